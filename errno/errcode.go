@@ -996,6 +996,9 @@ const (
 	ErrMultiStatementDisabled              = 8130
 	ErrPartitionStatsMissing               = 8131
 	ErrNotSupportedWithSem                 = 8132
+	ErrConstantFoldTimeout                 = 8133
+	ErrZeroDateNotAllowed                  = 8134
+	ErrZeroInDateNotAllowed                = 8135
 
 	// Error codes used by TiDB ddl package
 	ErrUnsupportedDDLOperation            = 8200
@@ -1039,6 +1039,10 @@ var MySQLErrName = map[uint16]*mysql.ErrMessage{
 	ErrPartitionStatsMissing: mysql.Message("Build table: %s global-level stats failed due to missing partition-level stats", nil),
 	ErrNotSupportedWithSem:   mysql.Message("Feature '%s' is not supported when security enhanced mode is enabled", nil),
 
+	ErrConstantFoldTimeout:  mysql.Message("Folding the constant expressions exceeds tidb_constant_fold_timeout_ms (%v)", nil),
+	ErrZeroDateNotAllowed:   mysql.Message("Incorrect %-.32s value: '%-.128s', the zero date is not allowed by NO_ZERO_DATE", nil),
+	ErrZeroInDateNotAllowed: mysql.Message("Incorrect %-.32s value: '%-.128s', the zero month or day is not allowed by NO_ZERO_IN_DATE", nil),
+
 	ErrInvalidPlacementSpec:   mysql.Message("Invalid placement policy '%s': %s", nil),
 	ErrPlacementPolicyCheck:   mysql.Message("Placement policy didn't meet the constraint, reason: %s", nil),
 	ErrMultiStatementDisabled: mysql.Message("client has multi-statement capability disabled. Run SET GLOBAL tidb_multi_statement_mode='ON' after you understand the security risk", nil),
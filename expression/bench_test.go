@@ -200,6 +200,22 @@ func BenchmarkScalarFunctionClone(b *testing.B) {
 	b.ReportAllocs()
 }
 
+func BenchmarkNewFunctionFold(b *testing.B) {
+	ctx := mock.NewContext()
+	tp := types.NewFieldType(mysql.TypeLonglong)
+	// The constants are folded with the timeout, see FoldConstantWithTimeout.
+	for _, timeoutMs := range []uint64{0, variable.DefTiDBConstantFoldTimeoutMs} {
+		b.Run(fmt.Sprintf("timeout=%vms", timeoutMs), func(b *testing.B) {
+			ctx.GetSessionVars().ConstantFoldTimeoutMs = timeoutMs
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				NewFunctionInternal(ctx, ast.Plus, tp, NewOne(), NewZero())
+			}
+			b.ReportAllocs()
+		})
+	}
+}
+
 func getRandomTime(r *rand.Rand) types.CoreTime {
 	return types.FromDate(r.Intn(2200), r.Intn(10)+1, r.Intn(20)+1,
 		r.Intn(12), r.Intn(60), r.Intn(60), r.Intn(1000000))
@@ -1204,13 +1220,13 @@ func genVecBuiltinFuncBenchCase(ctx sessionctx.Context, funcName string, testCas
 		tp := eType2FieldType(testCase.retEvalType)
 		switch testCase.retEvalType {
 		case types.ETInt:
-			fc = &castAsIntFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+			fc = &castAsIntFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, CastContext{}}
 		case types.ETDecimal:
-			fc = &castAsDecimalFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+			fc = &castAsDecimalFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, CastContext{}}
 		case types.ETReal:
-			fc = &castAsRealFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+			fc = &castAsRealFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, CastContext{}}
 		case types.ETDatetime, types.ETTimestamp:
-			fc = &castAsTimeFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+			fc = &castAsTimeFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, CastContext{}}
 		case types.ETDuration:
 			fc = &castAsDurationFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
 		case types.ETJson:
@@ -426,31 +426,40 @@ func (b *baseBuiltinFunc) Clone() builtinFunc {
 	panic("you should not call this method.")
 }
 
+func (b *baseBuiltinFunc) setCtx(ctx sessionctx.Context) {
+	b.ctx = ctx
+	for i, arg := range b.args {
+		b.args[i] = arg.CloneWithNewContext(ctx)
+	}
+}
+
 // baseBuiltinCastFunc will be contained in every struct that implement cast builtinFunc.
 type baseBuiltinCastFunc struct {
 	baseBuiltinFunc
-
-	// inUnion indicates whether cast is in union context.
-	inUnion bool
+	CastContext
 }
 
 // metadata returns the metadata of cast functions
 func (b *baseBuiltinCastFunc) metadata() proto.Message {
 	args := &tipb.InUnionMetadata{
-		InUnion: b.inUnion,
+		InUnion: b.InUnion,
 	}
 	return args
 }
 
+func (b *baseBuiltinCastFunc) getCastContext() CastContext {
+	return b.CastContext
+}
+
 func (b *baseBuiltinCastFunc) cloneFrom(from *baseBuiltinCastFunc) {
 	b.baseBuiltinFunc.cloneFrom(&from.baseBuiltinFunc)
-	b.inUnion = from.inUnion
+	b.CastContext = from.CastContext
 }
 
 func newBaseBuiltinCastFunc(builtinFunc baseBuiltinFunc, inUnion bool) baseBuiltinCastFunc {
 	return baseBuiltinCastFunc{
 		baseBuiltinFunc: builtinFunc,
-		inUnion:         inUnion,
+		CastContext:     CastContext{InUnion: inUnion},
 	}
 }
 
@@ -537,6 +546,8 @@ type builtinFunc interface {
 	metadata() proto.Message
 	// Clone returns a copy of itself.
 	Clone() builtinFunc
+	// setCtx binds this function and its arguments to ctx, it should only be called on a cloned function.
+	setCtx(ctx sessionctx.Context)
 
 	CollationInfo
 }
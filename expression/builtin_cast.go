@@ -25,13 +25,18 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/charset"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/terror"
 	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/types/json"
@@ -51,6 +56,7 @@ var (
 
 var (
 	_ builtinFunc = &builtinCastIntAsIntSig{}
+	_ builtinFunc = &builtinCastIntAsYearSig{}
 	_ builtinFunc = &builtinCastIntAsRealSig{}
 	_ builtinFunc = &builtinCastIntAsStringSig{}
 	_ builtinFunc = &builtinCastIntAsDecimalSig{}
@@ -110,7 +116,8 @@ var (
 type castAsIntFunctionClass struct {
 	baseFunctionClass
 
-	tp *types.FieldType
+	tp      *types.FieldType
+	castCtx CastContext
 }
 
 func (c *castAsIntFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (sig builtinFunc, err error) {
@@ -121,7 +128,7 @@ func (c *castAsIntFunctionClass) getFunction(ctx sessionctx.Context, args []Expr
 	if err != nil {
 		return nil, err
 	}
-	bf := newBaseBuiltinCastFunc(b, ctx.Value(inUnionCastContext) != nil)
+	bf := newBaseBuiltinCastFunc(b, c.castCtx.InUnion)
 	bf.tp = c.tp
 	if args[0].GetType().Hybrid() || IsBinaryLiteral(args[0]) {
 		sig = &builtinCastIntAsIntSig{bf}
@@ -129,6 +136,10 @@ func (c *castAsIntFunctionClass) getFunction(ctx sessionctx.Context, args []Expr
 		return sig, nil
 	}
 	argTp := args[0].GetType().EvalType()
+	if c.tp.Tp == mysql.TypeYear && argTp == types.ETInt && args[0].GetType().Tp != mysql.TypeYear {
+		// There is no pb signature for the cast as year, so it's always evaluated in TiDB.
+		return &builtinCastIntAsYearSig{bf}, nil
+	}
 	switch argTp {
 	case types.ETInt:
 		sig = &builtinCastIntAsIntSig{bf}
@@ -160,7 +171,8 @@ func (c *castAsIntFunctionClass) getFunction(ctx sessionctx.Context, args []Expr
 type castAsRealFunctionClass struct {
 	baseFunctionClass
 
-	tp *types.FieldType
+	tp      *types.FieldType
+	castCtx CastContext
 }
 
 func (c *castAsRealFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (sig builtinFunc, err error) {
@@ -171,8 +183,18 @@ func (c *castAsRealFunctionClass) getFunction(ctx sessionctx.Context, args []Exp
 	if err != nil {
 		return nil, err
 	}
-	bf := newBaseBuiltinCastFunc(b, ctx.Value(inUnionCastContext) != nil)
+	bf := newBaseBuiltinCastFunc(b, c.castCtx.InUnion)
 	bf.tp = c.tp
+	if c.tp.Flen == types.UnspecifiedLength {
+		// c.tp is owned by the caller, so the width is set on a copy.
+		bf.tp = c.tp.Clone()
+		switch bf.tp.Tp {
+		case mysql.TypeFloat:
+			setDataTypeFloat(bf.tp, bf.tp.Decimal)
+		case mysql.TypeDouble:
+			setDataTypeDouble(bf.tp, bf.tp.Decimal)
+		}
+	}
 	if IsBinaryLiteral(args[0]) {
 		sig = &builtinCastRealAsRealSig{bf}
 		sig.setPbCode(tipb.ScalarFuncSig_CastRealAsReal)
@@ -212,10 +234,37 @@ func (c *castAsRealFunctionClass) getFunction(ctx sessionctx.Context, args []Exp
 	return sig, nil
 }
 
+const (
+	// fltDIG is the number of significant decimal digits of a single-precision float.
+	fltDIG = 7
+	// dblDIG is the number of significant decimal digits of a double-precision float.
+	dblDIG = 15
+)
+
+// floatLength returns the display width of a floating point type with `digits` significant
+// decimal digits and `decimals` digits after the decimal point.
+func floatLength(digits, decimals int) int {
+	if decimals != types.UnspecifiedLength {
+		return digits + 2 + decimals
+	}
+	return digits + 8
+}
+
+// setDataTypeFloat sets the display width and the decimals of a FLOAT field type.
+func setDataTypeFloat(tp *types.FieldType, decimals int) {
+	tp.Flen, tp.Decimal = floatLength(fltDIG, decimals), decimals
+}
+
+// setDataTypeDouble sets the display width and the decimals of a DOUBLE field type.
+func setDataTypeDouble(tp *types.FieldType, decimals int) {
+	tp.Flen, tp.Decimal = floatLength(dblDIG, decimals), decimals
+}
+
 type castAsDecimalFunctionClass struct {
 	baseFunctionClass
 
-	tp *types.FieldType
+	tp      *types.FieldType
+	castCtx CastContext
 }
 
 func (c *castAsDecimalFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (sig builtinFunc, err error) {
@@ -226,7 +275,7 @@ func (c *castAsDecimalFunctionClass) getFunction(ctx sessionctx.Context, args []
 	if err != nil {
 		return nil, err
 	}
-	bf := newBaseBuiltinCastFunc(b, ctx.Value(inUnionCastContext) != nil)
+	bf := newBaseBuiltinCastFunc(b, c.castCtx.InUnion)
 	bf.tp = c.tp
 	if IsBinaryLiteral(args[0]) {
 		sig = &builtinCastDecimalAsDecimalSig{bf}
@@ -282,6 +331,19 @@ func (c *castAsStringFunctionClass) getFunction(ctx sessionctx.Context, args []E
 		return nil, err
 	}
 	bf.tp = c.tp
+	if c.tp.Tp == mysql.TypeEnum && len(c.tp.Elems) > 0 {
+		// The value is cast as string first, and then checked against the members.
+		// The string is not limited by the length of the members, or a longer value would be truncated
+		// to a member or rejected as too long before it's checked.
+		strTp := c.tp.Clone()
+		strTp.Tp, strTp.Elems, strTp.Flen = mysql.TypeVarString, nil, types.UnspecifiedLength
+		strFc := &castAsStringFunctionClass{c.baseFunctionClass, strTp}
+		strCast, err := strFc.getFunction(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return &builtinCastAsEnumSig{bf, strCast}, nil
+	}
 	if args[0].GetType().Hybrid() || IsBinaryLiteral(args[0]) {
 		sig = &builtinCastStringAsStringSig{bf}
 		sig.setPbCode(tipb.ScalarFuncSig_CastStringAsString)
@@ -316,10 +378,47 @@ func (c *castAsStringFunctionClass) getFunction(ctx sessionctx.Context, args []E
 	return sig, nil
 }
 
+// builtinCastAsEnumSig casts a value as a member of the ENUM type.
+type builtinCastAsEnumSig struct {
+	baseBuiltinFunc
+
+	// strCast casts the argument as string.
+	strCast builtinFunc
+}
+
+func (b *builtinCastAsEnumSig) Clone() builtinFunc {
+	newSig := &builtinCastAsEnumSig{strCast: b.strCast.Clone()}
+	newSig.cloneFrom(&b.baseBuiltinFunc)
+	return newSig
+}
+
+func (b *builtinCastAsEnumSig) setCtx(ctx sessionctx.Context) {
+	b.baseBuiltinFunc.setCtx(ctx)
+	b.strCast.setCtx(ctx)
+}
+
+// evalString evals a builtinCastAsEnumSig.
+// Like the conversion of the datum, a member is matched by its name or its index, the value which doesn't
+// match any member is truncated to the empty string.
+func (b *builtinCastAsEnumSig) evalString(row chunk.Row) (res string, isNull bool, err error) {
+	val, isNull, err := b.strCast.evalString(row)
+	if isNull || err != nil {
+		return res, isNull, err
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	str := types.NewStringDatum(val)
+	d, err := str.ConvertTo(sc, b.tp)
+	if err = sc.HandleTruncate(err); err != nil {
+		return res, false, err
+	}
+	return d.GetMysqlEnum().Name, false, nil
+}
+
 type castAsTimeFunctionClass struct {
 	baseFunctionClass
 
-	tp *types.FieldType
+	tp      *types.FieldType
+	castCtx CastContext
 }
 
 func (c *castAsTimeFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (sig builtinFunc, err error) {
@@ -334,10 +433,10 @@ func (c *castAsTimeFunctionClass) getFunction(ctx sessionctx.Context, args []Exp
 	argTp := args[0].GetType().EvalType()
 	switch argTp {
 	case types.ETInt:
-		sig = &builtinCastIntAsTimeSig{bf}
+		sig = &builtinCastIntAsTimeSig{bf, c.castCtx.Implicit}
 		sig.setPbCode(tipb.ScalarFuncSig_CastIntAsTime)
 	case types.ETReal:
-		sig = &builtinCastRealAsTimeSig{bf}
+		sig = &builtinCastRealAsTimeSig{bf, c.castCtx.Implicit}
 		sig.setPbCode(tipb.ScalarFuncSig_CastRealAsTime)
 	case types.ETDecimal:
 		sig = &builtinCastDecimalAsTimeSig{bf}
@@ -352,7 +451,7 @@ func (c *castAsTimeFunctionClass) getFunction(ctx sessionctx.Context, args []Exp
 		sig = &builtinCastJSONAsTimeSig{bf}
 		sig.setPbCode(tipb.ScalarFuncSig_CastJsonAsTime)
 	case types.ETString:
-		sig = &builtinCastStringAsTimeSig{bf}
+		sig = &builtinCastStringAsTimeSig{bf, c.castCtx.Implicit}
 		sig.setPbCode(tipb.ScalarFuncSig_CastStringAsTime)
 	default:
 		panic("unsupported types.EvalType in castAsTimeFunctionClass")
@@ -464,12 +563,46 @@ func (b *builtinCastIntAsIntSig) evalInt(row chunk.Row) (res int64, isNull bool,
 	if isNull || err != nil {
 		return
 	}
-	if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
+	if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
 		res = 0
 	}
 	return
 }
 
+type builtinCastIntAsYearSig struct {
+	baseBuiltinCastFunc
+}
+
+func (b *builtinCastIntAsYearSig) Clone() builtinFunc {
+	newSig := &builtinCastIntAsYearSig{}
+	newSig.cloneFrom(&b.baseBuiltinCastFunc)
+	return newSig
+}
+
+func (b *builtinCastIntAsYearSig) evalInt(row chunk.Row) (res int64, isNull bool, err error) {
+	val, isNull, err := b.args[0].EvalInt(b.ctx, row)
+	if isNull || err != nil {
+		return 0, isNull, err
+	}
+	res, isNull, err = castIntAsYear(b.ctx.GetSessionVars().StmtCtx, val, mysql.HasUnsignedFlag(b.args[0].GetType().Flag))
+	return
+}
+
+// castIntAsYear adjusts val to a year, the 2-digit values are mapped to 1970-2069. A value out of
+// the range of YEAR is truncated to NULL with a warning.
+func castIntAsYear(sc *stmtctx.StatementContext, val int64, unsigned bool) (int64, bool, error) {
+	if !unsigned || val >= 0 {
+		if year, err := types.AdjustYear(val, false); err == nil {
+			return year, false, nil
+		}
+	}
+	str := strconv.FormatInt(val, 10)
+	if unsigned {
+		str = strconv.FormatUint(uint64(val), 10)
+	}
+	return 0, true, sc.HandleTruncate(types.ErrTruncatedWrongVal.GenWithStackByArgs("YEAR", str))
+}
+
 type builtinCastIntAsRealSig struct {
 	baseBuiltinCastFunc
 }
@@ -487,11 +620,11 @@ func (b *builtinCastIntAsRealSig) evalReal(row chunk.Row) (res float64, isNull b
 	}
 	if unsignedArgs0 := mysql.HasUnsignedFlag(b.args[0].GetType().Flag); !mysql.HasUnsignedFlag(b.tp.Flag) && !unsignedArgs0 {
 		res = float64(val)
-	} else if b.inUnion && !unsignedArgs0 && val < 0 {
+	} else if b.InUnion && !unsignedArgs0 && val < 0 {
 		// Round up to 0 if the value is negative but the expression eval type is unsigned in `UNION` statement
 		// NOTE: the following expressions are equal (so choose the more efficient one):
-		// `b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && !unsignedArgs0 && val < 0`
-		// `b.inUnion && !unsignedArgs0 && val < 0`
+		// `b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && !unsignedArgs0 && val < 0`
+		// `b.InUnion && !unsignedArgs0 && val < 0`
 		res = 0
 	} else {
 		// recall that, int to float is different from uint to float
@@ -520,9 +653,9 @@ func (b *builtinCastIntAsDecimalSig) evalDecimal(row chunk.Row) (res *types.MyDe
 		res = types.NewDecFromInt(val)
 		// Round up to 0 if the value is negative but the expression eval type is unsigned in `UNION` statement
 		// NOTE: the following expressions are equal (so choose the more efficient one):
-		// `b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && !unsignedArgs0 && val < 0`
-		// `b.inUnion && !unsignedArgs0 && val < 0`
-	} else if b.inUnion && !unsignedArgs0 && val < 0 {
+		// `b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && !unsignedArgs0 && val < 0`
+		// `b.InUnion && !unsignedArgs0 && val < 0`
+	} else if b.InUnion && !unsignedArgs0 && val < 0 {
 		res = &types.MyDecimal{}
 	} else {
 		res = types.NewDecFromUint(uint64(val))
@@ -564,10 +697,14 @@ func (b *builtinCastIntAsStringSig) evalString(row chunk.Row) (res string, isNul
 
 type builtinCastIntAsTimeSig struct {
 	baseBuiltinFunc
+
+	// implicit indicates whether the cast is built for the argument of a function,
+	// the zero date isn't checked since the function checks it itself.
+	implicit bool
 }
 
 func (b *builtinCastIntAsTimeSig) Clone() builtinFunc {
-	newSig := &builtinCastIntAsTimeSig{}
+	newSig := &builtinCastIntAsTimeSig{implicit: b.implicit}
 	newSig.cloneFrom(&b.baseBuiltinFunc)
 	return newSig
 }
@@ -578,7 +715,10 @@ func (b *builtinCastIntAsTimeSig) evalTime(row chunk.Row) (res types.Time, isNul
 		return res, isNull, err
 	}
 
-	if b.args[0].GetType().Tp == mysql.TypeYear {
+	if b.args[0].GetType().Tp == mysql.TypeYear && val == 0 {
+		// YEAR 0000 is converted to the zero value of the target type, the same as MySQL.
+		res = types.NewTime(types.ZeroCoreTime, b.tp.Tp, int8(b.tp.Decimal))
+	} else if b.args[0].GetType().Tp == mysql.TypeYear {
 		res, err = types.ParseTimeFromYear(b.ctx.GetSessionVars().StmtCtx, val)
 	} else {
 		res, err = types.ParseTimeFromNum(b.ctx.GetSessionVars().StmtCtx, val, b.tp.Tp, int8(b.tp.Decimal))
@@ -587,6 +727,9 @@ func (b *builtinCastIntAsTimeSig) evalTime(row chunk.Row) (res types.Time, isNul
 	if err != nil {
 		return types.ZeroTime, true, handleInvalidTimeError(b.ctx, err)
 	}
+	if err = handleZeroTimeError(b.ctx, res, b.implicit); err != nil {
+		return types.ZeroTime, true, err
+	}
 	if b.tp.Tp == mysql.TypeDate {
 		// Truncate hh:mm:ss part if the type is Date.
 		res.SetCoreTime(types.FromDate(res.Year(), res.Month(), res.Day(), 0, 0, 0, 0))
@@ -612,7 +755,7 @@ func (b *builtinCastIntAsDurationSig) evalDuration(row chunk.Row) (res types.Dur
 	dur, err := types.NumberToDuration(val, int8(b.tp.Decimal))
 	if err != nil {
 		if types.ErrOverflow.Equal(err) {
-			err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, err)
+			err = handleCastOverflow(b.ctx, err, err)
 		}
 		if types.ErrTruncatedWrongVal.Equal(err) {
 			err = b.ctx.GetSessionVars().StmtCtx.HandleTruncate(err)
@@ -761,7 +904,7 @@ func (b *builtinCastRealAsRealSig) Clone() builtinFunc {
 
 func (b *builtinCastRealAsRealSig) evalReal(row chunk.Row) (res float64, isNull bool, err error) {
 	res, isNull, err = b.args[0].EvalReal(b.ctx, row)
-	if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
+	if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
 		res = 0
 	}
 	return
@@ -777,14 +920,39 @@ func (b *builtinCastRealAsIntSig) Clone() builtinFunc {
 	return newSig
 }
 
+// convertNaNOrInf converts NaN to 0 and ±Inf to the bounds of the return type like MySQL, ok is false if val
+// is neither of them. The conversion is undefined for them in Go, so they can't be passed to ConvertFloatToInt.
+func (b *builtinCastRealAsIntSig) convertNaNOrInf(val float64) (res int64, ok bool, err error) {
+	switch {
+	case math.IsNaN(val):
+		res = 0
+	case math.IsInf(val, 1) && mysql.HasUnsignedFlag(b.tp.Flag):
+		uval := uint64(math.MaxUint64)
+		res = int64(uval)
+	case math.IsInf(val, 1):
+		res = math.MaxInt64
+	case math.IsInf(val, -1) && mysql.HasUnsignedFlag(b.tp.Flag):
+		res = 0
+	case math.IsInf(val, -1):
+		res = math.MinInt64
+	default:
+		return 0, false, nil
+	}
+	err = types.ErrOverflow.GenWithStack("constant %v overflows %s", val, types.TypeStr(mysql.TypeLonglong))
+	return res, true, handleCastOverflow(b.ctx, err, err)
+}
+
 func (b *builtinCastRealAsIntSig) evalInt(row chunk.Row) (res int64, isNull bool, err error) {
 	val, isNull, err := b.args[0].EvalReal(b.ctx, row)
 	if isNull || err != nil {
 		return res, isNull, err
 	}
+	if res, ok, err := b.convertNaNOrInf(val); ok {
+		return res, false, err
+	}
 	if !mysql.HasUnsignedFlag(b.tp.Flag) {
 		res, err = types.ConvertFloatToInt(val, types.IntergerSignedLowerBound(mysql.TypeLonglong), types.IntergerSignedUpperBound(mysql.TypeLonglong), mysql.TypeLonglong)
-	} else if b.inUnion && val < 0 {
+	} else if b.InUnion && val < 0 {
 		res = 0
 	} else {
 		var uintVal uint64
@@ -793,7 +961,7 @@ func (b *builtinCastRealAsIntSig) evalInt(row chunk.Row) (res int64, isNull bool
 		res = int64(uintVal)
 	}
 	if types.ErrOverflow.Equal(err) {
-		err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, err)
+		err = handleCastOverflow(b.ctx, err, err)
 	}
 	return res, isNull, err
 }
@@ -814,13 +982,13 @@ func (b *builtinCastRealAsDecimalSig) evalDecimal(row chunk.Row) (res *types.MyD
 		return res, isNull, err
 	}
 	res = new(types.MyDecimal)
-	if !b.inUnion || val >= 0 {
+	if !b.InUnion || val >= 0 {
 		err = res.FromFloat64(val)
 		if types.ErrOverflow.Equal(err) {
 			warnErr := types.ErrTruncatedWrongVal.GenWithStackByArgs("DECIMAL", b.args[0])
-			err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, warnErr)
+			err = handleCastOverflow(b.ctx, err, warnErr)
 		} else if types.ErrTruncated.Equal(err) {
-			// This behavior is consistent with MySQL.
+			handleCastRealAsDecimalTruncated(b.ctx, val, res)
 			err = nil
 		}
 		if err != nil {
@@ -847,26 +1015,49 @@ func (b *builtinCastRealAsStringSig) evalString(row chunk.Row) (res string, isNu
 		return res, isNull, err
 	}
 
+	res, err = types.ProduceStrWithSpecifiedTp(formatRealAsString(val, b.args[0].GetType().Tp), b.tp, b.ctx.GetSessionVars().StmtCtx, false)
+	if err != nil {
+		return res, false, err
+	}
+	return padZeroForBinaryType(res, b.tp, b.ctx)
+}
+
+const (
+	expFormatBig   = 1e15
+	expFormatSmall = 1e-15
+)
+
+// formatRealAsString formats the real value like my_gcvt of MySQL. The shortest digits are used,
+// and the E notation is used if the absolute value is out of [1e-15, 1e15), e.g. 1.23e15 is
+// formatted as "1.23e15", and 123456789 is formatted as "123456789".
+func formatRealAsString(val float64, tp byte) string {
 	bits := 64
-	if b.args[0].GetType().Tp == mysql.TypeFloat {
-		// b.args[0].EvalReal() casts the value from float32 to float64, for example:
+	absVal := math.Abs(val)
+	isEFormat := absVal >= expFormatBig || (absVal != 0 && absVal < expFormatSmall)
+	if tp == mysql.TypeFloat {
+		// EvalReal() casts the value from float32 to float64, for example:
 		// float32(208.867) is cast to float64(208.86700439)
 		// If we strconv.FormatFloat the value with 64bits, the result is incorrect!
 		bits = 32
+		isEFormat = float32(absVal) >= expFormatBig || (absVal != 0 && float32(absVal) < expFormatSmall)
 	}
-	res, err = types.ProduceStrWithSpecifiedTp(strconv.FormatFloat(val, 'f', -1, bits), b.tp, b.ctx.GetSessionVars().StmtCtx, false)
-	if err != nil {
-		return res, false, err
+	if isEFormat {
+		// MySQL doesn't write the '+' of the exponent, e.g. 1e22.
+		return strings.Replace(strconv.FormatFloat(val, 'e', -1, bits), "e+", "e", 1)
 	}
-	return padZeroForBinaryType(res, b.tp, b.ctx)
+	return strconv.FormatFloat(val, 'f', -1, bits)
 }
 
 type builtinCastRealAsTimeSig struct {
 	baseBuiltinFunc
+
+	// implicit indicates whether the cast is built for the argument of a function,
+	// the zero date isn't checked since the function checks it itself.
+	implicit bool
 }
 
 func (b *builtinCastRealAsTimeSig) Clone() builtinFunc {
-	newSig := &builtinCastRealAsTimeSig{}
+	newSig := &builtinCastRealAsTimeSig{implicit: b.implicit}
 	newSig.cloneFrom(&b.baseBuiltinFunc)
 	return newSig
 }
@@ -886,6 +1077,9 @@ func (b *builtinCastRealAsTimeSig) evalTime(row chunk.Row) (types.Time, bool, er
 	if err != nil {
 		return types.ZeroTime, true, handleInvalidTimeError(b.ctx, err)
 	}
+	if err = handleZeroTimeError(b.ctx, res, b.implicit); err != nil {
+		return types.ZeroTime, true, err
+	}
 	if b.tp.Tp == mysql.TypeDate {
 		// Truncate hh:mm:ss part if the type is Date.
 		res.SetCoreTime(types.FromDate(res.Year(), res.Month(), res.Day(), 0, 0, 0, 0))
@@ -935,7 +1129,7 @@ func (b *builtinCastDecimalAsDecimalSig) evalDecimal(row chunk.Row) (res *types.
 		return res, isNull, err
 	}
 	res = &types.MyDecimal{}
-	if !(b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && evalDecimal.IsNegative()) {
+	if !(b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && evalDecimal.IsNegative()) {
 		*res = *evalDecimal
 	}
 	sc := b.ctx.GetSessionVars().StmtCtx
@@ -968,7 +1162,7 @@ func (b *builtinCastDecimalAsIntSig) evalInt(row chunk.Row) (res int64, isNull b
 
 	if !mysql.HasUnsignedFlag(b.tp.Flag) {
 		res, err = to.ToInt()
-	} else if b.inUnion && to.IsNegative() {
+	} else if b.InUnion && to.IsNegative() {
 		res = 0
 	} else {
 		var uintRes uint64
@@ -978,7 +1172,7 @@ func (b *builtinCastDecimalAsIntSig) evalInt(row chunk.Row) (res int64, isNull b
 
 	if types.ErrOverflow.Equal(err) {
 		warnErr := types.ErrTruncatedWrongVal.GenWithStackByArgs("DECIMAL", val)
-		err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, warnErr)
+		err = handleCastOverflow(b.ctx, err, warnErr)
 	}
 
 	return res, false, err
@@ -1022,7 +1216,11 @@ func (b *builtinCastDecimalAsRealSig) evalReal(row chunk.Row) (res float64, isNu
 	if isNull || err != nil {
 		return res, isNull, err
 	}
-	if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && val.IsNegative() {
+	if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && val.IsNegative() {
+		res = 0
+	} else if val.IsNegative() && val.IsZero() {
+		// Like MySQL, the negative zero of decimal is cast as the positive zero.
+		b.ctx.GetSessionVars().StmtCtx.AppendNote(types.ErrTruncatedWrongVal.GenWithStackByArgs("DOUBLE", val.String()))
 		res = 0
 	} else {
 		res, err = val.ToFloat64()
@@ -1046,7 +1244,7 @@ func (b *builtinCastDecimalAsTimeSig) evalTime(row chunk.Row) (res types.Time, i
 		return res, isNull, err
 	}
 	sc := b.ctx.GetSessionVars().StmtCtx
-	res, err = types.ParseTimeFromFloatString(sc, string(val.ToString()), b.tp.Tp, int8(b.tp.Decimal))
+	res, err = types.ParseTimeFromDecimal(sc, val, b.tp.Tp, int8(b.tp.Decimal))
 	if err != nil {
 		return types.ZeroTime, true, handleInvalidTimeError(b.ctx, err)
 	}
@@ -1114,6 +1312,30 @@ func (b *builtinCastStringAsIntSig) Clone() builtinFunc {
 	return newSig
 }
 
+// handleCastRealAsDecimalTruncated handles the ErrTruncated returned by converting the real val to the decimal dec.
+// The conversion of the binary representation always rounds, which isn't reported like MySQL, so the warning
+// is appended only if dec converted back differs from val by more than 1 ULP.
+func handleCastRealAsDecimalTruncated(ctx sessionctx.Context, val float64, dec *types.MyDecimal) {
+	f, err := dec.ToFloat64()
+	if err == nil && (f == val || f == math.Nextafter(val, math.Inf(1)) || f == math.Nextafter(val, math.Inf(-1))) {
+		return
+	}
+	ctx.GetSessionVars().StmtCtx.AppendWarning(types.ErrTruncatedWrongVal.GenWithStackByArgs("DECIMAL", strconv.FormatFloat(val, 'g', -1, 64)))
+}
+
+// handleCastOverflow treats the overflow error of a cast as a warning or returns it,
+// it always returns the error if tidb_cast_overflow_as_error is ON, otherwise it
+// follows the StmtCtx.OverflowAsWarning state.
+func handleCastOverflow(ctx sessionctx.Context, err error, warnErr error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.GetSessionVars().CastOverflowAsError {
+		return err
+	}
+	return ctx.GetSessionVars().StmtCtx.HandleOverflow(err, warnErr)
+}
+
 // handleOverflow handles the overflow caused by cast string as int,
 // see https://dev.mysql.com/doc/refman/5.7/en/out-of-range-and-overflow.html.
 // When an out-of-range value is assigned to an integer column, MySQL stores the value representing the corresponding endpoint of the column data type range. If it is in select statement, it will return the
@@ -1124,7 +1346,6 @@ func (b *builtinCastStringAsIntSig) handleOverflow(origRes int64, origStr string
 		return
 	}
 
-	sc := b.ctx.GetSessionVars().StmtCtx
 	if types.ErrOverflow.Equal(origErr) {
 		if isNegative {
 			res = math.MinInt64
@@ -1133,7 +1354,7 @@ func (b *builtinCastStringAsIntSig) handleOverflow(origRes int64, origStr string
 			res = int64(uval)
 		}
 		warnErr := types.ErrTruncatedWrongVal.GenWithStackByArgs("INTEGER", origStr)
-		err = sc.HandleOverflow(origErr, warnErr)
+		err = handleCastOverflow(b.ctx, origErr, warnErr)
 	}
 	return
 }
@@ -1168,7 +1389,7 @@ func (b *builtinCastStringAsIntSig) evalInt(row chunk.Row) (res int64, isNull bo
 		if err == nil && !mysql.HasUnsignedFlag(b.tp.Flag) && ures > uint64(math.MaxInt64) {
 			sc.AppendWarning(types.ErrCastAsSignedOverflow)
 		}
-	} else if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) {
+	} else if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) {
 		res = 0
 	} else {
 		res, err = types.StrToInt(sc, val, true)
@@ -1211,7 +1432,7 @@ func (b *builtinCastStringAsRealSig) evalReal(row chunk.Row) (res float64, isNul
 	if err != nil {
 		return 0, false, err
 	}
-	if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
+	if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
 		res = 0
 	}
 	res, err = types.ProduceFloatWithSpecifiedTp(res, b.tp, sc)
@@ -1237,10 +1458,11 @@ func (b *builtinCastStringAsDecimalSig) evalDecimal(row chunk.Row) (res *types.M
 		return res, isNull, err
 	}
 	val = strings.TrimSpace(val)
+	val = stripLocaleNumeric(b.ctx, val)
 	isNegative := len(val) > 1 && val[0] == '-'
 	res = new(types.MyDecimal)
 	sc := b.ctx.GetSessionVars().StmtCtx
-	if !(b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && isNegative) {
+	if !(b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && isNegative) {
 		err = sc.HandleTruncate(res.FromString([]byte(val)))
 		if err != nil {
 			return res, false, err
@@ -1250,12 +1472,73 @@ func (b *builtinCastStringAsDecimalSig) evalDecimal(row chunk.Row) (res *types.M
 	return res, false, err
 }
 
+// localeNumericFormat is the numeric format of a locale used by the locale aware cast.
+type localeNumericFormat struct {
+	currencySymbols []string
+	thousandsSep    string
+	decimalPoint    string
+}
+
+// localeNumericFormats contains the locales supported by the `lc_numeric` variable.
+var localeNumericFormats = map[string]localeNumericFormat{
+	"en_US": {[]string{"$"}, ",", "."},
+	"en_GB": {[]string{"£"}, ",", "."},
+	"de_DE": {[]string{"€"}, ".", ","},
+	"fr_FR": {[]string{"€"}, " ", ","},
+	"ja_JP": {[]string{"¥", "￥"}, ",", "."},
+	"zh_CN": {[]string{"¥", "￥"}, ",", "."},
+}
+
+// stripLocaleNumeric strips the currency symbol and the thousands separators defined by `lc_numeric`
+// from `val` and converts the decimal point to '.', if `tidb_enable_locale_aware_cast` is enabled.
+// A note is appended in strict mode if `val` is changed.
+func stripLocaleNumeric(ctx sessionctx.Context, val string) string {
+	vars := ctx.GetSessionVars()
+	if !vars.EnableLocaleAwareCast {
+		return val
+	}
+	format, ok := localeNumericFormats[vars.LcNumeric]
+	if !ok {
+		return val
+	}
+	origin, sign := val, ""
+	if len(val) > 0 && (val[0] == '-' || val[0] == '+') {
+		sign, val = val[:1], val[1:]
+	}
+	for _, symbol := range format.currencySymbols {
+		if strings.HasPrefix(val, symbol) {
+			val = strings.TrimSpace(val[len(symbol):])
+			break
+		} else if strings.HasSuffix(val, symbol) {
+			val = strings.TrimSpace(val[:len(val)-len(symbol)])
+			break
+		}
+	}
+	// The sign may follow the currency symbol, like `$-1.5`.
+	if sign == "" && len(val) > 0 && (val[0] == '-' || val[0] == '+') {
+		sign, val = val[:1], val[1:]
+	}
+	val = strings.Replace(val, format.thousandsSep, "", -1)
+	if format.decimalPoint != "." {
+		val = strings.Replace(val, format.decimalPoint, ".", -1)
+	}
+	val = sign + val
+	if val != origin && vars.StrictSQLMode {
+		vars.StmtCtx.AppendNote(types.ErrTruncatedWrongVal.GenWithStackByArgs("DECIMAL", origin))
+	}
+	return val
+}
+
 type builtinCastStringAsTimeSig struct {
 	baseBuiltinFunc
+
+	// implicit indicates whether the cast is built for the argument of a function,
+	// the zero date isn't checked since the function checks it itself.
+	implicit bool
 }
 
 func (b *builtinCastStringAsTimeSig) Clone() builtinFunc {
-	newSig := &builtinCastStringAsTimeSig{}
+	newSig := &builtinCastStringAsTimeSig{implicit: b.implicit}
 	newSig.cloneFrom(&b.baseBuiltinFunc)
 	return newSig
 }
@@ -1266,10 +1549,13 @@ func (b *builtinCastStringAsTimeSig) evalTime(row chunk.Row) (res types.Time, is
 		return res, isNull, err
 	}
 	sc := b.ctx.GetSessionVars().StmtCtx
-	res, err = types.ParseTime(sc, val, b.tp.Tp, int8(b.tp.Decimal))
+	res, err = b.parseTime(sc, val)
 	if err != nil {
 		return types.ZeroTime, true, handleInvalidTimeError(b.ctx, err)
 	}
+	if err = handleZeroTimeError(b.ctx, res, b.implicit); err != nil {
+		return types.ZeroTime, true, err
+	}
 	if b.tp.Tp == mysql.TypeDate {
 		// Truncate hh:mm:ss part if the type is Date.
 		res.SetCoreTime(types.FromDate(res.Year(), res.Month(), res.Day(), 0, 0, 0, 0))
@@ -1277,6 +1563,31 @@ func (b *builtinCastStringAsTimeSig) evalTime(row chunk.Row) (res types.Time, is
 	return res, false, nil
 }
 
+// parseTime parses the string as time. For the explicit CAST, if the fractional seconds part has
+// more than 6 digits, the excess digits are truncated rather than rounded before parsing, and a
+// warning is appended. The implicit cast for the argument of a function keeps rounding them.
+func (b *builtinCastStringAsTimeSig) parseTime(sc *stmtctx.StatementContext, str string) (types.Time, error) {
+	fracIdx := types.GetFracIndex(str)
+	if b.implicit || fracIdx < 0 || len(str)-fracIdx-1 <= int(types.MaxFsp) || !isAllDigits(str[fracIdx+1:]) {
+		return types.ParseTime(sc, str, b.tp.Tp, int8(b.tp.Decimal))
+	}
+	t, err := types.ParseTime(sc, str[:fracIdx+1+int(types.MaxFsp)], b.tp.Tp, int8(b.tp.Decimal))
+	if err != nil {
+		return t, err
+	}
+	sc.AppendWarning(types.ErrTruncatedWrongVal.GenWithStackByArgs(types.DateTimeStr, str))
+	return t, nil
+}
+
+func isAllDigits(str string) bool {
+	for i := range str {
+		if str[i] < '0' || str[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 type builtinCastStringAsDurationSig struct {
 	baseBuiltinFunc
 }
@@ -1324,7 +1635,12 @@ func (b *builtinCastTimeAsTimeSig) evalTime(row chunk.Row) (res types.Time, isNu
 	if res, err = res.Convert(sc, b.tp.Tp); err != nil {
 		return types.ZeroTime, true, handleInvalidTimeError(b.ctx, err)
 	}
-	res, err = res.RoundFrac(sc, int8(b.tp.Decimal))
+	fsp := int8(b.tp.Decimal)
+	if b.tp.Decimal == types.UnspecifiedLength {
+		// Keep the precision of the source, RoundFrac would round it to DefaultFsp.
+		fsp = res.Fsp()
+	}
+	res, err = res.RoundFrac(sc, fsp)
 	if b.tp.Tp == mysql.TypeDate {
 		// Truncate hh:mm:ss part if the type is Date.
 		res.SetCoreTime(types.FromDate(res.Year(), res.Month(), res.Day(), 0, 0, 0, 0))
@@ -1412,13 +1728,28 @@ func (b *builtinCastTimeAsStringSig) evalString(row chunk.Row) (res string, isNu
 		return res, isNull, err
 	}
 	sc := b.ctx.GetSessionVars().StmtCtx
-	res, err = types.ProduceStrWithSpecifiedTp(val.String(), b.tp, sc, false)
+	str := val.String()
+	if format := b.timestampFormat(); format != "" {
+		if str, err = val.DateFormat(format); err != nil {
+			return res, false, err
+		}
+	}
+	res, err = types.ProduceStrWithSpecifiedTp(str, b.tp, sc, false)
 	if err != nil {
 		return res, false, err
 	}
 	return padZeroForBinaryType(res, b.tp, b.ctx)
 }
 
+// timestampFormat returns the DATE_FORMAT pattern set by tidb_timestamp_cast_format if the argument
+// is a TIMESTAMP, the empty result means the default format is used.
+func (b *builtinCastTimeAsStringSig) timestampFormat() string {
+	if b.args[0].GetType().Tp != mysql.TypeTimestamp {
+		return ""
+	}
+	return b.ctx.GetSessionVars().TimestampCastFormat
+}
+
 type builtinCastTimeAsDurationSig struct {
 	baseBuiltinFunc
 }
@@ -1525,7 +1856,7 @@ func (b *builtinCastDurationAsDecimalSig) evalDecimal(row chunk.Row) (res *types
 		return res, false, err
 	}
 	sc := b.ctx.GetSessionVars().StmtCtx
-	res, err = types.ProduceDecWithSpecifiedTp(val.ToNumber(), b.tp, sc)
+	res, err = types.ProduceDecWithSpecifiedTp(val.ToDecimalExact(), b.tp, sc)
 	return res, false, err
 }
 
@@ -1559,14 +1890,22 @@ func padZeroForBinaryType(s string, tp *types.FieldType, ctx sessionctx.Context)
 		valStr, _ := ctx.GetSessionVars().GetSystemVar(variable.MaxAllowedPacket)
 		maxAllowedPacket, err := strconv.ParseUint(valStr, 10, 64)
 		if err != nil {
-			return "", false, errors.Trace(err)
+			return "", true, errors.Trace(err)
 		}
 		if uint64(flen) > maxAllowedPacket {
 			sc.AppendWarning(errWarnAllowedPacketOverflowed.GenWithStackByArgs("cast_as_binary", maxAllowedPacket))
 			return "", true, nil
 		}
-		padding := make([]byte, flen-len(s))
-		s = string(append([]byte(s), padding...))
+		// The buffer is taken from the pool if flen fits in it, the larger one, e.g. for
+		// CAST(... AS BINARY(n)) with a large n, is allocated by allocByteSlice.
+		pooled := allocByteSlice(flen)
+		buf := pooled[:flen]
+		n := copy(buf, s)
+		for i := n; i < flen; i++ {
+			buf[i] = 0
+		}
+		s = string(buf)
+		deallocateByteSlice(pooled)
 	}
 	return s, false, nil
 }
@@ -1706,12 +2045,8 @@ func (b *builtinCastJSONAsTimeSig) evalTime(row chunk.Row) (res types.Time, isNu
 	if isNull || err != nil {
 		return res, isNull, err
 	}
-	s, err := val.Unquote()
-	if err != nil {
-		return res, false, err
-	}
 	sc := b.ctx.GetSessionVars().StmtCtx
-	res, err = types.ParseTime(sc, s, b.tp.Tp, int8(b.tp.Decimal))
+	res, err = types.ConvertJSONToTime(sc, val, b.tp.Tp, b.tp.Decimal)
 	if err != nil {
 		return types.ZeroTime, true, handleInvalidTimeError(b.ctx, err)
 	}
@@ -1737,11 +2072,16 @@ func (b *builtinCastJSONAsDurationSig) evalDuration(row chunk.Row) (res types.Du
 	if isNull || err != nil {
 		return res, isNull, err
 	}
-	s, err := val.Unquote()
-	if err != nil {
-		return res, false, err
+	if val.TypeCode == json.TypeCodeObject && b.ctx.GetSessionVars().JSONObjectAsDuration {
+		res, err = castJSONObjectAsDuration(val, int8(b.tp.Decimal))
+	} else {
+		var s string
+		s, err = val.Unquote()
+		if err != nil {
+			return res, false, err
+		}
+		res, err = types.ParseDuration(b.ctx.GetSessionVars().StmtCtx, s, int8(b.tp.Decimal))
 	}
-	res, err = types.ParseDuration(b.ctx.GetSessionVars().StmtCtx, s, int8(b.tp.Decimal))
 	if types.ErrTruncatedWrongVal.Equal(err) {
 		sc := b.ctx.GetSessionVars().StmtCtx
 		err = sc.HandleTruncate(err)
@@ -1749,18 +2089,86 @@ func (b *builtinCastJSONAsDurationSig) evalDuration(row chunk.Row) (res types.Du
 	return
 }
 
-// inCastContext is session key type that indicates whether executing
-// in special cast context that negative unsigned num will be zero.
-type inCastContext int
+// jsonDurationFields are the fields of the JSON object cast as duration by castJSONObjectAsDuration,
+// and the units of them.
+var jsonDurationFields = []struct {
+	path json.PathExpression
+	unit time.Duration
+}{
+	{mustParseJSONPathExpr("$.hours"), time.Hour},
+	{mustParseJSONPathExpr("$.minutes"), time.Minute},
+	{mustParseJSONPathExpr("$.seconds"), time.Second},
+	{mustParseJSONPathExpr("$.microseconds"), time.Microsecond},
+}
+
+func mustParseJSONPathExpr(s string) json.PathExpression {
+	path, err := json.ParseJSONPathExpr(s)
+	terror.MustNil(err)
+	return path
+}
+
+// castJSONObjectAsDuration casts a JSON object like {"hours": 2, "minutes": 30} as duration, which is used
+// by some ORMs. The duration is the sum of the fields "hours", "minutes", "seconds" and "microseconds",
+// the absent ones are 0 and the others are ignored. The fields must be numbers, they may be negative
+// or fractional. Like ParseDuration, ErrTruncatedWrongVal is returned if the object isn't a duration,
+// and the duration out of the range of TIME is truncated with ErrTruncatedWrongVal.
+func castJSONObjectAsDuration(val json.BinaryJSON, fsp int8) (types.Duration, error) {
+	found := false
+	var total float64
+	for _, f := range jsonDurationFields {
+		v, ok := val.Extract([]json.PathExpression{f.path})
+		if !ok {
+			continue
+		}
+		found = true
+		var n float64
+		switch v.TypeCode {
+		case json.TypeCodeInt64:
+			n = float64(v.GetInt64())
+		case json.TypeCodeUint64:
+			n = float64(v.GetUint64())
+		case json.TypeCodeFloat64:
+			n = v.GetFloat64()
+		default:
+			return types.ZeroDuration, types.ErrTruncatedWrongVal.GenWithStackByArgs("time", val.String())
+		}
+		total += n * float64(f.unit)
+	}
+	if !found {
+		return types.ZeroDuration, types.ErrTruncatedWrongVal.GenWithStackByArgs("time", val.String())
+	}
+	if total > float64(types.MaxTime) {
+		return types.Duration{Duration: types.MaxTime, Fsp: fsp}, types.ErrTruncatedWrongVal.GenWithStackByArgs("time", val.String())
+	} else if total < float64(types.MinTime) {
+		return types.Duration{Duration: types.MinTime, Fsp: fsp}, types.ErrTruncatedWrongVal.GenWithStackByArgs("time", val.String())
+	}
+	return types.Duration{Duration: time.Duration(total), Fsp: types.MaxFsp}.RoundFrac(fsp)
+}
 
-func (i inCastContext) String() string {
-	return "__cast_ctx"
+// CastContext is the context of a cast function which affects its evaluation.
+type CastContext struct {
+	// InUnion indicates whether the cast is built for the `UNION` statement,
+	// in which the negative number cast to unsigned type will be zero.
+	// @see BuildCastFunction4Union
+	InUnion bool
+	// Implicit indicates whether the cast is built for the argument of a function,
+	// which checks the zero date of the argument itself.
+	// @see WrapWithCastAsTime
+	Implicit bool
 }
 
-// inUnionCastContext is session key value that indicates whether executing in
-// union cast context.
-// @see BuildCastFunction4Union
-const inUnionCastContext inCastContext = 0
+// IsInUnionCastContext checks whether expr is a cast function built for the `UNION` statement.
+// The other expressions, including the casts folded, are not in the union cast context.
+func IsInUnionCastContext(expr Expression) bool {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok {
+		return false
+	}
+	if f, ok := sf.Function.(interface{ getCastContext() CastContext }); ok {
+		return f.getCastContext().InUnion
+	}
+	return false
+}
 
 // CanImplicitEvalInt represents the builtin functions that have an implicit path to evaluate as integer,
 // regardless of the type that type inference decides it to be.
@@ -1795,25 +2203,201 @@ func CanImplicitEvalReal(expr Expression) bool {
 // BuildCastFunction4Union build a implicitly CAST ScalarFunction from the Union
 // Expression.
 func BuildCastFunction4Union(ctx sessionctx.Context, expr Expression, tp *types.FieldType) (res Expression) {
-	ctx.SetValue(inUnionCastContext, struct{}{})
-	defer func() {
-		ctx.SetValue(inUnionCastContext, nil)
-	}()
-	return BuildCastFunction(ctx, expr, tp)
+	return buildCastFunction(ctx, expr, tp, CastContext{InUnion: true})
 }
 
 // BuildCastFunction builds a CAST ScalarFunction from the Expression.
 func BuildCastFunction(ctx sessionctx.Context, expr Expression, tp *types.FieldType) (res Expression) {
+	return buildCastFunction(ctx, expr, tp, CastContext{})
+}
+
+// CanElideCast checks whether casting a value of type src to type dst is a no-op, so the cast can be removed.
+// It's only true for the values which are exactly of type src, e.g. the columns of base tables. The types
+// inferred for other expressions, including the output columns of projections, may be narrower than their values.
+func CanElideCast(src, dst *types.FieldType) bool {
+	return canElideCast(src, dst) && getCastTypeRule(src.Tp, dst.Tp) == nil
+}
+
+// canElideCast checks whether casting a value of type src to type dst is a no-op.
+// The flags which don't affect the value, like NotNullFlag, are ignored.
+func canElideCast(src, dst *types.FieldType) bool {
+	if src.Tp != dst.Tp || src.Flen != dst.Flen || src.Decimal != dst.Decimal ||
+		src.Charset != dst.Charset || src.Collate != dst.Collate {
+		return false
+	}
+	// The flags of JSON may be reset after the cast is built, see buildCastFunction.
+	if src.Hybrid() || src.EvalType() == types.ETJson {
+		return false
+	}
+	// The BinaryFlag of the non-string types, e.g. the columns, isn't always set.
+	valueFlags := uint(mysql.UnsignedFlag)
+	if src.EvalType() == types.ETString {
+		valueFlags |= mysql.BinaryFlag
+	}
+	return src.Flag&valueFlags == dst.Flag&valueFlags
+}
+
+// RewriteCastChain collapses the nested CASTs on a column, e.g. CAST(CAST(CAST(a AS DECIMAL) AS REAL) AS SIGNED),
+// into a single CAST from the column to the outermost type. It's only done if the result is unchanged, i.e.
+// every intermediate CAST is either a no-op, or holds the integer value of the column exactly and the outermost
+// type is an integer or a double. Otherwise, e.g. CAST(CAST(a AS DECIMAL(10,2)) AS CHAR), expr is returned as is.
+// The values of the column must be exactly of its type, see CanElideCast. The union cast context of the outermost
+// CAST is kept, in which the negative values are cast to the unsigned types as 0.
+func RewriteCastChain(expr Expression) Expression {
+	outer, ok := expr.(*ScalarFunction)
+	if !ok || outer.FuncName.L != ast.Cast {
+		return expr
+	}
+	// The intermediate types from the outermost to the innermost.
+	var mids []*types.FieldType
+	arg := outer.GetArgs()[0]
+	for {
+		f, ok := arg.(*ScalarFunction)
+		if !ok || f.FuncName.L != ast.Cast {
+			break
+		}
+		mids = append(mids, f.RetType)
+		arg = f.GetArgs()[0]
+	}
+	// Only the type of column is reliable, see CanElideCast.
+	col, ok := arg.(*Column)
+	if !ok || len(mids) == 0 {
+		return expr
+	}
+
+	srcTp, prev := col.RetType, col.RetType
+	digits := intTypeDigits(srcTp)
+	unsigned := mysql.HasUnsignedFlag(srcTp.Flag)
+	for i := len(mids) - 1; i >= 0; i-- {
+		tp := mids[i]
+		if getCastTypeRule(prev.Tp, tp.Tp) != nil {
+			return expr
+		}
+		if canElideCast(prev, tp) {
+			continue
+		}
+		if digits == 0 || !holdsIntExactly(tp, digits, unsigned) {
+			return expr
+		}
+		prev = tp
+	}
+	if getCastTypeRule(prev.Tp, outer.RetType.Tp) != nil {
+		return expr
+	}
+	if prev != srcTp {
+		// The intermediate value equals the column, but its type is different, so the outermost CAST
+		// behaves the same only if it's a cast to integer or double of the same signedness.
+		if mysql.HasUnsignedFlag(outer.RetType.Flag) != unsigned {
+			return expr
+		}
+		if outer.RetType.EvalType() != types.ETInt && outer.RetType.Tp != mysql.TypeDouble {
+			return expr
+		}
+	}
+	if IsInUnionCastContext(outer) {
+		return BuildCastFunction4Union(outer.GetCtx(), col, outer.RetType)
+	}
+	return BuildCastFunction(outer.GetCtx(), col, outer.RetType)
+}
+
+// intTypeDigits returns the maximum number of decimal digits of the values of the integer type tp,
+// 0 is returned if tp isn't an integer type.
+func intTypeDigits(tp *types.FieldType) int {
+	if tp.Hybrid() {
+		return 0
+	}
+	switch tp.Tp {
+	case mysql.TypeTiny:
+		return 3
+	case mysql.TypeYear:
+		return 4
+	case mysql.TypeShort:
+		return 5
+	case mysql.TypeInt24:
+		return 8
+	case mysql.TypeLong:
+		return 10
+	case mysql.TypeLonglong:
+		return 20
+	}
+	return 0
+}
+
+// holdsIntExactly checks whether every integer of at most digits decimal digits is cast as the same value of type tp.
+func holdsIntExactly(tp *types.FieldType, digits int, unsigned bool) bool {
+	if mysql.HasUnsignedFlag(tp.Flag) != unsigned || tp.Hybrid() {
+		return false
+	}
+	switch tp.Tp {
+	case mysql.TypeLonglong:
+		return true
+	case mysql.TypeNewDecimal:
+		return tp.Flen != types.UnspecifiedLength && tp.Decimal != types.UnspecifiedLength && tp.Flen-tp.Decimal >= digits
+	case mysql.TypeDouble:
+		// The integers below 2^53 are represented exactly.
+		return digits <= 15
+	}
+	return false
+}
+
+// TypeInferFn infers the return type of casting a value of type argTp to the type tp.
+// It must not modify argTp. The returned type is used as the return type of the cast,
+// it's ignored if it has a different eval type from tp.
+type TypeInferFn func(argTp, tp *types.FieldType) *types.FieldType
+
+type castTypeRules map[[2]byte]TypeInferFn
+
+// typeInferenceRules is the registry of the cast type inference rules, keyed by the source and target type.
+// The rules are registered by plugins to make their custom types participate in the cast machinery.
+// The registry is copied on write, so the lookups on building casts are lock free.
+var typeInferenceRules struct {
+	sync.Mutex
+	rules atomic.Value // castTypeRules
+}
+
+func init() {
+	typeInferenceRules.rules.Store(make(castTypeRules))
+}
+
+// RegisterCastTypeRule registers inferFn to infer the return type of casting a value of type fromType
+// to the type toType, the former rule of the types is replaced. A nil inferFn unregisters the rule.
+func RegisterCastTypeRule(fromType, toType byte, inferFn TypeInferFn) {
+	typeInferenceRules.Lock()
+	defer typeInferenceRules.Unlock()
+	old := typeInferenceRules.rules.Load().(castTypeRules)
+	rules := make(castTypeRules, len(old)+1)
+	for k, fn := range old {
+		rules[k] = fn
+	}
+	if inferFn == nil {
+		delete(rules, [2]byte{fromType, toType})
+	} else {
+		rules[[2]byte{fromType, toType}] = inferFn
+	}
+	typeInferenceRules.rules.Store(rules)
+}
+
+func getCastTypeRule(fromType, toType byte) TypeInferFn {
+	return typeInferenceRules.rules.Load().(castTypeRules)[[2]byte{fromType, toType}]
+}
+
+func buildCastFunction(ctx sessionctx.Context, expr Expression, tp *types.FieldType, castCtx CastContext) (res Expression) {
+	// The registered rule is consulted first, it can't change the eval type of the cast.
+	if inferFn := getCastTypeRule(expr.GetType().Tp, tp.Tp); inferFn != nil {
+		if inferredTp := inferFn(expr.GetType(), tp); inferredTp != nil && inferredTp.EvalType() == tp.EvalType() {
+			tp = inferredTp
+		}
+	}
 	var fc functionClass
 	switch tp.EvalType() {
 	case types.ETInt:
-		fc = &castAsIntFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+		fc = &castAsIntFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, castCtx}
 	case types.ETDecimal:
-		fc = &castAsDecimalFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+		fc = &castAsDecimalFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, castCtx}
 	case types.ETReal:
-		fc = &castAsRealFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+		fc = &castAsRealFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, castCtx}
 	case types.ETDatetime, types.ETTimestamp:
-		fc = &castAsTimeFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
+		fc = &castAsTimeFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp, castCtx}
 	case types.ETDuration:
 		fc = &castAsDurationFunctionClass{baseFunctionClass{ast.Cast, 1, 1}, tp}
 	case types.ETJson:
@@ -1823,6 +2407,10 @@ func BuildCastFunction(ctx sessionctx.Context, expr Expression, tp *types.FieldT
 	}
 	f, err := fc.getFunction(ctx, []Expression{expr})
 	terror.Log(err)
+	if f != nil && tp.EvalType() == types.ETReal {
+		// castAsRealFunctionClass may infer the display width on a copy of tp.
+		tp = f.getRetTp()
+	}
 	res = &ScalarFunction{
 		FuncName: model.NewCIStr(ast.Cast),
 		RetType:  tp,
@@ -1837,6 +2425,87 @@ func BuildCastFunction(ctx sessionctx.Context, expr Expression, tp *types.FieldT
 	return res
 }
 
+// EvalContext is the minimal context needed to evaluate cast expressions. It's used by
+// the external callers which have no sessionctx.Context, like binlog appliers and CDC consumers.
+type EvalContext interface {
+	// GetSessionVars returns the session variables used by the evaluation.
+	GetSessionVars() *variable.SessionVars
+	// GetTimeZone returns the time zone used by the evaluation, nil means the time zone of the session variables.
+	GetTimeZone() *time.Location
+}
+
+// evalContextAdapter is the sessionctx.Context a cast expression is bound to by BindCastExprWithCtx.
+// The session variables are derived from an EvalContext, the other methods are served by the
+// context the expression was built with.
+type evalContextAdapter struct {
+	sessionctx.Context
+	vars *variable.SessionVars
+}
+
+// GetSessionVars implements the sessionctx.Context interface.
+func (a *evalContextAdapter) GetSessionVars() *variable.SessionVars {
+	return a.vars
+}
+
+// deriveSessionVars derives the session variables used to evaluate casts from the ones of ctx.
+// They have their own statement context and time zone, so ctx is never modified by the evaluation.
+func deriveSessionVars(ctx EvalContext) *variable.SessionVars {
+	origin := ctx.GetSessionVars()
+	tz := ctx.GetTimeZone()
+	if tz == nil {
+		tz = origin.Location()
+	}
+	vars := variable.NewSessionVars()
+	vars.SQLMode = origin.SQLMode
+	vars.TimeZone = tz
+	vars.CastOverflowAsError = origin.CastOverflowAsError
+	vars.TimestampCastFormat = origin.TimestampCastFormat
+	vars.JSONObjectAsDuration = origin.JSONObjectAsDuration
+	for _, name := range []string{variable.CharacterSetConnection, variable.CollationConnection, variable.MaxAllowedPacket} {
+		if val, ok := origin.GetSystemVar(name); ok {
+			terror.Log(vars.SetSystemVar(name, val))
+		}
+	}
+	sc := origin.StmtCtx
+	vars.StmtCtx = &stmtctx.StatementContext{
+		InInsertStmt:           sc.InInsertStmt,
+		InUpdateStmt:           sc.InUpdateStmt,
+		InDeleteStmt:           sc.InDeleteStmt,
+		InSelectStmt:           sc.InSelectStmt,
+		IgnoreTruncate:         sc.IgnoreTruncate,
+		IgnoreZeroInDate:       sc.IgnoreZeroInDate,
+		DividedByZeroAsWarning: sc.DividedByZeroAsWarning,
+		TruncateAsWarning:      sc.TruncateAsWarning,
+		OverflowAsWarning:      sc.OverflowAsWarning,
+		AllowInvalidDate:       sc.AllowInvalidDate,
+		TimeZone:               tz,
+	}
+	return vars
+}
+
+// BindCastExprWithCtx binds the cast expression `expr` to a context derived from ctx, the result
+// can be evaluated on many rows. The derived context has its own statement context and time zone,
+// the warnings of the evaluation are appended to `GetCtx().GetSessionVars().StmtCtx` of the result.
+// The cast signatures are kept, including their cast context.
+func BindCastExprWithCtx(ctx EvalContext, expr Expression) (Expression, error) {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok || sf.FuncName.L != ast.Cast {
+		return nil, errors.Errorf("%s is not a cast expression", expr)
+	}
+	return sf.CloneWithNewContext(&evalContextAdapter{Context: sf.GetCtx(), vars: deriveSessionVars(ctx)}), nil
+}
+
+// EvalCastExprWithCtx evaluates the cast expression `expr` on `row` with ctx.
+// It binds `expr` on every call, the callers evaluating many rows should bind it once
+// with BindCastExprWithCtx and evaluate the result instead.
+func EvalCastExprWithCtx(ctx EvalContext, expr Expression, row chunk.Row) (types.Datum, error) {
+	bound, err := BindCastExprWithCtx(ctx, expr)
+	if err != nil {
+		return types.Datum{}, err
+	}
+	return bound.Eval(row)
+}
+
 // WrapWithCastAsInt wraps `expr` with `cast` if the return type of expr is not
 // type int, otherwise, returns `expr` directly.
 func WrapWithCastAsInt(ctx sessionctx.Context, expr Expression) Expression {
@@ -1858,6 +2527,22 @@ func WrapWithCastAsInt(ctx sessionctx.Context, expr Expression) Expression {
 	return BuildCastFunction(ctx, expr, tp)
 }
 
+// WrapWithCastAsYear wraps `expr` with `cast` if the return type of expr is not
+// type year, otherwise, returns `expr` directly. The value is adjusted by the range
+// of YEAR like types.AdjustYear, and the value out of the range is truncated to NULL.
+func WrapWithCastAsYear(ctx sessionctx.Context, expr Expression) Expression {
+	if expr.GetType().Tp == mysql.TypeYear {
+		return expr
+	}
+	// The range check of YEAR is done on the integer value.
+	expr = WrapWithCastAsInt(ctx, expr)
+	tp := types.NewFieldType(mysql.TypeYear)
+	tp.Flen, tp.Decimal = 4, 0
+	types.SetBinChsClnFlag(tp)
+	tp.Flag |= mysql.UnsignedFlag | mysql.ZerofillFlag
+	return BuildCastFunction(ctx, expr, tp)
+}
+
 // WrapWithCastAsReal wraps `expr` with `cast` if the return type of expr is not
 // type real, otherwise, returns `expr` directly.
 func WrapWithCastAsReal(ctx sessionctx.Context, expr Expression) Expression {
@@ -1882,6 +2567,25 @@ func WrapWithCastAsDecimal(ctx sessionctx.Context, expr Expression) Expression {
 	if expr.GetType().EvalType() == types.ETInt {
 		tp.Flen = mysql.MaxIntWidth
 	}
+	// The precision inferred from the argument may exceed the limit of DECIMAL,
+	// cap it to the limit and append a warning if the argument is a constant.
+	// The precision of a real is not trusted, its value is converted as is.
+	if expr.GetType().EvalType() != types.ETReal {
+		sc := ctx.GetSessionVars().StmtCtx
+		isConst := expr.ConstItem(sc)
+		if tp.Flen > mysql.MaxDecimalWidth {
+			if isConst {
+				sc.AppendWarning(types.ErrTooBigPrecision.GenWithStackByArgs(tp.Flen, ast.Cast, mysql.MaxDecimalWidth))
+			}
+			tp.Flen = mysql.MaxDecimalWidth
+		}
+		if tp.Decimal > mysql.MaxDecimalScale && tp.Decimal != mysql.NotFixedDec {
+			if isConst {
+				sc.AppendWarning(types.ErrTooBigScale.GenWithStackByArgs(tp.Decimal, ast.Cast, mysql.MaxDecimalScale))
+			}
+			tp.Decimal = mysql.MaxDecimalScale
+		}
+	}
 	types.SetBinChsClnFlag(tp)
 	tp.Flag |= expr.GetType().Flag & mysql.UnsignedFlag
 	return BuildCastFunction(ctx, expr, tp)
@@ -1910,12 +2614,40 @@ func WrapWithCastAsString(ctx sessionctx.Context, expr Expression) Expression {
 		argLen = -1
 	}
 	tp := types.NewFieldType(mysql.TypeVarString)
+	switch expr.Coercibility() {
+	case CoercibilityExplicit:
+		tp.Charset, tp.Collate = expr.CharsetAndCollation(ctx)
+	case CoercibilityImplicit:
+		// Like MySQL, the expression of the implicit coercibility keeps its own collation
+		// unless it's binary, which means the value is not a string.
+		if chs, coll := expr.CharsetAndCollation(ctx); chs != "" && chs != charset.CharsetBin {
+			tp.Charset, tp.Collate = chs, coll
+		} else {
+			tp.Charset, tp.Collate = ctx.GetSessionVars().GetCharsetInfo()
+		}
+	default:
+		tp.Charset, tp.Collate = ctx.GetSessionVars().GetCharsetInfo()
+	}
+	tp.Flen, tp.Decimal = argLen, types.UnspecifiedLength
+	return BuildCastFunction(ctx, expr, tp)
+}
+
+// WrapWithCastAsEnum wraps `expr` with `cast` as the ENUM type of the members enumValues,
+// the value is checked against the members, which is skipped by the cast as string.
+func WrapWithCastAsEnum(ctx sessionctx.Context, expr Expression, enumValues []string) Expression {
+	tp := types.NewFieldType(mysql.TypeEnum)
 	if expr.Coercibility() == CoercibilityExplicit {
 		tp.Charset, tp.Collate = expr.CharsetAndCollation(ctx)
 	} else {
 		tp.Charset, tp.Collate = ctx.GetSessionVars().GetCharsetInfo()
 	}
-	tp.Flen, tp.Decimal = argLen, types.UnspecifiedLength
+	tp.Elems = enumValues
+	tp.Decimal = types.UnspecifiedLength
+	for _, elem := range enumValues {
+		if l := len([]rune(elem)); l > tp.Flen {
+			tp.Flen = l
+		}
+	}
 	return BuildCastFunction(ctx, expr, tp)
 }
 
@@ -1944,7 +2676,7 @@ func WrapWithCastAsTime(ctx sessionctx.Context, expr Expression, tp *types.Field
 		}
 	}
 	types.SetBinChsClnFlag(tp)
-	return BuildCastFunction(ctx, expr, tp)
+	return buildCastFunction(ctx, expr, tp, CastContext{Implicit: true})
 }
 
 // WrapWithCastAsDuration wraps `expr` with `cast` if the return type of expr is
@@ -1970,8 +2702,18 @@ func WrapWithCastAsDuration(ctx sessionctx.Context, expr Expression) Expression
 // WrapWithCastAsJSON wraps `expr` with `cast` if the return type of expr is not
 // type json, otherwise, returns `expr` directly.
 func WrapWithCastAsJSON(ctx sessionctx.Context, expr Expression) Expression {
-	if expr.GetType().Tp == mysql.TypeJSON && !mysql.HasParseToJSONFlag(expr.GetType().Flag) {
-		return expr
+	if expr.GetType().Tp == mysql.TypeJSON {
+		if !mysql.HasParseToJSONFlag(expr.GetType().Flag) {
+			return expr
+		}
+		// A prior `cast json as json` ignores the ParseToJSONFlag, the result of it is
+		// already a JSON. It's not the case for `cast string as json`, whose flag may be
+		// disabled by the caller afterwards, e.g. the compare functions.
+		if sf, ok := expr.(*ScalarFunction); ok {
+			if _, ok := sf.Function.(*builtinCastJSONAsJSONSig); ok {
+				return expr
+			}
+		}
 	}
 	tp := &types.FieldType{
 		Tp:      mysql.TypeJSON,
@@ -15,10 +15,14 @@ package expression
 
 import (
 	"math/rand"
+	"runtime"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
 	"github.com/pingcap/tidb/util/chunk"
 	"github.com/pingcap/tidb/util/mock"
 )
@@ -61,3 +65,246 @@ func BenchmarkCastIntAsIntVec(b *testing.B) {
 		}
 	}
 }
+
+func genCastTimeAsString() (*builtinCastTimeAsStringSig, *chunk.Chunk, *chunk.Column) {
+	tp := types.NewFieldType(mysql.TypeDatetime)
+	tp.Decimal = 6
+	col := &Column{RetType: tp, Index: 0}
+	baseFunc, err := newBaseBuiltinFunc(mock.NewContext(), "", []Expression{col}, 0)
+	if err != nil {
+		panic(err)
+	}
+	baseFunc.tp = types.NewFieldType(mysql.TypeVarString)
+	cast := &builtinCastTimeAsStringSig{baseFunc}
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, 1024)
+	for i := 0; i < 1024; i++ {
+		coreTime := types.FromDate(1970+rand.Intn(100), 1+rand.Intn(12), 1+rand.Intn(28), rand.Intn(24), rand.Intn(60), rand.Intn(60), rand.Intn(1000000))
+		input.AppendTime(0, types.NewTime(coreTime, mysql.TypeDatetime, 6))
+	}
+	result := chunk.NewColumn(types.NewFieldType(mysql.TypeVarString), 1024)
+	return cast, input, result
+}
+
+func genCastJSONAsString() (*builtinCastJSONAsStringSig, *chunk.Chunk, *chunk.Column) {
+	tp := types.NewFieldType(mysql.TypeJSON)
+	col := &Column{RetType: tp, Index: 0}
+	baseFunc, err := newBaseBuiltinFunc(mock.NewContext(), "", []Expression{col}, 0)
+	if err != nil {
+		panic(err)
+	}
+	baseFunc.tp = types.NewFieldType(mysql.TypeVarString)
+	cast := &builtinCastJSONAsStringSig{baseFunc}
+	const rows = 10000
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, rows)
+	for i := 0; i < rows; i++ {
+		j := json.CreateBinary(map[string]interface{}{"a": int64(i), "b": "c"})
+		input.AppendJSON(0, j)
+	}
+	result := chunk.NewColumn(types.NewFieldType(mysql.TypeVarString), rows)
+	return cast, input, result
+}
+
+func BenchmarkCastJSONAsStringRow(b *testing.B) {
+	cast, input, _ := genCastJSONAsString()
+	it := chunk.NewIterator4Chunk(input)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for row := it.Begin(); row != it.End(); row = it.Next() {
+			if _, _, err := cast.evalString(row); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCastJSONAsStringVec(b *testing.B) {
+	cast, input, result := genCastJSONAsString()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cast.vecEvalString(input, result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCastTimeAsStringRow(b *testing.B) {
+	cast, input, _ := genCastTimeAsString()
+	it := chunk.NewIterator4Chunk(input)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for row := it.Begin(); row != it.End(); row = it.Next() {
+			if _, _, err := cast.evalString(row); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkCastTimeAsStringVec(b *testing.B) {
+	cast, input, result := genCastTimeAsString()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cast.vecEvalString(input, result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// genBenchDecimal generates a random value of newBenchDecimalFieldType.
+func genBenchDecimal() *types.MyDecimal {
+	dec := types.NewDecFromInt(rand.Int63n(1e18) - 5e17)
+	if err := dec.Shift(-6); err != nil {
+		panic(err)
+	}
+	return dec
+}
+
+// newBenchDecimalFieldType returns the DECIMAL(18,6) type.
+func newBenchDecimalFieldType() *types.FieldType {
+	tp := types.NewFieldType(mysql.TypeNewDecimal)
+	tp.Flen, tp.Decimal = 18, 6
+	return tp
+}
+
+func genCastDecimalAsString() (*builtinCastDecimalAsStringSig, *chunk.Chunk, *chunk.Column) {
+	tp := newBenchDecimalFieldType()
+	col := &Column{RetType: tp, Index: 0}
+	baseFunc, err := newBaseBuiltinFunc(mock.NewContext(), "", []Expression{col}, 0)
+	if err != nil {
+		panic(err)
+	}
+	baseFunc.tp = types.NewFieldType(mysql.TypeVarString)
+	cast := &builtinCastDecimalAsStringSig{baseFunc}
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, 1024)
+	for i := 0; i < 1024; i++ {
+		input.AppendMyDecimal(0, genBenchDecimal())
+	}
+	result := chunk.NewColumn(types.NewFieldType(mysql.TypeVarString), 1024)
+	return cast, input, result
+}
+
+func genCastStringAsDecimalSig() (*builtinCastStringAsDecimalSig, *chunk.Chunk, *chunk.Column) {
+	tp := types.NewFieldType(mysql.TypeVarString)
+	col := &Column{RetType: tp, Index: 0}
+	baseFunc, err := newBaseBuiltinFunc(mock.NewContext(), "", []Expression{col}, 0)
+	if err != nil {
+		panic(err)
+	}
+	baseFunc.tp = newBenchDecimalFieldType()
+	cast := &builtinCastStringAsDecimalSig{newBaseBuiltinCastFunc(baseFunc, false)}
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, 1024)
+	for i := 0; i < 1024; i++ {
+		input.AppendString(0, genBenchDecimal().String())
+	}
+	result := chunk.NewColumn(newBenchDecimalFieldType(), 1024)
+	return cast, input, result
+}
+
+// benchmarkCastVecPerRow runs the vectorized cast on rows rows per op, and reports the time and allocations per row.
+func benchmarkCastVecPerRow(b *testing.B, rows int, vecEval func() error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := vecEval(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(elapsed.Nanoseconds())/float64(b.N*rows), "ns/row")
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*rows), "allocs/row")
+}
+
+func BenchmarkCastDecimalAsString(b *testing.B) {
+	cast, input, result := genCastDecimalAsString()
+	benchmarkCastVecPerRow(b, input.NumRows(), func() error {
+		return cast.vecEvalString(input, result)
+	})
+}
+
+func BenchmarkCastStringAsDecimal(b *testing.B) {
+	cast, input, result := genCastStringAsDecimalSig()
+	benchmarkCastVecPerRow(b, input.NumRows(), func() error {
+		return cast.vecEvalDecimal(input, result)
+	})
+}
+
+func genCastStringAsBinary() (*builtinCastStringAsStringSig, *chunk.Chunk, *chunk.Column) {
+	const rows = 1 << 20
+	tp := types.NewFieldType(mysql.TypeVarString)
+	col := &Column{RetType: tp, Index: 0}
+	baseFunc, err := newBaseBuiltinFunc(mock.NewContext(), "", []Expression{col}, 0)
+	if err != nil {
+		panic(err)
+	}
+	binaryTp := types.NewFieldType(mysql.TypeString)
+	binaryTp.Flen = 64
+	types.SetBinChsClnFlag(binaryTp)
+	baseFunc.tp = binaryTp
+	cast := &builtinCastStringAsStringSig{baseFunc}
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, rows)
+	for i := 0; i < rows; i++ {
+		input.AppendString(0, "abcd")
+	}
+	result := chunk.NewColumn(binaryTp, rows)
+	return cast, input, result
+}
+
+func BenchmarkCastStringAsBinaryVec(b *testing.B) {
+	cast, input, result := genCastStringAsBinary()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cast.vecEvalString(input, result); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func genCastStringAsInt(strs []string) (*builtinCastStringAsIntSig, *chunk.Chunk, *chunk.Column) {
+	tp := types.NewFieldType(mysql.TypeVarString)
+	col := &Column{RetType: tp, Index: 0}
+	baseFunc, err := newBaseBuiltinFunc(mock.NewContext(), "", []Expression{col}, 0)
+	if err != nil {
+		panic(err)
+	}
+	baseFunc.tp = types.NewFieldType(mysql.TypeLonglong)
+	cast := &builtinCastStringAsIntSig{newBaseBuiltinCastFunc(baseFunc, false)}
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, len(strs))
+	for _, s := range strs {
+		input.AppendString(0, s)
+	}
+	result := chunk.NewColumn(types.NewFieldType(mysql.TypeLonglong), len(strs))
+	return cast, input, result
+}
+
+func BenchmarkCastStringAsIntSequential(b *testing.B) {
+	strs := make([]string, 1000000)
+	for i := range strs {
+		strs[i] = strconv.Itoa(i + 1)
+	}
+	cast, input, result := genCastStringAsInt(strs)
+	benchmarkCastVecPerRow(b, input.NumRows(), func() error {
+		return cast.vecEvalInt(input, result)
+	})
+}
+
+func BenchmarkCastStringAsIntRandom(b *testing.B) {
+	strs := make([]string, 1024)
+	for i := range strs {
+		// The 18-digit numbers.
+		strs[i] = strconv.FormatInt(rand.Int63n(9e17)+1e17, 10)
+	}
+	cast, input, result := genCastStringAsInt(strs)
+	benchmarkCastVecPerRow(b, input.NumRows(), func() error {
+		return cast.vecEvalInt(input, result)
+	})
+}
@@ -17,16 +17,21 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"sync"
 	"time"
 
 	. "github.com/pingcap/check"
+	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/charset"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/sessionctx/variable"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/types/json"
 	"github.com/pingcap/tidb/util/chunk"
 	"github.com/pingcap/tidb/util/mock"
+	"github.com/pingcap/tipb/go-tipb"
 )
 
 func (s *testEvaluatorSuite) TestCastXXX(c *C) {
@@ -817,13 +822,13 @@ func (s *testEvaluatorSuite) TestCastFuncSig(c *C) {
 		timeFunc.tp = tp
 		switch i {
 		case 0:
-			sig = &builtinCastRealAsTimeSig{timeFunc}
+			sig = &builtinCastRealAsTimeSig{baseBuiltinFunc: timeFunc}
 		case 1:
 			sig = &builtinCastDecimalAsTimeSig{timeFunc}
 		case 2:
-			sig = &builtinCastIntAsTimeSig{timeFunc}
+			sig = &builtinCastIntAsTimeSig{baseBuiltinFunc: timeFunc}
 		case 3:
-			sig = &builtinCastStringAsTimeSig{timeFunc}
+			sig = &builtinCastStringAsTimeSig{baseBuiltinFunc: timeFunc}
 		case 4:
 			sig = &builtinCastDurationAsTimeSig{timeFunc}
 		case 5:
@@ -902,13 +907,13 @@ func (s *testEvaluatorSuite) TestCastFuncSig(c *C) {
 		timeFunc.tp = tp
 		switch i {
 		case 0:
-			sig = &builtinCastRealAsTimeSig{timeFunc}
+			sig = &builtinCastRealAsTimeSig{baseBuiltinFunc: timeFunc}
 		case 1:
 			sig = &builtinCastDecimalAsTimeSig{timeFunc}
 		case 2:
-			sig = &builtinCastIntAsTimeSig{timeFunc}
+			sig = &builtinCastIntAsTimeSig{baseBuiltinFunc: timeFunc}
 		case 3:
-			sig = &builtinCastStringAsTimeSig{timeFunc}
+			sig = &builtinCastStringAsTimeSig{baseBuiltinFunc: timeFunc}
 		case 4:
 			sig = &builtinCastDurationAsTimeSig{timeFunc}
 		case 5:
@@ -1389,6 +1394,381 @@ func (s *testEvaluatorSuite) TestWrapWithCastAsJSON(c *C) {
 	output, ok := expr.(*Column)
 	c.Assert(ok, IsTrue)
 	c.Assert(output, Equals, input)
+
+	jsonTp := func() *types.FieldType {
+		return &types.FieldType{Tp: mysql.TypeJSON, Flen: 12582912, Charset: mysql.DefaultCharset, Collate: mysql.DefaultCollationName, Flag: mysql.BinaryFlag}
+	}
+	// CAST(json_col AS JSON) should not be wrapped again.
+	castJSON := BuildCastFunction(s.ctx, input, jsonTp())
+	c.Assert(WrapWithCastAsJSON(s.ctx, castJSON), Equals, castJSON)
+
+	// CAST(json_col AS JSON) with the ParseToJSONFlag is not wrapped either, the flag is ignored.
+	flagTp := jsonTp()
+	flagTp.Flag |= mysql.ParseToJSONFlag
+	castJSON = BuildCastFunction(s.ctx, input, flagTp)
+	c.Assert(WrapWithCastAsJSON(s.ctx, castJSON), Equals, castJSON)
+
+	// CAST(str_col AS JSON) is wrapped, so its ParseToJSONFlag is kept if the caller disables
+	// the flag of the wrapper.
+	strCol := &Column{RetType: types.NewFieldType(mysql.TypeVarString), Index: 0}
+	castStr := BuildCastFunction(s.ctx, strCol, jsonTp())
+	c.Assert(mysql.HasParseToJSONFlag(castStr.GetType().Flag), IsTrue)
+	expr = WrapWithCastAsJSON(s.ctx, castStr)
+	sf, ok := expr.(*ScalarFunction)
+	c.Assert(ok, IsTrue)
+	c.Assert(sf.GetArgs()[0], Equals, castStr)
+	DisableParseJSONFlag4Expr(expr)
+	c.Assert(mysql.HasParseToJSONFlag(castStr.GetType().Flag), IsTrue)
+}
+
+func (s *testEvaluatorSuite) TestCastAsEnumCloneWithNewContext(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	originTruncateAsWarning := sc.TruncateAsWarning
+	defer func() {
+		sc.TruncateAsWarning = originTruncateAsWarning
+		sc.SetWarnings(nil)
+	}()
+	sc.TruncateAsWarning = true
+	sc.SetWarnings(nil)
+	// The truncation is an error in the statements under the strict sql_mode.
+	strictCtx := mock.NewContext()
+	strictCtx.GetSessionVars().SQLMode = mysql.ModeStrictAllTables
+	strictCtx.GetSessionVars().StrictSQLMode = true
+	strictCtx.GetSessionVars().StmtCtx.TruncateAsWarning = false
+
+	// The inner cast as string is bound to the new context as well.
+	strCol := &Column{RetType: types.NewFieldTypeWithCollation(mysql.TypeVarString, mysql.DefaultCollationName, 10), Index: 0}
+	cast := WrapWithCastAsEnum(s.ctx, strCol, []string{"a", "bb"}).CloneWithNewContext(strictCtx)
+	sig := cast.(*ScalarFunction).Function.(*builtinCastAsEnumSig)
+	c.Assert(sig.getCtx(), Equals, strictCtx)
+	c.Assert(sig.strCast.getCtx(), Equals, strictCtx)
+	_, _, err := cast.EvalString(strictCtx, chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum("c")}).ToRow())
+	c.Assert(types.ErrTruncated.Equal(err), IsTrue, Commentf("%v", err))
+	c.Assert(sc.WarningCount(), Equals, uint16(0))
+}
+
+func (s *testEvaluatorSuite) TestCanElideCast(c *C) {
+	newTp := func(tp byte, flen, decimal int, flag uint) *types.FieldType {
+		ft := types.NewFieldType(tp)
+		ft.Flen, ft.Decimal, ft.Flag = flen, decimal, flag
+		types.SetBinChsClnFlag(ft)
+		return ft
+	}
+	enumTp := types.NewFieldType(mysql.TypeEnum)
+	enumTp.Elems = []string{"a", "b"}
+	// The decimal columns are created without the BinaryFlag.
+	decimalCol := newTp(mysql.TypeNewDecimal, 10, 2, 0)
+	decimalCol.Flag = 0
+	binStrTp := newTp(mysql.TypeVarString, 10, 0, 0)
+	strTp := binStrTp.Clone()
+	strTp.Flag = 0
+	cases := []struct {
+		src    *types.FieldType
+		dst    *types.FieldType
+		elided bool
+	}{
+		{newTp(mysql.TypeNewDecimal, 10, 2, 0), newTp(mysql.TypeNewDecimal, 10, 2, 0), true},
+		{newTp(mysql.TypeNewDecimal, 10, 2, mysql.NotNullFlag), newTp(mysql.TypeNewDecimal, 10, 2, 0), true},
+		{newTp(mysql.TypeNewDecimal, 10, 2, 0), newTp(mysql.TypeNewDecimal, 10, 3, 0), false},
+		{newTp(mysql.TypeNewDecimal, 10, 2, 0), newTp(mysql.TypeNewDecimal, 12, 2, 0), false},
+		{decimalCol, newTp(mysql.TypeNewDecimal, 10, 2, 0), true},
+		{strTp, binStrTp, false},
+		{newTp(mysql.TypeLonglong, 20, 0, 0), newTp(mysql.TypeLonglong, 20, 0, 0), true},
+		{newTp(mysql.TypeLonglong, 20, 0, mysql.UnsignedFlag), newTp(mysql.TypeLonglong, 20, 0, 0), false},
+		{newTp(mysql.TypeLonglong, 20, 0, 0), newTp(mysql.TypeLong, 20, 0, 0), false},
+		{newTp(mysql.TypeJSON, 0, 0, 0), newTp(mysql.TypeJSON, 0, 0, 0), false},
+		{enumTp, enumTp.Clone(), false},
+	}
+	for i, t := range cases {
+		c.Assert(CanElideCast(t.src, t.dst), Equals, t.elided, Commentf("case %d", i))
+		// BuildCastFunction never elides the cast, it's up to the caller who knows where the values come from.
+		col := &Column{RetType: t.src, Index: 0}
+		res := BuildCastFunction(s.ctx, col, t.dst)
+		c.Assert(res.(*ScalarFunction).FuncName.L, Equals, ast.Cast, Commentf("case %d", i))
+	}
+}
+
+func (s *testEvaluatorSuite) TestRegisterCastTypeRule(c *C) {
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	col.RetType.Flen = mysql.MaxIntWidth
+	types.SetBinChsClnFlag(col.RetType)
+
+	RegisterCastTypeRule(mysql.TypeLonglong, mysql.TypeVarString, func(argTp, tp *types.FieldType) *types.FieldType {
+		c.Assert(argTp, Equals, col.RetType)
+		inferred := tp.Clone()
+		inferred.Flen = 5
+		return inferred
+	})
+	defer RegisterCastTypeRule(mysql.TypeLonglong, mysql.TypeVarString, nil)
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	strTp.Charset, strTp.Collate = mysql.DefaultCharset, mysql.DefaultCollationName
+	cast := BuildCastFunction(s.ctx, col, strTp)
+	c.Assert(cast.GetType().Flen, Equals, 5)
+	c.Assert(strTp.Flen, Equals, types.UnspecifiedLength)
+
+	// The rule can't change the eval type, and the cast with a rule can't be elided.
+	RegisterCastTypeRule(mysql.TypeLonglong, mysql.TypeLonglong, func(_, tp *types.FieldType) *types.FieldType {
+		return types.NewFieldType(mysql.TypeDouble)
+	})
+	cast = BuildCastFunction(s.ctx, col, col.RetType.Clone())
+	c.Assert(cast.GetType().EvalType(), Equals, types.ETInt)
+	c.Assert(cast.(*ScalarFunction).Function.PbCode(), Equals, tipb.ScalarFuncSig_CastIntAsInt)
+	c.Assert(CanElideCast(col.RetType, col.RetType.Clone()), IsFalse)
+
+	RegisterCastTypeRule(mysql.TypeLonglong, mysql.TypeLonglong, nil)
+	c.Assert(CanElideCast(col.RetType, col.RetType.Clone()), IsTrue)
+}
+
+func (s *testEvaluatorSuite) TestIsInUnionCastContext(c *C) {
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	unsignedTp := types.NewFieldType(mysql.TypeLonglong)
+	unsignedTp.Flag |= mysql.UnsignedFlag
+
+	union := BuildCastFunction4Union(s.ctx, col, unsignedTp)
+	c.Assert(IsInUnionCastContext(union), IsTrue)
+	c.Assert(IsInUnionCastContext(union.Clone()), IsTrue)
+	c.Assert(IsInUnionCastContext(BuildCastFunction(s.ctx, col, unsignedTp)), IsFalse)
+	c.Assert(IsInUnionCastContext(col), IsFalse)
+	c.Assert(IsInUnionCastContext(newFunction(ast.Plus, col, NewOne())), IsFalse)
+	// The cast of a constant is folded.
+	c.Assert(IsInUnionCastContext(BuildCastFunction4Union(s.ctx, NewOne(), unsignedTp)), IsFalse)
+}
+
+// cdcEvalContext is an EvalContext used by a CDC consumer, which has no sessionctx.Context.
+type cdcEvalContext struct {
+	vars *variable.SessionVars
+	tz   *time.Location
+}
+
+func (ctx *cdcEvalContext) GetSessionVars() *variable.SessionVars {
+	return ctx.vars
+}
+
+func (ctx *cdcEvalContext) GetTimeZone() *time.Location {
+	return ctx.tz
+}
+
+func (s *testEvaluatorSuite) TestEvalCastExprWithCtx(c *C) {
+	// The cast expressions are built when the plan is stored.
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	decTp := types.NewFieldType(mysql.TypeNewDecimal)
+	decTp.Flen, decTp.Decimal = 10, 2
+	dtTp := types.NewFieldType(mysql.TypeDatetime)
+	dtTp.Decimal = 0
+	castAsDatetime := BuildCastFunction(s.ctx, &Column{RetType: strTp, Index: 0}, dtTp)
+	castAsDecimal := BuildCastFunction(s.ctx, &Column{RetType: strTp, Index: 1}, decTp)
+	castAsString := BuildCastFunction(s.ctx, castAsDecimal, types.NewFieldType(mysql.TypeVarString))
+
+	// The CDC consumer decodes a row event and evaluates the stored casts on it.
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	c.Assert(err, IsNil)
+	ctx := &cdcEvalContext{vars: variable.NewSessionVars(), tz: loc}
+	originTZ, originStmtCtx := ctx.vars.TimeZone, ctx.vars.StmtCtx
+	row := chunk.MutRowFromDatums(types.MakeDatums("2021-05-01 12:00:00", "42.5")).ToRow()
+
+	d, err := EvalCastExprWithCtx(ctx, castAsDatetime, row)
+	c.Assert(err, IsNil)
+	c.Assert(d.GetMysqlTime().String(), Equals, "2021-05-01 12:00:00")
+	d, err = EvalCastExprWithCtx(ctx, castAsDecimal, row)
+	c.Assert(err, IsNil)
+	c.Assert(d.GetMysqlDecimal().String(), Equals, "42.50")
+	d, err = EvalCastExprWithCtx(ctx, castAsString, row)
+	c.Assert(err, IsNil)
+	c.Assert(d.GetString(), Equals, "42.50")
+	// The session variables of the CDC consumer are never modified.
+	c.Assert(ctx.vars.TimeZone, Equals, originTZ)
+	c.Assert(ctx.vars.StmtCtx, Equals, originStmtCtx)
+
+	// The bound expression has its own statement context and time zone.
+	bound, err := BindCastExprWithCtx(ctx, castAsDecimal)
+	c.Assert(err, IsNil)
+	vars := bound.(*ScalarFunction).GetCtx().GetSessionVars()
+	c.Assert(vars, Not(Equals), ctx.vars)
+	c.Assert(vars.StmtCtx, Not(Equals), ctx.vars.StmtCtx)
+	c.Assert(vars.TimeZone, Equals, loc)
+	c.Assert(vars.StmtCtx.TimeZone, Equals, loc)
+	c.Assert(castAsDecimal.(*ScalarFunction).GetCtx(), Equals, s.ctx)
+
+	// The truncation is handled by the statement context flags of the CDC consumer.
+	row = chunk.MutRowFromDatums(types.MakeDatums("2021-05-01 12:00:00", "abc")).ToRow()
+	_, err = bound.Eval(row)
+	c.Assert(types.ErrBadNumber.Equal(err), IsTrue)
+	ctx.vars.StmtCtx.TruncateAsWarning = true
+	bound, err = BindCastExprWithCtx(ctx, castAsDecimal)
+	c.Assert(err, IsNil)
+	for i := 0; i < 2; i++ {
+		d, err = bound.Eval(row)
+		c.Assert(err, IsNil)
+		c.Assert(d.GetMysqlDecimal().String(), Equals, "0.00")
+	}
+	c.Assert(bound.(*ScalarFunction).GetCtx().GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(2))
+	c.Assert(ctx.vars.StmtCtx.WarningCount(), Equals, uint16(0))
+
+	// The cast context of the signature is kept.
+	unionCast := BuildCastFunction4Union(s.ctx, &Column{RetType: strTp, Index: 1}, decTp)
+	bound, err = BindCastExprWithCtx(ctx, unionCast)
+	c.Assert(err, IsNil)
+	c.Assert(IsInUnionCastContext(bound), IsTrue)
+
+	_, err = EvalCastExprWithCtx(ctx, &Column{RetType: strTp, Index: 0}, row)
+	c.Assert(err, NotNil)
+}
+
+// This example shows how a CDC consumer evaluates the cast expressions of a stored plan on the row events.
+func ExampleEvalCastExprWithCtx() {
+	// The cast expressions are built when the plan is stored.
+	decTp := types.NewFieldType(mysql.TypeNewDecimal)
+	decTp.Flen, decTp.Decimal = 10, 2
+	castAsDecimal := BuildCastFunction(mock.NewContext(), &Column{RetType: types.NewFieldType(mysql.TypeVarString), Index: 1}, decTp)
+
+	// The consumer has no session, only the session variables and the time zone of the upstream.
+	ctx := &cdcEvalContext{vars: variable.NewSessionVars(), tz: time.UTC}
+	ctx.vars.StmtCtx.TruncateAsWarning = true
+
+	// A single row is evaluated with EvalCastExprWithCtx.
+	event := types.MakeDatums(int64(1), "42.5")
+	d, err := EvalCastExprWithCtx(ctx, castAsDecimal, chunk.MutRowFromDatums(event).ToRow())
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(d.GetMysqlDecimal())
+
+	// The cast is bound once for the rows of a batch.
+	bound, err := BindCastExprWithCtx(ctx, castAsDecimal)
+	if err != nil {
+		panic(err)
+	}
+	for _, event := range [][]types.Datum{types.MakeDatums(int64(2), "1.5"), types.MakeDatums(int64(3), "abc")} {
+		d, err := bound.Eval(chunk.MutRowFromDatums(event).ToRow())
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(d.GetMysqlDecimal())
+	}
+	fmt.Println(bound.(*ScalarFunction).GetCtx().GetSessionVars().StmtCtx.WarningCount())
+	// Output:
+	// 42.50
+	// 1.50
+	// 0.00
+	// 1
+}
+
+func (s *testEvaluatorSuite) TestWrapWithCastAsYear(c *C) {
+	yearCol := &Column{RetType: types.NewFieldType(mysql.TypeYear), Index: 0}
+	c.Assert(WrapWithCastAsYear(s.ctx, yearCol), Equals, yearCol)
+
+	intCol := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	expr := WrapWithCastAsYear(s.ctx, intCol)
+	tp := expr.GetType()
+	c.Assert(tp.Tp, Equals, mysql.TypeYear)
+	c.Assert(tp.Flen, Equals, 4)
+	c.Assert(tp.Decimal, Equals, 0)
+	sc := s.ctx.GetSessionVars().StmtCtx
+	for _, t := range []struct {
+		val    int64
+		expect int64
+		isNull bool
+	}{
+		{1901, 1901, false},
+		{2155, 2155, false},
+		{0, 0, false},
+		{50, 2050, false},
+		{99, 1999, false},
+		{3000, 0, true},
+		{-5, 0, true},
+		{1900, 0, true},
+	} {
+		sc.SetWarnings(nil)
+		res, isNull, err := expr.EvalInt(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewIntDatum(t.val)}).ToRow())
+		c.Assert(err, IsNil)
+		c.Assert(isNull, Equals, t.isNull, Commentf("%d", t.val))
+		if t.isNull {
+			warnings := sc.GetWarnings()
+			c.Assert(warnings, HasLen, 1, Commentf("%d", t.val))
+			c.Assert(types.ErrTruncatedWrongVal.Equal(warnings[0].Err), IsTrue, Commentf("%d", t.val))
+			continue
+		}
+		c.Assert(sc.WarningCount(), Equals, uint16(0), Commentf("%d", t.val))
+		c.Assert(res, Equals, t.expect, Commentf("%d", t.val))
+	}
+
+	// The vectorized evaluation gives the same results.
+	input := chunk.NewChunkWithCapacity([]*types.FieldType{types.NewFieldType(mysql.TypeLonglong)}, 4)
+	for _, val := range []int64{2001, 50, 3000, -5} {
+		input.AppendInt64(0, val)
+	}
+	result := chunk.NewColumn(types.NewFieldType(mysql.TypeLonglong), 4)
+	c.Assert(expr.VecEvalInt(s.ctx, input, result), IsNil)
+	c.Assert(result.GetInt64(0), Equals, int64(2001))
+	c.Assert(result.GetInt64(1), Equals, int64(2050))
+	c.Assert(result.IsNull(2), IsTrue)
+	c.Assert(result.IsNull(3), IsTrue)
+
+	// The other types are converted to integer first.
+	realCol := &Column{RetType: types.NewFieldType(mysql.TypeDouble), Index: 0}
+	expr = WrapWithCastAsYear(s.ctx, realCol)
+	c.Assert(expr.GetType().Tp, Equals, mysql.TypeYear)
+	res, isNull, err := expr.EvalInt(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewFloat64Datum(2020.2)}).ToRow())
+	c.Assert(err, IsNil)
+	c.Assert(isNull, IsFalse)
+	c.Assert(res, Equals, int64(2020))
+}
+
+func (s *testEvaluatorSuite) TestCastAsRealUnspecifiedFlen(c *C) {
+	col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	for _, t := range []struct {
+		tp      byte
+		decimal int
+		flen    int
+	}{
+		{mysql.TypeFloat, types.UnspecifiedLength, 15},
+		{mysql.TypeFloat, 3, 12},
+		{mysql.TypeDouble, types.UnspecifiedLength, 23},
+		{mysql.TypeDouble, 3, 20},
+	} {
+		tp := types.NewFieldType(t.tp)
+		tp.Flen, tp.Decimal = types.UnspecifiedLength, t.decimal
+		cast := BuildCastFunction(s.ctx, col, tp)
+		c.Assert(cast.GetType().Flen, Equals, t.flen)
+		c.Assert(cast.GetType().Decimal, Equals, t.decimal)
+		c.Assert(cast.(*ScalarFunction).Function.getRetTp(), Equals, cast.GetType())
+		// The width is inferred on a copy, tp may be shared by others.
+		c.Assert(tp.Flen, Equals, types.UnspecifiedLength)
+	}
+
+	// The width given by the parser is kept.
+	tp := types.NewFieldType(mysql.TypeFloat)
+	tp.Flen, tp.Decimal = mysql.GetDefaultFieldLengthAndDecimalForCast(mysql.TypeFloat)
+	c.Assert(BuildCastFunction(s.ctx, col, tp).GetType().Flen, Equals, 12)
+}
+
+func (s *testEvaluatorSuite) TestWrapWithCastAsDecimalCapPrecision(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	sc.SetWarnings(nil)
+
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	strTp.Flen, strTp.Decimal = 100, 40
+	con := &Constant{Value: types.NewStringDatum("0.5"), RetType: strTp}
+	expr := WrapWithCastAsDecimal(s.ctx, con)
+	c.Assert(expr.GetType().Flen, Equals, mysql.MaxDecimalWidth)
+	c.Assert(expr.GetType().Decimal, Equals, mysql.MaxDecimalScale)
+	c.Assert(sc.WarningCount(), Equals, uint16(2))
+
+	sc.SetWarnings(nil)
+	col := &Column{RetType: strTp, Index: 0}
+	expr = WrapWithCastAsDecimal(s.ctx, col)
+	c.Assert(expr.GetType().Flen, Equals, mysql.MaxDecimalWidth)
+	c.Assert(expr.GetType().Decimal, Equals, mysql.MaxDecimalScale)
+	c.Assert(sc.WarningCount(), Equals, uint16(0))
+
+	// The precision of a real isn't trusted, it's not capped.
+	realTp := types.NewFieldType(mysql.TypeDouble)
+	realTp.Flen, realTp.Decimal = 84, 82
+	con = &Constant{Value: types.NewFloat64Datum(0.5), RetType: realTp}
+	expr = WrapWithCastAsDecimal(s.ctx, con)
+	c.Assert(expr.GetType().Flen, Equals, 84)
+	c.Assert(expr.GetType().Decimal, Equals, 82)
+	c.Assert(sc.WarningCount(), Equals, uint16(0))
 }
 
 func (s *testEvaluatorSuite) TestCastIntAsIntVec(c *C) {
@@ -1404,7 +1784,7 @@ func (s *testEvaluatorSuite) TestCastIntAsIntVec(c *C) {
 		i++
 	}
 
-	cast.inUnion = true
+	cast.InUnion = true
 	cast.getRetTp().Flag |= mysql.UnsignedFlag
 	c.Assert(cast.vecEvalInt(input, result), IsNil)
 	i64s = result.Int64s()
@@ -1453,3 +1833,504 @@ func (s *testEvaluatorSuite) TestCastStringAsDecimalSigWithUnsignedFlagInUnion(c
 		c.Assert(res.Compare(t.res), Equals, 0)
 	}
 }
+
+func (s *testEvaluatorSuite) TestPadZeroForBinaryType(c *C) {
+	tp := types.NewFieldType(mysql.TypeString)
+	tp.Flen = 8
+	types.SetBinChsClnFlag(tp)
+	// The pooled buffer used by the previous padding must be zeroed.
+	for _, t := range []struct {
+		val    string
+		expect string
+	}{
+		{"abcdefg", "abcdefg\x00"},
+		{"abc", "abc\x00\x00\x00\x00\x00"},
+		{"", "\x00\x00\x00\x00\x00\x00\x00\x00"},
+		{"abcdefghi", "abcdefghi"},
+	} {
+		res, isNull, err := padZeroForBinaryType(t.val, tp, s.ctx)
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, Equals, t.expect)
+	}
+}
+
+func (s *testEvaluatorSuite) TestWrapWithCastAsStringCollation(c *C) {
+	sessionChs, sessionColl := s.ctx.GetSessionVars().GetCharsetInfo()
+	newCol := func(coer Coercibility, chs, coll string) *Column {
+		col := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+		col.SetCoercibility(coer)
+		col.SetCharsetAndCollation(chs, coll)
+		return col
+	}
+	for _, t := range []struct {
+		col          *Column
+		chs, collate string
+	}{
+		{newCol(CoercibilityExplicit, "latin1", "latin1_bin"), "latin1", "latin1_bin"},
+		// The implicit expression keeps its collation unless it's binary.
+		{newCol(CoercibilityImplicit, "latin1", "latin1_bin"), "latin1", "latin1_bin"},
+		{newCol(CoercibilityImplicit, charset.CharsetBin, charset.CollationBin), sessionChs, sessionColl},
+		{newCol(CoercibilityNumeric, "latin1", "latin1_bin"), sessionChs, sessionColl},
+	} {
+		tp := WrapWithCastAsString(s.ctx, t.col).GetType()
+		c.Assert(tp.Charset, Equals, t.chs)
+		c.Assert(tp.Collate, Equals, t.collate)
+	}
+}
+
+func (s *testEvaluatorSuite) TestWrapWithCastAsEnum(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	originTruncateAsWarning := sc.TruncateAsWarning
+	defer func() {
+		sc.TruncateAsWarning = originTruncateAsWarning
+		sc.SetWarnings(nil)
+	}()
+	elems := []string{"a", "bb", "ccc"}
+
+	strCol := &Column{RetType: types.NewFieldTypeWithCollation(mysql.TypeVarString, mysql.DefaultCollationName, 10), Index: 0}
+	cast := WrapWithCastAsEnum(s.ctx, strCol, elems)
+	c.Assert(cast.GetType().Tp, Equals, mysql.TypeEnum)
+	c.Assert(cast.GetType().Elems, DeepEquals, elems)
+	c.Assert(cast.GetType().Flen, Equals, 3)
+	_, ok := cast.(*ScalarFunction).Function.(*builtinCastAsEnumSig)
+	c.Assert(ok, IsTrue)
+
+	// The members are matched by the name or the index.
+	sc.TruncateAsWarning = true
+	for _, t := range []struct {
+		val    string
+		expect string
+	}{
+		{"bb", "bb"},
+		{"3", "ccc"},
+	} {
+		res, isNull, err := cast.EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum(t.val)}).ToRow())
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, Equals, t.expect)
+	}
+	c.Assert(sc.WarningCount(), Equals, uint16(0))
+	intCol := &Column{RetType: types.NewFieldType(mysql.TypeLonglong), Index: 0}
+	res, _, err := WrapWithCastAsEnum(s.ctx, intCol, elems).EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewIntDatum(1)}).ToRow())
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, "a")
+
+	// The invalid value is truncated to the empty string with a warning.
+	for _, val := range []string{"d", "4", "", "ax", "zzzz"} {
+		res, isNull, err := cast.EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum(val)}).ToRow())
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, Equals, "")
+		warnings := sc.GetWarnings()
+		c.Assert(types.ErrTruncated.Equal(warnings[len(warnings)-1].Err), IsTrue, Commentf("%v", warnings[len(warnings)-1].Err))
+	}
+	c.Assert(sc.WarningCount(), Equals, uint16(5))
+	d, err := cast.Eval(chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum("ax")}).ToRow())
+	c.Assert(err, IsNil)
+	c.Assert(d.GetMysqlEnum(), Equals, types.Enum{})
+	_, isNull, err := cast.EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewDatum(nil)}).ToRow())
+	c.Assert(err, IsNil)
+	c.Assert(isNull, IsTrue)
+
+	// It's an error if the truncation is not allowed, like the INSERT in the strict mode.
+	sc.TruncateAsWarning = false
+	_, _, err = cast.EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum("d")}).ToRow())
+	c.Assert(types.ErrTruncated.Equal(err), IsTrue, Commentf("%v", err))
+}
+
+func (s *testEvaluatorSuite) TestPadZeroForBinaryTypeWithInvalidMaxAllowedPacket(c *C) {
+	ctx := mock.NewContext()
+	c.Assert(ctx.GetSessionVars().SetSystemVar(variable.MaxAllowedPacket, "invalid"), IsNil)
+
+	col := &Column{RetType: types.NewFieldType(mysql.TypeString), Index: 0}
+	bf, err := newBaseBuiltinFunc(ctx, "", []Expression{col}, 0)
+	c.Assert(err, IsNil)
+	bf.tp = types.NewFieldType(mysql.TypeString)
+	bf.tp.Flen = 10
+	types.SetBinChsClnFlag(bf.tp)
+	cast := &builtinCastStringAsStringSig{bf}
+
+	res, isNull, err := cast.evalString(chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum("a")}).ToRow())
+	c.Assert(isNull, IsTrue)
+	c.Assert(err, ErrorMatches, ".*invalid syntax.*")
+	c.Assert(res, Equals, "")
+
+	// The padding is not needed when the length of string is not less than the flen.
+	res, isNull, err = cast.evalString(chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum("0123456789")}).ToRow())
+	c.Assert(err, IsNil)
+	c.Assert(isNull, IsFalse)
+	c.Assert(res, Equals, "0123456789")
+}
+
+func (s *testEvaluatorSuite) TestBuildCastFunction4UnionConcurrently(c *C) {
+	ctx := mock.NewContext()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(inUnion bool) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				col := &Column{RetType: types.NewFieldType(mysql.TypeString), Index: 0}
+				tp := types.NewFieldType(mysql.TypeLonglong)
+				var cast Expression
+				if inUnion {
+					cast = BuildCastFunction4Union(ctx, col, tp)
+				} else {
+					cast = BuildCastFunction(ctx, col, tp)
+				}
+				sig, ok := cast.(*ScalarFunction).Function.(*builtinCastStringAsIntSig)
+				c.Check(ok, IsTrue)
+				if ok {
+					c.Check(sig.InUnion, Equals, inUnion)
+				}
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
+
+func (s *testEvaluatorSuite) TestCastStringAsTimeWithExcessFrac(c *C) {
+	ctx := mock.NewContext()
+	sc := ctx.GetSessionVars().StmtCtx
+	tp := types.NewFieldType(mysql.TypeDatetime)
+	tp.Decimal = int(types.MaxFsp)
+	col := &Column{RetType: types.NewFieldType(mysql.TypeString), Index: 0}
+	cast := BuildCastFunction(ctx, col, tp)
+
+	for _, t := range []struct {
+		str    string
+		expect string
+	}{
+		{"2024-01-01 12:00:00.1234567", "2024-01-01 12:00:00.123456"},
+		{"2024-01-01 12:00:00.12345678", "2024-01-01 12:00:00.123456"},
+		{"2024-01-01 12:00:00.123456789", "2024-01-01 12:00:00.123456"},
+		{"2024-01-01 12:00:00.9999995", "2024-01-01 12:00:00.999999"},
+	} {
+		sc.SetWarnings(nil)
+		res, isNull, err := cast.EvalTime(ctx, chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum(t.str)}).ToRow())
+		c.Assert(err, IsNil, Commentf("%s", t.str))
+		c.Assert(isNull, IsFalse, Commentf("%s", t.str))
+		c.Assert(res.String(), Equals, t.expect, Commentf("%s", t.str))
+		warnings := sc.GetWarnings()
+		c.Assert(warnings, HasLen, 1, Commentf("%s", t.str))
+		c.Assert(types.ErrTruncatedWrongVal.Equal(warnings[0].Err), IsTrue, Commentf("%s", t.str))
+	}
+
+	// No warning for at most 6 fractional digits.
+	sc.SetWarnings(nil)
+	res, isNull, err := cast.EvalTime(ctx, chunk.MutRowFromDatums([]types.Datum{types.NewStringDatum("2024-01-01 12:00:00.123456")}).ToRow())
+	c.Assert(err, IsNil)
+	c.Assert(isNull, IsFalse)
+	c.Assert(res.String(), Equals, "2024-01-01 12:00:00.123456")
+	c.Assert(sc.WarningCount(), Equals, uint16(0))
+}
+
+func (s *testEvaluatorSuite) TestCastRealAsStringFormat(c *C) {
+	col := &Column{RetType: types.NewFieldType(mysql.TypeDouble), Index: 0}
+	cast := BuildCastFunction(s.ctx, col, types.NewFieldType(mysql.TypeVarString))
+	cases := []struct {
+		val    float64
+		expect string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "-0"},
+		{1, "1"},
+		{-1, "-1"},
+		{0.1, "0.1"},
+		{0.5, "0.5"},
+		{123456789.123456789, "123456789.12345679"},
+		{999999999999999, "999999999999999"},
+		{-999999999999999, "-999999999999999"},
+		{1e15, "1e15"},
+		{1.23e15, "1.23e15"},
+		{-1.23e15, "-1.23e15"},
+		{1e16, "1e16"},
+		{1.5e20, "1.5e20"},
+		{1e22, "1e22"},
+		{9007199254740993, "9.007199254740992e15"},
+		{math.MaxFloat64, "1.7976931348623157e308"},
+		{-math.MaxFloat64, "-1.7976931348623157e308"},
+		{1e-5, "0.00001"},
+		{1.5e-7, "0.00000015"},
+		{1.23e-10, "0.000000000123"},
+		{-2.5e-8, "-0.000000025"},
+		{1e-15, "0.000000000000001"},
+		{9.9e-16, "9.9e-16"},
+		{1.23e-20, "1.23e-20"},
+		{math.SmallestNonzeroFloat64, "5e-324"},
+	}
+	for _, t := range cases {
+		res, isNull, err := cast.EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewFloat64Datum(t.val)}).ToRow())
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, Equals, t.expect, Commentf("%v", t.val))
+	}
+
+	// FLOAT values are formatted with 32 bits.
+	col = &Column{RetType: types.NewFieldType(mysql.TypeFloat), Index: 0}
+	cast = BuildCastFunction(s.ctx, col, types.NewFieldType(mysql.TypeVarString))
+	for _, t := range []struct {
+		val    float32
+		expect string
+	}{
+		{208.867, "208.867"},
+		{1e15, "1e15"},
+		{3.4e38, "3.4e38"},
+		{1.4e-45, "1e-45"},
+	} {
+		res, isNull, err := cast.EvalString(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewFloat32Datum(t.val)}).ToRow())
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, Equals, t.expect, Commentf("%v", t.val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestCastRealAsIntNaNAndInf(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	oldOverflowAsWarning := sc.OverflowAsWarning
+	sc.OverflowAsWarning = true
+	defer func() {
+		sc.OverflowAsWarning = oldOverflowAsWarning
+	}()
+
+	vals := []float64{math.NaN(), math.Inf(1), math.Inf(-1)}
+	col := &Column{RetType: types.NewFieldType(mysql.TypeDouble), Index: 0}
+	for _, t := range []struct {
+		flag   uint
+		expect []int64
+	}{
+		{0, []int64{0, math.MaxInt64, math.MinInt64}},
+		// -1 is MaxUint64 as int64.
+		{mysql.UnsignedFlag, []int64{0, -1, 0}},
+	} {
+		tp := types.NewFieldType(mysql.TypeLonglong)
+		tp.Flag |= t.flag
+		cast := BuildCastFunction(s.ctx, col, tp)
+
+		sc.SetWarnings(nil)
+		for i, val := range vals {
+			res, isNull, err := cast.EvalInt(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewFloat64Datum(val)}).ToRow())
+			c.Assert(err, IsNil)
+			c.Assert(isNull, IsFalse)
+			c.Assert(res, Equals, t.expect[i], Commentf("%v", val))
+		}
+		c.Assert(sc.WarningCount(), Equals, uint16(len(vals)))
+		for _, warn := range sc.GetWarnings() {
+			c.Assert(types.ErrOverflow.Equal(warn.Err), IsTrue)
+		}
+
+		input := chunk.NewChunkWithCapacity([]*types.FieldType{col.RetType}, len(vals))
+		for _, val := range vals {
+			input.AppendFloat64(0, val)
+		}
+		result := chunk.NewColumn(tp, len(vals))
+		c.Assert(cast.VecEvalInt(s.ctx, input, result), IsNil)
+		c.Assert(result.Int64s(), DeepEquals, t.expect)
+	}
+
+	// The overflow is returned as an error if it isn't treated as a warning.
+	sc.OverflowAsWarning = false
+	cast := BuildCastFunction(s.ctx, col, types.NewFieldType(mysql.TypeLonglong))
+	_, _, err := cast.EvalInt(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewFloat64Datum(math.NaN())}).ToRow())
+	c.Assert(types.ErrOverflow.Equal(err), IsTrue)
+}
+
+func (s *testEvaluatorSuite) TestCastTimeAsTimeUnspecifiedFsp(c *C) {
+	srcTp := types.NewFieldType(mysql.TypeTimestamp)
+	srcTp.Decimal = 6
+	col := &Column{RetType: srcTp, Index: 0}
+	src, err := types.ParseTime(s.ctx.GetSessionVars().StmtCtx, "2021-03-04 05:06:07.123456", mysql.TypeTimestamp, 6)
+	c.Assert(err, IsNil)
+	for _, t := range []struct {
+		decimal int
+		expect  string
+	}{
+		// The precision of the source is kept if the fsp of the target is unspecified.
+		{types.UnspecifiedLength, "2021-03-04 05:06:07.123456"},
+		{3, "2021-03-04 05:06:07.123"},
+		{0, "2021-03-04 05:06:07"},
+	} {
+		tp := types.NewFieldType(mysql.TypeDatetime)
+		tp.Decimal = t.decimal
+		cast := BuildCastFunction(s.ctx, col, tp)
+
+		res, isNull, err := cast.EvalTime(s.ctx, chunk.MutRowFromDatums([]types.Datum{types.NewTimeDatum(src)}).ToRow())
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res.Type(), Equals, mysql.TypeDatetime)
+		c.Assert(res.String(), Equals, t.expect)
+
+		input := chunk.NewChunkWithCapacity([]*types.FieldType{srcTp}, 1)
+		input.AppendTime(0, src)
+		result := chunk.NewColumn(tp, 1)
+		c.Assert(cast.VecEvalTime(s.ctx, input, result), IsNil)
+		c.Assert(result.GetTime(0).String(), Equals, t.expect)
+	}
+}
+
+func (s *testEvaluatorSuite) TestCastRealAsDecimalTruncated(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	col := &Column{RetType: types.NewFieldType(mysql.TypeDouble), Index: 0}
+	tp := types.NewFieldType(mysql.TypeNewDecimal)
+	tp.Flen, tp.Decimal = types.UnspecifiedLength, types.UnspecifiedLength
+	cast := BuildCastFunction(s.ctx, col, tp)
+	for _, t := range []struct {
+		val       float64
+		truncated bool
+	}{
+		{1.5, false},
+		{0.25, false},
+		{0.1, false},
+		// All the digits are shifted out of the decimal.
+		{1e-100, true},
+		{-1e-100, true},
+	} {
+		row := chunk.MutRowFromDatums([]types.Datum{types.NewFloat64Datum(t.val)}).ToRow()
+		sc.SetWarnings(nil)
+		_, isNull, err := cast.EvalDecimal(s.ctx, row)
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(len(sc.GetWarnings()) == 1, Equals, t.truncated, Commentf("%v", t.val))
+		if t.truncated {
+			c.Assert(types.ErrTruncatedWrongVal.Equal(sc.GetWarnings()[0].Err), IsTrue)
+		}
+
+		input := chunk.NewChunkWithCapacity([]*types.FieldType{col.RetType}, 1)
+		input.AppendFloat64(0, t.val)
+		result := chunk.NewColumn(tp, 1)
+		sc.SetWarnings(nil)
+		c.Assert(cast.VecEvalDecimal(s.ctx, input, result), IsNil)
+		c.Assert(len(sc.GetWarnings()) == 1, Equals, t.truncated, Commentf("%v", t.val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestCastNegativeZeroDecimalAsReal(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	defer sc.SetWarnings(nil)
+	tp := types.NewFieldType(mysql.TypeNewDecimal)
+	tp.Flen, tp.Decimal = 10, 2
+	col := &Column{RetType: tp, Index: 0}
+	cast := BuildCastFunction(s.ctx, col, types.NewFieldType(mysql.TypeDouble))
+
+	// Parsing "-0.00" yields the positive zero, so decode the negative zero from
+	// its binary form, which is the positive zero with all the bits inverted.
+	bin, err := types.NewDecFromStringForTest("0.00").ToBin(tp.Flen, tp.Decimal)
+	c.Assert(err, IsNil)
+	for i := range bin {
+		bin[i] ^= 0xff
+	}
+	negZero := new(types.MyDecimal)
+	_, err = negZero.FromBin(bin, tp.Flen, tp.Decimal)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		val    *types.MyDecimal
+		expect float64
+		note   bool
+	}{
+		{negZero, 0, true},
+		{types.NewDecFromStringForTest("0.00"), 0, false},
+		{types.NewDecFromStringForTest("-1.50"), -1.5, false},
+	} {
+		dec := t.val
+		row := chunk.MutRowFromDatums([]types.Datum{types.NewDecimalDatum(dec)}).ToRow()
+		sc.SetWarnings(nil)
+		res, isNull, err := cast.EvalReal(s.ctx, row)
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, Equals, t.expect)
+		c.Assert(math.Signbit(res), Equals, t.expect < 0, Commentf("%v", t.val))
+		c.Assert(len(sc.GetWarnings()) == 1, Equals, t.note, Commentf("%v", t.val))
+		if t.note {
+			c.Assert(sc.GetWarnings()[0].Level, Equals, stmtctx.WarnLevelNote)
+			c.Assert(types.ErrTruncatedWrongVal.Equal(sc.GetWarnings()[0].Err), IsTrue)
+		}
+
+		input := chunk.NewChunkWithCapacity([]*types.FieldType{tp}, 1)
+		input.AppendMyDecimal(0, dec)
+		result := chunk.NewColumn(types.NewFieldType(mysql.TypeDouble), 1)
+		sc.SetWarnings(nil)
+		c.Assert(cast.VecEvalReal(s.ctx, input, result), IsNil)
+		c.Assert(result.GetFloat64(0), Equals, t.expect)
+		c.Assert(math.Signbit(result.GetFloat64(0)), Equals, t.expect < 0, Commentf("%v", t.val))
+		c.Assert(len(sc.GetWarnings()) == 1, Equals, t.note, Commentf("%v", t.val))
+	}
+}
+
+func (s *testEvaluatorSuite) TestRewriteCastChain(c *C) {
+	newTp := func(tp byte, flen, decimal int, flag uint) *types.FieldType {
+		ft := types.NewFieldType(tp)
+		ft.Flen, ft.Decimal, ft.Flag = flen, decimal, flag
+		if types.IsString(tp) {
+			ft.Charset, ft.Collate = mysql.DefaultCharset, mysql.DefaultCollationName
+		} else {
+			types.SetBinChsClnFlag(ft)
+		}
+		return ft
+	}
+	intTp := newTp(mysql.TypeLong, 11, 0, 0)
+	bigintTp := newTp(mysql.TypeLonglong, 20, 0, 0)
+	decimalTp := newTp(mysql.TypeNewDecimal, 10, 0, 0)
+	doubleTp := newTp(mysql.TypeDouble, types.UnspecifiedLength, types.UnspecifiedLength, 0)
+	unsignedTp := newTp(mysql.TypeLonglong, 20, 0, mysql.UnsignedFlag)
+	charTp := newTp(mysql.TypeVarString, types.UnspecifiedLength, types.UnspecifiedLength, 0)
+	for _, t := range []struct {
+		colTp     *types.FieldType
+		chain     []*types.FieldType
+		collapsed bool
+	}{
+		{intTp, []*types.FieldType{decimalTp, doubleTp, bigintTp}, true},
+		{intTp, []*types.FieldType{bigintTp, doubleTp}, true},
+		// The BIGINT values aren't all represented exactly by double.
+		{bigintTp, []*types.FieldType{doubleTp, bigintTp}, false},
+		// The scale of the decimal changes the string.
+		{intTp, []*types.FieldType{newTp(mysql.TypeNewDecimal, 12, 2, 0), charTp}, false},
+		{intTp, []*types.FieldType{decimalTp, charTp}, false},
+		{intTp, []*types.FieldType{decimalTp, unsignedTp}, false},
+		{intTp, []*types.FieldType{newTp(mysql.TypeNewDecimal, 9, 0, 0), bigintTp}, false},
+		{charTp, []*types.FieldType{decimalTp, bigintTp}, false},
+		// The no-op CASTs are removed for any type.
+		{charTp, []*types.FieldType{charTp, decimalTp}, true},
+	} {
+		col := &Column{RetType: t.colTp, Index: 0}
+		var expr Expression = col
+		for _, tp := range t.chain {
+			expr = BuildCastFunction(s.ctx, expr, tp)
+		}
+		res := RewriteCastChain(expr)
+		if !t.collapsed {
+			c.Assert(res, Equals, expr)
+			continue
+		}
+		f, ok := res.(*ScalarFunction)
+		c.Assert(ok, IsTrue)
+		c.Assert(f.FuncName.L, Equals, ast.Cast)
+		c.Assert(f.GetArgs()[0], Equals, col)
+		c.Assert(canElideCast(f.RetType, expr.GetType()), IsTrue)
+	}
+
+	// The union cast context of the outermost CAST is kept, in which -1 is cast as unsigned 0.
+	col := &Column{RetType: intTp, Index: 0}
+	union := BuildCastFunction4Union(s.ctx, BuildCastFunction(s.ctx, col, intTp), unsignedTp)
+	collapsedUnion := RewriteCastChain(union)
+	c.Assert(collapsedUnion.(*ScalarFunction).GetArgs()[0], Equals, col)
+	c.Assert(IsInUnionCastContext(collapsedUnion), IsTrue)
+	row := chunk.MutRowFromDatums([]types.Datum{types.NewIntDatum(-1)}).ToRow()
+	res, _, err := collapsedUnion.EvalInt(s.ctx, row)
+	c.Assert(err, IsNil)
+	c.Assert(res, Equals, int64(0))
+
+	// The collapsed CAST returns the same values.
+	chain := BuildCastFunction(s.ctx, BuildCastFunction(s.ctx, BuildCastFunction(s.ctx, col, decimalTp), doubleTp), bigintTp)
+	collapsed := RewriteCastChain(chain)
+	for _, val := range []int64{0, 1, -1, math.MaxInt32, math.MinInt32} {
+		row := chunk.MutRowFromDatums([]types.Datum{types.NewIntDatum(val)}).ToRow()
+		expect, _, err := chain.EvalInt(s.ctx, row)
+		c.Assert(err, IsNil)
+		res, _, err := collapsed.EvalInt(s.ctx, row)
+		c.Assert(err, IsNil)
+		c.Assert(res, Equals, expect)
+		c.Assert(res, Equals, val)
+	}
+}
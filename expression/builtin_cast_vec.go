@@ -46,7 +46,7 @@ func (b *builtinCastIntAsDurationSig) vecEvalDuration(input *chunk.Chunk, result
 		dur, err := types.NumberToDuration(i64s[i], int8(b.tp.Decimal))
 		if err != nil {
 			if types.ErrOverflow.Equal(err) {
-				err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, err)
+				err = handleCastOverflow(b.ctx, err, err)
 			}
 			if types.ErrTruncatedWrongVal.Equal(err) {
 				err = b.ctx.GetSessionVars().StmtCtx.HandleTruncate(err)
@@ -70,7 +70,7 @@ func (b *builtinCastIntAsIntSig) vecEvalInt(input *chunk.Chunk, result *chunk.Co
 	if err := b.args[0].VecEvalInt(b.ctx, input, result); err != nil {
 		return err
 	}
-	if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) {
+	if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) {
 		i64s := result.Int64s()
 		// the null array of result is set by its child args[0],
 		// so we can skip it here to make this loop simpler to improve its performance.
@@ -87,6 +87,34 @@ func (b *builtinCastIntAsIntSig) vectorized() bool {
 	return true
 }
 
+func (b *builtinCastIntAsYearSig) vecEvalInt(input *chunk.Chunk, result *chunk.Column) error {
+	if err := b.args[0].VecEvalInt(b.ctx, input, result); err != nil {
+		return err
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	unsigned := mysql.HasUnsignedFlag(b.args[0].GetType().Flag)
+	i64s := result.Int64s()
+	for i := range i64s {
+		if result.IsNull(i) {
+			continue
+		}
+		year, isNull, err := castIntAsYear(sc, i64s[i], unsigned)
+		if err != nil {
+			return err
+		}
+		if isNull {
+			result.SetNull(i, true)
+			continue
+		}
+		i64s[i] = year
+	}
+	return nil
+}
+
+func (b *builtinCastIntAsYearSig) vectorized() bool {
+	return true
+}
+
 func (b *builtinCastIntAsRealSig) vecEvalReal(input *chunk.Chunk, result *chunk.Column) error {
 	n := input.NumRows()
 	buf, err := b.bufAllocator.get(types.ETInt, n)
@@ -113,11 +141,11 @@ func (b *builtinCastIntAsRealSig) vecEvalReal(input *chunk.Chunk, result *chunk.
 		}
 		if !hasUnsignedFlag0 && !hasUnsignedFlag1 {
 			rs[i] = float64(i64s[i])
-		} else if b.inUnion && !hasUnsignedFlag1 && i64s[i] < 0 {
+		} else if b.InUnion && !hasUnsignedFlag1 && i64s[i] < 0 {
 			// Round up to 0 if the value is negative but the expression eval type is unsigned in `UNION` statement
 			// NOTE: the following expressions are equal (so choose the more efficient one):
-			// `b.inUnion && hasUnsignedFlag0 && !hasUnsignedFlag1 && i64s[i] < 0`
-			// `b.inUnion && !hasUnsignedFlag1 && i64s[i] < 0`
+			// `b.InUnion && hasUnsignedFlag0 && !hasUnsignedFlag1 && i64s[i] < 0`
+			// `b.InUnion && !hasUnsignedFlag1 && i64s[i] < 0`
 			rs[i] = 0
 		} else {
 			// recall that, int to float is different from uint to float
@@ -137,7 +165,7 @@ func (b *builtinCastRealAsRealSig) vecEvalReal(input *chunk.Chunk, result *chunk
 	}
 	n := input.NumRows()
 	f64s := result.Float64s()
-	conditionUnionAndUnsigned := b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag)
+	conditionUnionAndUnsigned := b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag)
 	if !conditionUnionAndUnsigned {
 		return nil
 	}
@@ -203,14 +231,7 @@ func (b *builtinCastRealAsStringSig) vecEvalString(input *chunk.Chunk, result *c
 		return err
 	}
 
-	bits := 64
-	if b.args[0].GetType().Tp == mysql.TypeFloat {
-		// b.args[0].EvalReal() casts the value from float32 to float64, for example:
-		// float32(208.867) is cast to float64(208.86700439)
-		// If we strconv.FormatFloat the value with 64bits, the result is incorrect!
-		bits = 32
-	}
-
+	argTp := b.args[0].GetType().Tp
 	var isNull bool
 	var res string
 	f64s := buf.Float64s()
@@ -221,7 +242,7 @@ func (b *builtinCastRealAsStringSig) vecEvalString(input *chunk.Chunk, result *c
 			result.AppendNull()
 			continue
 		}
-		res, err = types.ProduceStrWithSpecifiedTp(strconv.FormatFloat(v, 'f', -1, bits), b.tp, sc, false)
+		res, err = types.ProduceStrWithSpecifiedTp(formatRealAsString(v, argTp), b.tp, sc, false)
 		if err != nil {
 			return err
 		}
@@ -377,13 +398,17 @@ func (b *builtinCastIntAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chunk.
 	stmt := b.ctx.GetSessionVars().StmtCtx
 	fsp := int8(b.tp.Decimal)
 
+	isYearType := b.args[0].GetType().Tp == mysql.TypeYear
 	var tm types.Time
 	for i := 0; i < n; i++ {
 		if buf.IsNull(i) {
 			continue
 		}
 
-		if b.args[0].GetType().Tp == mysql.TypeYear {
+		if isYearType && i64s[i] == 0 {
+			// YEAR 0000 is converted to the zero value of the target type, the same as MySQL.
+			tm, err = types.NewTime(types.ZeroCoreTime, b.tp.Tp, fsp), nil
+		} else if isYearType {
 			tm, err = types.ParseTimeFromYear(stmt, i64s[i])
 		} else {
 			tm, err = types.ParseTimeFromNum(stmt, i64s[i], b.tp.Tp, fsp)
@@ -396,6 +421,9 @@ func (b *builtinCastIntAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chunk.
 			result.SetNull(i, true)
 			continue
 		}
+		if err = handleZeroTimeError(b.ctx, tm, b.implicit); err != nil {
+			return err
+		}
 		times[i] = tm
 		if b.tp.Tp == mysql.TypeDate {
 			// Truncate hh:mm:ss part if the type is Date.
@@ -482,16 +510,11 @@ func (b *builtinCastJSONAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chunk
 	result.MergeNulls(buf)
 	times := result.Times()
 	stmtCtx := b.ctx.GetSessionVars().StmtCtx
-	fsp := int8(b.tp.Decimal)
 	for i := 0; i < n; i++ {
 		if result.IsNull(i) {
 			continue
 		}
-		s, err := buf.GetJSON(i).Unquote()
-		if err != nil {
-			return err
-		}
-		tm, err := types.ParseTime(stmtCtx, s, b.tp.Tp, fsp)
+		tm, err := types.ConvertJSONToTime(stmtCtx, buf.GetJSON(i), b.tp.Tp, b.tp.Decimal)
 		if err != nil {
 			if err = handleInvalidTimeError(b.ctx, err); err != nil {
 				return err
@@ -546,6 +569,9 @@ func (b *builtinCastRealAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chunk
 			result.SetNull(i, true)
 			continue
 		}
+		if err = handleZeroTimeError(b.ctx, tm, b.implicit); err != nil {
+			return err
+		}
 		times[i] = tm
 		if b.tp.Tp == mysql.TypeDate {
 			// Truncate hh:mm:ss part if the type is Date.
@@ -567,7 +593,7 @@ func (b *builtinCastDecimalAsDecimalSig) vecEvalDecimal(input *chunk.Chunk, resu
 	n := input.NumRows()
 	decs := result.Decimals()
 	sc := b.ctx.GetSessionVars().StmtCtx
-	conditionUnionAndUnsigned := b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag)
+	conditionUnionAndUnsigned := b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag)
 	dec := new(types.MyDecimal)
 	for i := 0; i < n; i++ {
 		if result.IsNull(i) {
@@ -709,9 +735,16 @@ func (b *builtinCastRealAsIntSig) vecEvalInt(input *chunk.Chunk, result *chunk.C
 			continue
 		}
 
+		var ok bool
+		if i64s[i], ok, err = b.convertNaNOrInf(f64s[i]); ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		if !unsigned {
 			i64s[i], err = types.ConvertFloatToInt(f64s[i], types.IntergerSignedLowerBound(mysql.TypeLonglong), types.IntergerSignedUpperBound(mysql.TypeLonglong), mysql.TypeLonglong)
-		} else if b.inUnion && f64s[i] < 0 {
+		} else if b.InUnion && f64s[i] < 0 {
 			i64s[i] = 0
 		} else {
 			var uintVal uint64
@@ -720,7 +753,7 @@ func (b *builtinCastRealAsIntSig) vecEvalInt(input *chunk.Chunk, result *chunk.C
 			i64s[i] = int64(uintVal)
 		}
 		if types.ErrOverflow.Equal(err) {
-			err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, err)
+			err = handleCastOverflow(b.ctx, err, err)
 		}
 		if err != nil {
 			return err
@@ -754,7 +787,7 @@ func (b *builtinCastTimeAsRealSig) vecEvalReal(input *chunk.Chunk, result *chunk
 		f64, err := times[i].ToNumber().ToFloat64()
 		if err != nil {
 			if types.ErrOverflow.Equal(err) {
-				err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, err)
+				err = handleCastOverflow(b.ctx, err, err)
 			}
 			if err != nil {
 				return err
@@ -791,7 +824,7 @@ func (b *builtinCastStringAsJSONSig) vecEvalJSON(input *chunk.Chunk, result *chu
 				result.AppendNull()
 				continue
 			}
-			res, err = json.ParseBinaryFromString(buf.GetString(i))
+			res, err = json.ParseBinaryFromBytes(buf.GetBytes(i))
 			if err != nil {
 				return err
 			}
@@ -831,13 +864,13 @@ func (b *builtinCastRealAsDecimalSig) vecEvalDecimal(input *chunk.Chunk, result
 		if result.IsNull(i) {
 			continue
 		}
-		if !b.inUnion || bufreal[i] >= 0 {
+		if !b.InUnion || bufreal[i] >= 0 {
 			if err = resdecimal[i].FromFloat64(bufreal[i]); err != nil {
 				if types.ErrOverflow.Equal(err) {
 					warnErr := types.ErrTruncatedWrongVal.GenWithStackByArgs("DECIMAL", b.args[0])
-					err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, warnErr)
+					err = handleCastOverflow(b.ctx, err, warnErr)
 				} else if types.ErrTruncated.Equal(err) {
-					// This behavior is consistent with MySQL.
+					handleCastRealAsDecimalTruncated(b.ctx, bufreal[i], &resdecimal[i])
 					err = nil
 				}
 				if err != nil {
@@ -882,7 +915,7 @@ func (b *builtinCastStringAsIntSig) vecEvalInt(input *chunk.Chunk, result *chunk
 	sc := b.ctx.GetSessionVars().StmtCtx
 	i64s := result.Int64s()
 	isUnsigned := mysql.HasUnsignedFlag(b.tp.Flag)
-	unionUnsigned := isUnsigned && b.inUnion
+	unionUnsigned := isUnsigned && b.InUnion
 	for i := 0; i < n; i++ {
 		if result.IsNull(i) {
 			continue
@@ -986,7 +1019,7 @@ func (b *builtinCastDurationAsDecimalSig) vecEvalDecimal(input *chunk.Chunk, res
 		}
 		duration.Duration = ds[i]
 		duration.Fsp = fsp
-		res, err := types.ProduceDecWithSpecifiedTp(duration.ToNumber(), b.tp, sc)
+		res, err := types.ProduceDecWithSpecifiedTp(duration.ToDecimalExact(), b.tp, sc)
 		if err != nil {
 			return err
 		}
@@ -1026,7 +1059,7 @@ func (b *builtinCastIntAsDecimalSig) vecEvalDecimal(input *chunk.Chunk, result *
 		*dec = types.MyDecimal{}
 		if !isUnsignedTp && !isUnsignedArgs0 {
 			dec.FromInt(nums[i])
-		} else if b.inUnion && !isUnsignedArgs0 && nums[i] < 0 {
+		} else if b.InUnion && !isUnsignedArgs0 && nums[i] < 0 {
 			dec.FromUint(0)
 		} else {
 			dec.FromUint(uint64(nums[i]))
@@ -1109,13 +1142,21 @@ func (b *builtinCastJSONAsStringSig) vecEvalString(input *chunk.Chunk, result *c
 		return err
 	}
 
+	// strBuf is taken from the pool and reused to format all the rows,
+	// the bytes are copied into result before the next row is formatted.
+	pooled := allocByteSlice(defaultByteSliceSize)
+	defer deallocateByteSlice(pooled)
+	strBuf := pooled[:0]
 	result.ReserveString(n)
 	for i := 0; i < n; i++ {
 		if buf.IsNull(i) {
 			result.AppendNull()
 			continue
 		}
-		result.AppendString(buf.GetJSON(i).String())
+		if strBuf, err = buf.GetJSON(i).AppendTo(strBuf[:0]); err != nil {
+			return err
+		}
+		result.AppendBytes(strBuf)
 	}
 	return nil
 }
@@ -1357,7 +1398,7 @@ func (b *builtinCastDecimalAsRealSig) vecEvalReal(input *chunk.Chunk, result *ch
 	d := buf.Decimals()
 	rs := result.Float64s()
 
-	inUnionAndUnsigned := b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag)
+	inUnionAndUnsigned := b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag)
 	for i := 0; i < n; i++ {
 		if result.IsNull(i) {
 			continue
@@ -1366,10 +1407,16 @@ func (b *builtinCastDecimalAsRealSig) vecEvalReal(input *chunk.Chunk, result *ch
 			rs[i] = 0
 			continue
 		}
+		if d[i].IsNegative() && d[i].IsZero() {
+			// Like MySQL, the negative zero of decimal is cast as the positive zero.
+			b.ctx.GetSessionVars().StmtCtx.AppendNote(types.ErrTruncatedWrongVal.GenWithStackByArgs("DOUBLE", d[i].String()))
+			rs[i] = 0
+			continue
+		}
 		res, err := d[i].ToFloat64()
 		if err != nil {
 			if types.ErrOverflow.Equal(err) {
-				err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, err)
+				err = handleCastOverflow(b.ctx, err, err)
 			}
 			if err != nil {
 				return err
@@ -1407,7 +1454,7 @@ func (b *builtinCastDecimalAsTimeSig) vecEvalTime(input *chunk.Chunk, result *ch
 		if buf.IsNull(i) {
 			continue
 		}
-		tm, err := types.ParseTimeFromFloatString(stmt, string(decimals[i].ToString()), b.tp.Tp, fsp)
+		tm, err := types.ParseTimeFromDecimal(stmt, &decimals[i], b.tp.Tp, fsp)
 		if err != nil {
 			if err = handleInvalidTimeError(b.ctx, err); err != nil {
 				return err
@@ -1485,6 +1532,10 @@ func (b *builtinCastTimeAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chunk
 			result.SetNull(i, true)
 			continue
 		}
+		if b.tp.Decimal == types.UnspecifiedLength {
+			// Keep the precision of the source, RoundFrac would round it to DefaultFsp.
+			fsp = res.Fsp()
+		}
 		tm, err := res.RoundFrac(stmt, fsp)
 		if err != nil {
 			return err
@@ -1517,14 +1568,34 @@ func (b *builtinCastTimeAsStringSig) vecEvalString(input *chunk.Chunk, result *c
 	var res string
 	var isNull bool
 	sc := b.ctx.GetSessionVars().StmtCtx
+	format := b.timestampFormat()
 	vas := buf.Times()
+	// strBuf is reused to format all the rows. The formatted time is ASCII, so it's neither truncated nor
+	// padded if its length is in [padLen, Flen], then it's appended to result directly without a string.
+	strBuf := make([]byte, 0, 32)
+	padLen := 0
+	if b.tp.Tp == mysql.TypeString && types.IsBinaryStr(b.tp) {
+		padLen = b.tp.Flen
+	}
 	result.ReserveString(n)
 	for i, v := range vas {
 		if buf.IsNull(i) {
 			result.AppendNull()
 			continue
 		}
-		res, err = types.ProduceStrWithSpecifiedTp(v.String(), b.tp, sc, false)
+		if format != "" {
+			if res, err = v.DateFormat(format); err != nil {
+				return err
+			}
+		} else {
+			strBuf = v.AppendTo(strBuf[:0])
+			if len(strBuf) >= padLen && (b.tp.Flen < 0 || len(strBuf) <= b.tp.Flen) {
+				result.AppendBytes(strBuf)
+				continue
+			}
+			res = string(strBuf)
+		}
+		res, err = types.ProduceStrWithSpecifiedTp(res, b.tp, sc, false)
 		if err != nil {
 			return err
 		}
@@ -1613,7 +1684,7 @@ func (b *builtinCastStringAsRealSig) vecEvalReal(input *chunk.Chunk, result *chu
 		if err != nil {
 			return err
 		}
-		if b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
+		if b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && res < 0 {
 			res = 0
 		}
 		res, err = types.ProduceFloatWithSpecifiedTp(res, b.tp, sc)
@@ -1650,10 +1721,10 @@ func (b *builtinCastStringAsDecimalSig) vecEvalDecimal(input *chunk.Chunk, resul
 		if result.IsNull(i) {
 			continue
 		}
-		val := strings.TrimSpace(buf.GetString(i))
+		val := stripLocaleNumeric(b.ctx, strings.TrimSpace(buf.GetString(i)))
 		isNegative := len(val) > 0 && val[0] == '-'
 		dec := new(types.MyDecimal)
-		if !(b.inUnion && mysql.HasUnsignedFlag(b.tp.Flag) && isNegative) {
+		if !(b.InUnion && mysql.HasUnsignedFlag(b.tp.Flag) && isNegative) {
 			if err := stmtCtx.HandleTruncate(dec.FromString([]byte(val))); err != nil {
 				return err
 			}
@@ -1686,12 +1757,11 @@ func (b *builtinCastStringAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chu
 	result.MergeNulls(buf)
 	times := result.Times()
 	stmtCtx := b.ctx.GetSessionVars().StmtCtx
-	fsp := int8(b.tp.Decimal)
 	for i := 0; i < n; i++ {
 		if result.IsNull(i) {
 			continue
 		}
-		tm, err := types.ParseTime(stmtCtx, buf.GetString(i), b.tp.Tp, fsp)
+		tm, err := b.parseTime(stmtCtx, buf.GetString(i))
 		if err != nil {
 			if err = handleInvalidTimeError(b.ctx, err); err != nil {
 				return err
@@ -1699,6 +1769,9 @@ func (b *builtinCastStringAsTimeSig) vecEvalTime(input *chunk.Chunk, result *chu
 			result.SetNull(i, true)
 			continue
 		}
+		if err = handleZeroTimeError(b.ctx, tm, b.implicit); err != nil {
+			return err
+		}
 		times[i] = tm
 		if b.tp.Tp == mysql.TypeDate {
 			// Truncate hh:mm:ss part if the type is Date.
@@ -1741,7 +1814,7 @@ func (b *builtinCastDecimalAsIntSig) vecEvalInt(input *chunk.Chunk, result *chun
 
 		if !mysql.HasUnsignedFlag(b.tp.Flag) {
 			i64s[i], err = to.ToInt()
-		} else if b.inUnion && to.IsNegative() {
+		} else if b.InUnion && to.IsNegative() {
 			i64s[i] = 0
 		} else {
 			var uintRes uint64
@@ -1751,7 +1824,7 @@ func (b *builtinCastDecimalAsIntSig) vecEvalInt(input *chunk.Chunk, result *chun
 
 		if types.ErrOverflow.Equal(err) {
 			warnErr := types.ErrTruncatedWrongVal.GenWithStackByArgs("DECIMAL", d64s[i])
-			err = b.ctx.GetSessionVars().StmtCtx.HandleOverflow(err, warnErr)
+			err = handleCastOverflow(b.ctx, err, warnErr)
 		}
 
 		if err != nil {
@@ -1859,6 +1932,7 @@ func (b *builtinCastJSONAsDurationSig) vecEvalDuration(input *chunk.Chunk, resul
 	}
 
 	ctx := b.ctx.GetSessionVars().StmtCtx
+	objectAsDuration := b.ctx.GetSessionVars().JSONObjectAsDuration
 	result.ResizeGoDuration(n, false)
 	result.MergeNulls(buf)
 	var dur types.Duration
@@ -1867,11 +1941,17 @@ func (b *builtinCastJSONAsDurationSig) vecEvalDuration(input *chunk.Chunk, resul
 		if result.IsNull(i) {
 			continue
 		}
-		s, err := buf.GetJSON(i).Unquote()
-		if err != nil {
-			return nil
+		val := buf.GetJSON(i)
+		if val.TypeCode == json.TypeCodeObject && objectAsDuration {
+			dur, err = castJSONObjectAsDuration(val, int8(b.tp.Decimal))
+		} else {
+			var s string
+			s, err = val.Unquote()
+			if err != nil {
+				return nil
+			}
+			dur, err = types.ParseDuration(ctx, s, int8(b.tp.Decimal))
 		}
-		dur, err = types.ParseDuration(ctx, s, int8(b.tp.Decimal))
 		if types.ErrTruncatedWrongVal.Equal(err) {
 			err = ctx.HandleTruncate(err)
 		}
@@ -162,7 +162,7 @@ func (s *testEvaluatorSuite) TestVectorizedCastRealAsTime(c *C) {
 	if err != nil {
 		panic(err)
 	}
-	cast := &builtinCastRealAsTimeSig{baseFunc}
+	cast := &builtinCastRealAsTimeSig{baseBuiltinFunc: baseFunc}
 
 	inputs := []*chunk.Chunk{
 		genCastRealAsTime(),
@@ -228,6 +228,38 @@ func (s *testEvaluatorSuite) TestVectorizedCastStringAsDecimalWithUnsignedFlagIn
 	}
 }
 
+func (s *testEvaluatorSuite) TestVectorizedCastTimeAsStringAllocs(c *C) {
+	cast, input, result := genCastTimeAsString()
+	c.Assert(cast.vecEvalString(input, result), IsNil)
+	for i := 0; i < input.NumRows(); i++ {
+		res, isNull, err := cast.evalString(input.GetRow(i))
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(result.GetString(i), Equals, res)
+	}
+	// The formatted times are appended to the result directly, so the allocations don't grow with the rows.
+	allocs := testing.AllocsPerRun(10, func() {
+		if err := cast.vecEvalString(input, result); err != nil {
+			panic(err)
+		}
+	})
+	c.Assert(allocs <= 2, IsTrue, Commentf("%v allocations for %d rows", allocs, input.NumRows()))
+
+	// The BINARY values shorter than Flen are still padded.
+	binaryTp := types.NewFieldType(mysql.TypeString)
+	binaryTp.Flen = 32
+	types.SetBinChsClnFlag(binaryTp)
+	cast.tp = binaryTp
+	c.Assert(cast.vecEvalString(input, result), IsNil)
+	for i := 0; i < input.NumRows(); i++ {
+		res, isNull, err := cast.evalString(input.GetRow(i))
+		c.Assert(err, IsNil)
+		c.Assert(isNull, IsFalse)
+		c.Assert(res, HasLen, 32)
+		c.Assert(result.GetString(i), Equals, res)
+	}
+}
+
 func genCastStringAsDecimal(isNegative bool) *chunk.Chunk {
 	var sign float64
 	if isNegative {
@@ -45,6 +45,11 @@ func (col *CorrelatedColumn) Clone() Expression {
 	}
 }
 
+// CloneWithNewContext implements Expression interface.
+func (col *CorrelatedColumn) CloneWithNewContext(_ sessionctx.Context) Expression {
+	return col.Clone()
+}
+
 // VecEvalInt evaluates this expression in a vectorized manner.
 func (col *CorrelatedColumn) VecEvalInt(ctx sessionctx.Context, input *chunk.Chunk, result *chunk.Column) error {
 	return genVecFromConstExpr(ctx, col, types.ETInt, input, result)
@@ -437,6 +442,15 @@ func (col *Column) Clone() Expression {
 	return &newCol
 }
 
+// CloneWithNewContext implements Expression interface.
+func (col *Column) CloneWithNewContext(ctx sessionctx.Context) Expression {
+	newCol := *col
+	if col.VirtualExpr != nil {
+		newCol.VirtualExpr = col.VirtualExpr.CloneWithNewContext(ctx)
+	}
+	return &newCol
+}
+
 // IsCorrelated implements Expression interface.
 func (col *Column) IsCorrelated() bool {
 	return false
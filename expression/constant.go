@@ -104,6 +104,18 @@ func (c *Constant) Clone() Expression {
 	return &con
 }
 
+// CloneWithNewContext implements Expression interface.
+func (c *Constant) CloneWithNewContext(ctx sessionctx.Context) Expression {
+	con := *c
+	if c.ParamMarker != nil {
+		con.ParamMarker = &ParamMarker{ctx: ctx, order: c.ParamMarker.order}
+	}
+	if c.DeferredExpr != nil {
+		con.DeferredExpr = c.DeferredExpr.CloneWithNewContext(ctx)
+	}
+	return &con
+}
+
 // GetType implements Expression interface.
 func (c *Constant) GetType() *types.FieldType {
 	if c.ParamMarker != nil {
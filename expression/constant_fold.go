@@ -14,8 +14,12 @@
 package expression
 
 import (
+	"time"
+
 	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/chunk"
 	"github.com/pingcap/tidb/util/logutil"
@@ -42,6 +46,41 @@ func FoldConstant(expr Expression) Expression {
 	return e
 }
 
+// FoldConstantWithTimeout is like FoldConstant, but it returns an error if folding expr takes longer than timeout,
+// so that an expensive constant expression fails the statement instead of being evaluated again for every row.
+// The evaluation isn't interrupted, its cost is bounded by max_allowed_packet like at execution. The nested calls
+// share the deadline of the outermost one. The timeout is disabled if it's not positive. expr is returned unfolded
+// with the error.
+func FoldConstantWithTimeout(ctx sessionctx.Context, expr Expression, timeout time.Duration) (Expression, error) {
+	sc := ctx.GetSessionVars().StmtCtx
+	if timeout <= 0 || !sc.ConstantFoldDeadline.IsZero() {
+		return FoldConstant(expr), nil
+	}
+	sc.ConstantFoldDeadline = constantFoldNow().Add(timeout)
+	res := FoldConstant(expr)
+	timedOut := sc.ConstantFoldTimedOut
+	sc.ConstantFoldDeadline, sc.ConstantFoldTimedOut = time.Time{}, false
+	if timedOut {
+		return expr, errConstantFoldTimeout.GenWithStackByArgs(timeout)
+	}
+	return res, nil
+}
+
+// constantFoldNow returns the current time when checking the deadline of folding constants, it's replaced in tests.
+var constantFoldNow = time.Now
+
+// constantFoldTimedOut checks whether the deadline of folding constants has passed, it's checked after a function is evaluated.
+func constantFoldTimedOut(sc *stmtctx.StatementContext) bool {
+	if sc.ConstantFoldTimedOut {
+		return true
+	}
+	if sc.ConstantFoldDeadline.IsZero() || constantFoldNow().Before(sc.ConstantFoldDeadline) {
+		return false
+	}
+	sc.ConstantFoldTimedOut = true
+	return true
+}
+
 func isNullHandler(expr *ScalarFunction) (Expression, bool) {
 	arg0 := expr.GetArgs()[0]
 	if constArg, isConst := arg0.(*Constant); isConst {
@@ -208,6 +247,9 @@ func foldConstant(expr Expression) (Expression, bool) {
 			return expr, isDeferredConst
 		}
 		value, err := x.Eval(chunk.Row{})
+		if constantFoldTimedOut(sc) {
+			return expr, isDeferredConst
+		}
 		retType := x.RetType.Clone()
 		if !hasNullArg {
 			// set right not null flag for constant value
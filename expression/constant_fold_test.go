@@ -33,3 +33,18 @@ func (s *testIntegrationSuite) TestFoldIfNull(c *C) {
 	tk.MustQuery(`select ifnull("aaaa", a) from t;`).Check(testkit.Rows("aaaa"))
 	tk.MustQuery(`show warnings;`).Check(testkit.Rows())
 }
+
+func (s *testIntegrationSuite) TestConstantFoldTimeout(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t(a varchar(32))")
+	tk.MustQuery("select @@tidb_constant_fold_timeout_ms").Check(testkit.Rows("100"))
+	// The constant expression is folded within the timeout, see TestFoldConstantWithTimeout for exceeding it.
+	tk.MustQuery("explain format = 'brief' select a from t where md5(repeat('a', 3)) = a").Check(testkit.Rows(
+		`TableReader 10.00 root  data:Selection`,
+		`└─Selection 10.00 cop[tikv]  eq("47bce5c74f589f4867dbd57e9ca9f808", test.t.a)`,
+		`  └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo`,
+	))
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+}
@@ -217,6 +217,44 @@ func (*testExpressionSuite) TestConstantFolding(c *C) {
 	}
 }
 
+func (*testExpressionSuite) TestFoldConstantWithTimeout(c *C) {
+	ctx := mock.NewContext()
+	sc := ctx.GetSessionVars().StmtCtx
+	c.Assert(ctx.GetSessionVars().GetConstantFoldTimeout(), Equals, 100*time.Millisecond)
+	// Every evaluation takes a second with the clock.
+	now := time.Now()
+	originNow := constantFoldNow
+	defer func() { constantFoldNow = originNow }()
+	constantFoldNow = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	strTp := types.NewFieldType(mysql.TypeVarString)
+	aaa := &Constant{Value: types.NewStringDatum("aaa"), RetType: strTp}
+	md5, err := NewFunctionBase(ctx, ast.MD5, strTp, aaa)
+	c.Assert(err, IsNil)
+
+	// The evaluation exceeds the timeout.
+	_, err = FoldConstantWithTimeout(ctx, md5, 500*time.Millisecond)
+	c.Assert(errConstantFoldTimeout.Equal(err), IsTrue, Commentf("err %v", err))
+	c.Assert(sc.ConstantFoldDeadline.IsZero(), IsTrue)
+	c.Assert(sc.ConstantFoldTimedOut, IsFalse)
+	_, err = NewFunction(ctx, ast.MD5, strTp, aaa)
+	c.Assert(errConstantFoldTimeout.Equal(err), IsTrue, Commentf("err %v", err))
+
+	// The timeout is for each expression.
+	for i := 0; i < 2; i++ {
+		res, err := FoldConstantWithTimeout(ctx, md5, 1500*time.Millisecond)
+		c.Assert(err, IsNil)
+		c.Assert(res.String(), Equals, "47bce5c74f589f4867dbd57e9ca9f808")
+	}
+	// It's disabled if the timeout isn't positive.
+	ctx.GetSessionVars().ConstantFoldTimeoutMs = 0
+	res, err := NewFunction(ctx, ast.MD5, strTp, aaa)
+	c.Assert(err, IsNil)
+	c.Assert(res.String(), Equals, "47bce5c74f589f4867dbd57e9ca9f808")
+}
+
 func (*testExpressionSuite) TestDeferredParamNotNull(c *C) {
 	ctx := mock.NewContext()
 	testTime := time.Now()
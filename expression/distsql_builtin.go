@@ -16,12 +16,16 @@ package expression
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/model"
 	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/config"
 	"github.com/pingcap/tidb/sessionctx"
 	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/sessionctx/variable"
@@ -44,7 +48,7 @@ func PbTypeToFieldType(tp *tipb.FieldType) *types.FieldType {
 	}
 }
 
-func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *tipb.FieldType, args []Expression) (f builtinFunc, e error) {
+func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *tipb.FieldType, args []Expression, inUnion bool) (f builtinFunc, e error) {
 	fieldTp := PbTypeToFieldType(tp)
 	base, err := newBaseBuiltinFuncWithFieldType(ctx, fieldTp, args)
 	if err != nil {
@@ -58,41 +62,41 @@ func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *ti
 	base.tp = fieldTp
 	switch sigCode {
 	case tipb.ScalarFuncSig_CastIntAsInt:
-		f = &builtinCastIntAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastIntAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastIntAsReal:
-		f = &builtinCastIntAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastIntAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastIntAsString:
 		f = &builtinCastIntAsStringSig{base}
 	case tipb.ScalarFuncSig_CastIntAsDecimal:
-		f = &builtinCastIntAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastIntAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastIntAsTime:
-		f = &builtinCastIntAsTimeSig{base}
+		f = &builtinCastIntAsTimeSig{baseBuiltinFunc: base}
 	case tipb.ScalarFuncSig_CastIntAsDuration:
 		f = &builtinCastIntAsDurationSig{base}
 	case tipb.ScalarFuncSig_CastIntAsJson:
 		f = &builtinCastIntAsJSONSig{base}
 	case tipb.ScalarFuncSig_CastRealAsInt:
-		f = &builtinCastRealAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastRealAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastRealAsReal:
-		f = &builtinCastRealAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastRealAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastRealAsString:
 		f = &builtinCastRealAsStringSig{base}
 	case tipb.ScalarFuncSig_CastRealAsDecimal:
-		f = &builtinCastRealAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastRealAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastRealAsTime:
-		f = &builtinCastRealAsTimeSig{base}
+		f = &builtinCastRealAsTimeSig{baseBuiltinFunc: base}
 	case tipb.ScalarFuncSig_CastRealAsDuration:
 		f = &builtinCastRealAsDurationSig{base}
 	case tipb.ScalarFuncSig_CastRealAsJson:
 		f = &builtinCastRealAsJSONSig{base}
 	case tipb.ScalarFuncSig_CastDecimalAsInt:
-		f = &builtinCastDecimalAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastDecimalAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastDecimalAsReal:
-		f = &builtinCastDecimalAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastDecimalAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastDecimalAsString:
 		f = &builtinCastDecimalAsStringSig{base}
 	case tipb.ScalarFuncSig_CastDecimalAsDecimal:
-		f = &builtinCastDecimalAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastDecimalAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastDecimalAsTime:
 		f = &builtinCastDecimalAsTimeSig{base}
 	case tipb.ScalarFuncSig_CastDecimalAsDuration:
@@ -100,27 +104,27 @@ func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *ti
 	case tipb.ScalarFuncSig_CastDecimalAsJson:
 		f = &builtinCastDecimalAsJSONSig{base}
 	case tipb.ScalarFuncSig_CastStringAsInt:
-		f = &builtinCastStringAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastStringAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastStringAsReal:
-		f = &builtinCastStringAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastStringAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastStringAsString:
 		f = &builtinCastStringAsStringSig{base}
 	case tipb.ScalarFuncSig_CastStringAsDecimal:
-		f = &builtinCastStringAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastStringAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastStringAsTime:
-		f = &builtinCastStringAsTimeSig{base}
+		f = &builtinCastStringAsTimeSig{baseBuiltinFunc: base}
 	case tipb.ScalarFuncSig_CastStringAsDuration:
 		f = &builtinCastStringAsDurationSig{base}
 	case tipb.ScalarFuncSig_CastStringAsJson:
 		f = &builtinCastStringAsJSONSig{base}
 	case tipb.ScalarFuncSig_CastTimeAsInt:
-		f = &builtinCastTimeAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastTimeAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastTimeAsReal:
-		f = &builtinCastTimeAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastTimeAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastTimeAsString:
 		f = &builtinCastTimeAsStringSig{base}
 	case tipb.ScalarFuncSig_CastTimeAsDecimal:
-		f = &builtinCastTimeAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastTimeAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastTimeAsTime:
 		f = &builtinCastTimeAsTimeSig{base}
 	case tipb.ScalarFuncSig_CastTimeAsDuration:
@@ -128,13 +132,13 @@ func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *ti
 	case tipb.ScalarFuncSig_CastTimeAsJson:
 		f = &builtinCastTimeAsJSONSig{base}
 	case tipb.ScalarFuncSig_CastDurationAsInt:
-		f = &builtinCastDurationAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastDurationAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastDurationAsReal:
-		f = &builtinCastDurationAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastDurationAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastDurationAsString:
 		f = &builtinCastDurationAsStringSig{base}
 	case tipb.ScalarFuncSig_CastDurationAsDecimal:
-		f = &builtinCastDurationAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastDurationAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastDurationAsTime:
 		f = &builtinCastDurationAsTimeSig{base}
 	case tipb.ScalarFuncSig_CastDurationAsDuration:
@@ -142,13 +146,13 @@ func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *ti
 	case tipb.ScalarFuncSig_CastDurationAsJson:
 		f = &builtinCastDurationAsJSONSig{base}
 	case tipb.ScalarFuncSig_CastJsonAsInt:
-		f = &builtinCastJSONAsIntSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastJSONAsIntSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastJsonAsReal:
-		f = &builtinCastJSONAsRealSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastJSONAsRealSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastJsonAsString:
 		f = &builtinCastJSONAsStringSig{base}
 	case tipb.ScalarFuncSig_CastJsonAsDecimal:
-		f = &builtinCastJSONAsDecimalSig{newBaseBuiltinCastFunc(base, false)}
+		f = &builtinCastJSONAsDecimalSig{newBaseBuiltinCastFunc(base, inUnion)}
 	case tipb.ScalarFuncSig_CastJsonAsTime:
 		f = &builtinCastJSONAsTimeSig{base}
 	case tipb.ScalarFuncSig_CastJsonAsDuration:
@@ -1060,7 +1064,7 @@ func getSignatureByPB(ctx sessionctx.Context, sigCode tipb.ScalarFuncSig, tp *ti
 func newDistSQLFunctionBySig(sc *stmtctx.StatementContext, sigCode tipb.ScalarFuncSig, tp *tipb.FieldType, args []Expression) (Expression, error) {
 	ctx := mock.NewContext()
 	ctx.GetSessionVars().StmtCtx = sc
-	f, err := getSignatureByPB(ctx, sigCode, tp, args)
+	f, err := getSignatureByPB(ctx, sigCode, tp, args, false)
 	if err != nil {
 		return nil, err
 	}
@@ -1071,6 +1075,65 @@ func newDistSQLFunctionBySig(sc *stmtctx.StatementContext, sigCode tipb.ScalarFu
 	}, nil
 }
 
+// NewCastFuncFromPB reconstructs the cast function from its protobuf representation.
+// The signature is built by getSignatureByPB like the other pushed down functions, and the
+// types of the column references in pbFunc are read from their own field types.
+func NewCastFuncFromPB(ctx sessionctx.Context, pbFunc *tipb.Expr) (Expression, error) {
+	if pbFunc.Tp != tipb.ExprType_ScalarFunc || !strings.HasPrefix(pbFunc.Sig.String(), "Cast") ||
+		len(pbFunc.Children) != 1 || pbFunc.FieldType == nil {
+		return nil, errors.Errorf("%s is not a cast function", pbFunc.Sig)
+	}
+	tps, err := columnTypesFromPB(pbFunc.Children[0], nil)
+	if err != nil {
+		return nil, err
+	}
+	arg, err := PBToExpr(pbFunc.Children[0], tps, ctx.GetSessionVars().StmtCtx)
+	if err != nil {
+		return nil, err
+	}
+	var metadata tipb.InUnionMetadata
+	if len(pbFunc.Val) > 0 {
+		if err := proto.Unmarshal(pbFunc.Val, &metadata); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	f, err := getSignatureByPB(ctx, pbFunc.Sig, pbFunc.FieldType, []Expression{arg}, metadata.InUnion)
+	if err != nil {
+		return nil, err
+	}
+	return &ScalarFunction{
+		FuncName: model.NewCIStr(ast.Cast),
+		RetType:  f.getRetTp(),
+		Function: f,
+	}, nil
+}
+
+// columnTypesFromPB collects the field types of the column references in expr, indexed by their offsets.
+func columnTypesFromPB(expr *tipb.Expr, tps []*types.FieldType) ([]*types.FieldType, error) {
+	if expr.Tp == tipb.ExprType_ColumnRef {
+		_, offset, err := codec.DecodeInt(expr.Val)
+		if err != nil {
+			return nil, err
+		}
+		// A row never has more columns than a table, the offset out of the range is corrupted.
+		if offset < 0 || offset >= config.DefMaxOfTableColumnCountLimit || expr.FieldType == nil {
+			return nil, errors.Errorf("invalid column reference %d", offset)
+		}
+		if int64(len(tps)) <= offset {
+			tps = append(tps, make([]*types.FieldType, int(offset)+1-len(tps))...)
+		}
+		tps[offset] = PbTypeToFieldType(expr.FieldType)
+		return tps, nil
+	}
+	var err error
+	for _, child := range expr.Children {
+		if tps, err = columnTypesFromPB(child, tps); err != nil {
+			return nil, err
+		}
+	}
+	return tps, nil
+}
+
 // PBToExprs converts pb structures to expressions.
 func PBToExprs(pbExprs []*tipb.Expr, fieldTps []*types.FieldType, sc *stmtctx.StatementContext) ([]Expression, error) {
 	exprs := make([]Expression, 0, len(pbExprs))
@@ -14,8 +14,11 @@
 package expression
 
 import (
+	"math"
+	"reflect"
 	"time"
 
+	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
 	"github.com/pingcap/parser/charset"
 	"github.com/pingcap/parser/mysql"
@@ -25,6 +28,7 @@ import (
 	"github.com/pingcap/tidb/util/chunk"
 	"github.com/pingcap/tidb/util/codec"
 	"github.com/pingcap/tidb/util/collate"
+	"github.com/pingcap/tidb/util/mock"
 	"github.com/pingcap/tipb/go-tipb"
 )
 
@@ -158,6 +162,87 @@ func (s *testEvalSuite) TestPBToExpr(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (s *testEvalSuite) TestNewCastFuncFromPB(c *C) {
+	ctx := mock.NewContext()
+	evalTps := []struct {
+		name string
+		tp   *types.FieldType
+	}{
+		{"Int", types.NewFieldType(mysql.TypeLonglong)},
+		{"Real", types.NewFieldType(mysql.TypeDouble)},
+		{"Decimal", types.NewFieldType(mysql.TypeNewDecimal)},
+		{"String", types.NewFieldType(mysql.TypeVarString)},
+		{"Time", types.NewFieldType(mysql.TypeDatetime)},
+		{"Duration", types.NewFieldType(mysql.TypeDuration)},
+		{"Json", types.NewFieldType(mysql.TypeJSON)},
+	}
+	cnt := 0
+	for _, from := range evalTps {
+		for _, to := range evalTps {
+			sig := tipb.ScalarFuncSig(tipb.ScalarFuncSig_value["Cast"+from.name+"As"+to.name])
+			col := columnExpr(0)
+			col.FieldType = ToPBFieldType(from.tp)
+			pbFunc := &tipb.Expr{
+				Tp:        tipb.ExprType_ScalarFunc,
+				Sig:       sig,
+				Children:  []*tipb.Expr{col},
+				FieldType: ToPBFieldType(to.tp),
+			}
+			expr, err := NewCastFuncFromPB(ctx, pbFunc)
+			c.Assert(err, IsNil, Commentf("%s", sig))
+			sf, ok := expr.(*ScalarFunction)
+			c.Assert(ok, IsTrue)
+			expected := BuildCastFunction(ctx, &Column{Index: 0, RetType: from.tp}, to.tp).(*ScalarFunction)
+			c.Assert(sf.FuncName.L, Equals, expected.FuncName.L)
+			c.Assert(reflect.TypeOf(sf.Function), Equals, reflect.TypeOf(expected.Function))
+			c.Assert(sf.Function.PbCode(), Equals, sig)
+			c.Assert(sf.RetType.Tp, Equals, to.tp.Tp)
+			cnt++
+		}
+	}
+	c.Assert(cnt, Equals, 49)
+
+	// The in union flag is carried by the metadata.
+	col := columnExpr(0)
+	col.FieldType = ToPBFieldType(types.NewFieldType(mysql.TypeLonglong))
+	val, err := proto.Marshal(&tipb.InUnionMetadata{InUnion: true})
+	c.Assert(err, IsNil)
+	expr, err := NewCastFuncFromPB(ctx, &tipb.Expr{
+		Tp:        tipb.ExprType_ScalarFunc,
+		Sig:       tipb.ScalarFuncSig_CastIntAsInt,
+		Val:       val,
+		Children:  []*tipb.Expr{col},
+		FieldType: ToPBFieldType(types.NewFieldType(mysql.TypeLonglong)),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(IsInUnionCastContext(expr), IsTrue)
+
+	// Only the cast signatures are accepted.
+	_, err = NewCastFuncFromPB(ctx, &tipb.Expr{
+		Tp:        tipb.ExprType_ScalarFunc,
+		Sig:       tipb.ScalarFuncSig_AbsInt,
+		Children:  []*tipb.Expr{col},
+		FieldType: ToPBFieldType(types.NewFieldType(mysql.TypeLonglong)),
+	})
+	c.Assert(err, NotNil)
+	_, err = NewCastFuncFromPB(ctx, columnExpr(0))
+	c.Assert(err, NotNil)
+
+	// The offset of the column reference is bounded.
+	col = &tipb.Expr{
+		Tp:        tipb.ExprType_ColumnRef,
+		Val:       codec.EncodeInt(nil, math.MaxInt32),
+		FieldType: ToPBFieldType(types.NewFieldType(mysql.TypeLonglong)),
+	}
+	_, err = NewCastFuncFromPB(ctx, &tipb.Expr{
+		Tp:        tipb.ExprType_ScalarFunc,
+		Sig:       tipb.ScalarFuncSig_CastIntAsInt,
+		Children:  []*tipb.Expr{col},
+		FieldType: ToPBFieldType(types.NewFieldType(mysql.TypeLonglong)),
+	})
+	c.Assert(err, NotNil)
+}
+
 // TestEval test expr.Eval().
 func (s *testEvalSuite) TestEval(c *C) {
 	row := chunk.MutRowFromDatums([]types.Datum{types.NewDatum(100)}).ToRow()
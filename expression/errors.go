@@ -47,6 +47,9 @@ var (
 	errTruncatedWrongValue           = dbterror.ClassExpression.NewStd(mysql.ErrTruncatedWrongValue)
 	errUnknownLocale                 = dbterror.ClassExpression.NewStd(mysql.ErrUnknownLocale)
 	errNonUniq                       = dbterror.ClassExpression.NewStd(mysql.ErrNonUniq)
+	errConstantFoldTimeout           = dbterror.ClassExpression.NewStd(mysql.ErrConstantFoldTimeout)
+	errZeroDateNotAllowed            = dbterror.ClassExpression.NewStd(mysql.ErrZeroDateNotAllowed)
+	errZeroInDateNotAllowed          = dbterror.ClassExpression.NewStd(mysql.ErrZeroInDateNotAllowed)
 
 	// Sequence usage privilege check.
 	errSequenceAccessDenied      = dbterror.ClassExpression.NewStd(mysql.ErrTableaccessDenied)
@@ -69,6 +72,49 @@ func handleInvalidTimeError(ctx sessionctx.Context, err error) error {
 	return nil
 }
 
+// handleZeroTimeError checks the time value against NO_ZERO_DATE and NO_ZERO_IN_DATE separately,
+// it only takes effect in INSERT, UPDATE and DELETE statements. The implicit cast of a function
+// argument is skipped, the function reports the zero date itself.
+func handleZeroTimeError(ctx sessionctx.Context, t types.Time, implicit bool) error {
+	sc := ctx.GetSessionVars().StmtCtx
+	if implicit || !(sc.InInsertStmt || sc.InUpdateStmt || sc.InDeleteStmt) {
+		return nil
+	}
+	if t.IsZero() {
+		return handleZeroDateError(ctx, t)
+	}
+	if t.InvalidZero() {
+		return handleZeroInDateError(ctx, t)
+	}
+	return nil
+}
+
+// handleZeroDateError reports error or warning for the all-zero date '0000-00-00' if NO_ZERO_DATE is set.
+func handleZeroDateError(ctx sessionctx.Context, t types.Time) error {
+	if !ctx.GetSessionVars().SQLMode.HasNoZeroDateMode() {
+		return nil
+	}
+	return handleZeroTimeModeError(ctx, errZeroDateNotAllowed.GenWithStackByArgs(types.DateTimeStr, t.String()))
+}
+
+// handleZeroInDateError reports error or warning for the date with zero month or day like '2024-00-01'
+// if NO_ZERO_IN_DATE is set.
+func handleZeroInDateError(ctx sessionctx.Context, t types.Time) error {
+	if !ctx.GetSessionVars().SQLMode.HasNoZeroInDateMode() {
+		return nil
+	}
+	return handleZeroTimeModeError(ctx, errZeroInDateNotAllowed.GenWithStackByArgs(types.DateTimeStr, t.String()))
+}
+
+// handleZeroTimeModeError reports the error of NO_ZERO_DATE or NO_ZERO_IN_DATE in strict mode, or a warning otherwise.
+func handleZeroTimeModeError(ctx sessionctx.Context, err error) error {
+	err = ctx.GetSessionVars().StmtCtx.HandleTruncate(err)
+	if ctx.GetSessionVars().StrictSQLMode {
+		return err
+	}
+	return nil
+}
+
 // handleDivisionByZeroError reports error or warning depend on the context.
 func handleDivisionByZeroError(ctx sessionctx.Context) error {
 	sc := ctx.GetSessionVars().StmtCtx
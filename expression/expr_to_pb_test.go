@@ -17,6 +17,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	. "github.com/pingcap/check"
@@ -27,6 +28,7 @@ import (
 	"github.com/pingcap/tidb/kv"
 	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/chunk"
 	"github.com/pingcap/tidb/util/collate"
 	"github.com/pingcap/tidb/util/mock"
 	"github.com/pingcap/tipb/go-tipb"
@@ -1003,3 +1005,94 @@ func (s *testEvalSerialSuite) TestPushCollationDown(c *C) {
 		c.Assert(eColl, Equals, coll)
 	}
 }
+
+func (s *testEvaluatorSuite) TestCastFunc2PbRoundTrip(c *C) {
+	sc := s.ctx.GetSessionVars().StmtCtx
+	client := new(mock.Client)
+	pc := NewPBConverter(client, sc)
+
+	decimalTp := types.NewFieldType(mysql.TypeNewDecimal)
+	decimalTp.Flen, decimalTp.Decimal = 20, 2
+	stringTp := types.NewFieldType(mysql.TypeVarString)
+	stringTp.Charset, stringTp.Collate = charset.CharsetUTF8MB4, charset.CollationUTF8MB4
+	jsonTp := types.NewFieldType(mysql.TypeJSON)
+	jsonTp.Charset, jsonTp.Collate = charset.CharsetBin, charset.CollationBin
+	jsonTp.Flag |= mysql.BinaryFlag
+	jsonDatum := types.NewIntDatum(20210102)
+	jsonVal, err := jsonDatum.ToMysqlJSON()
+	c.Assert(err, IsNil)
+	targetTps := []*types.FieldType{
+		types.NewFieldType(mysql.TypeLonglong),
+		types.NewFieldType(mysql.TypeDouble),
+		decimalTp,
+		stringTp,
+		types.NewFieldType(mysql.TypeDatetime),
+		types.NewFieldType(mysql.TypeDuration),
+		jsonTp,
+	}
+	inputs := []struct {
+		tp    byte
+		datum types.Datum
+	}{
+		{mysql.TypeLonglong, types.NewIntDatum(20210102)},
+		{mysql.TypeDouble, types.NewFloat64Datum(20210102.5)},
+		{mysql.TypeNewDecimal, types.NewDecimalDatum(types.NewDecFromStringForTest("20210102.5"))},
+		{mysql.TypeVarString, types.NewStringDatum("2021-01-02 03:04:05")},
+		{mysql.TypeDatetime, types.NewTimeDatum(types.NewTime(types.FromDate(2021, 1, 2, 3, 4, 5, 0), mysql.TypeDatetime, 0))},
+		{mysql.TypeDuration, types.NewDurationDatum(types.Duration{Duration: time.Hour})},
+		{mysql.TypeJSON, types.NewJSONDatum(jsonVal)},
+	}
+
+	sigs := make(map[tipb.ScalarFuncSig]struct{})
+	for _, input := range inputs {
+		col := &Column{RetType: types.NewFieldType(input.tp), ID: 1, Index: 0}
+		row := chunk.MutRowFromDatums([]types.Datum{input.datum}).ToRow()
+		for _, tp := range targetTps {
+			cast := BuildCastFunction(s.ctx, col, tp)
+			comment := Commentf("cast %s as %s", types.TypeToStr(input.tp, ""), types.TypeToStr(tp.Tp, ""))
+			pbExpr := pc.ExprToPB(cast)
+			c.Assert(pbExpr, NotNil, comment)
+			sigs[pbExpr.Sig] = struct{}{}
+
+			data, err := proto.Marshal(pbExpr)
+			c.Assert(err, IsNil, comment)
+			decoded := new(tipb.Expr)
+			c.Assert(proto.Unmarshal(data, decoded), IsNil, comment)
+			c.Assert(decoded.Sig, Equals, pbExpr.Sig, comment)
+			expr, err := PBToExpr(decoded, []*types.FieldType{col.RetType}, sc)
+			c.Assert(err, IsNil, comment)
+
+			expected, err1 := cast.Eval(row)
+			actual, err2 := expr.Eval(row)
+			c.Assert(err2 == nil, Equals, err1 == nil, comment)
+			if err1 != nil {
+				continue
+			}
+			cmp, err := expected.CompareDatum(sc, &actual)
+			c.Assert(err, IsNil, comment)
+			c.Assert(cmp, Equals, 0, comment)
+		}
+	}
+	c.Assert(sigs, HasLen, len(inputs)*len(targetTps))
+}
+
+func (s *testEvaluatorSuite) TestImplicitTimeCastPushDown(c *C) {
+	ctx := mock.NewContext()
+	sc := ctx.GetSessionVars().StmtCtx
+	client := new(mock.Client)
+	col := &Column{RetType: types.NewFieldType(mysql.TypeVarString), Index: 0}
+	implicit := WrapWithCastAsTime(ctx, col, types.NewFieldType(mysql.TypeDatetime))
+	explicit := BuildCastFunction(ctx, col, types.NewFieldType(mysql.TypeDatetime))
+
+	// The implicit cast skips the zero date check in DML, which the storage layer doesn't know.
+	ctx.GetSessionVars().SQLMode = mysql.ModeStrictTransTables | mysql.ModeNoZeroDate
+	sc.InInsertStmt = true
+	c.Assert(CanExprsPushDown(sc, []Expression{implicit}, client, kv.TiKV), IsFalse)
+	c.Assert(CanExprsPushDown(sc, []Expression{explicit}, client, kv.TiKV), IsTrue)
+
+	sc.InInsertStmt = false
+	c.Assert(CanExprsPushDown(sc, []Expression{implicit}, client, kv.TiKV), IsTrue)
+	sc.InUpdateStmt = true
+	ctx.GetSessionVars().SQLMode = mysql.ModeStrictTransTables
+	c.Assert(CanExprsPushDown(sc, []Expression{implicit}, client, kv.TiKV), IsTrue)
+}
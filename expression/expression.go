@@ -131,6 +131,10 @@ type Expression interface {
 	// Clone copies an expression totally.
 	Clone() Expression
 
+	// CloneWithNewContext copies an expression totally and binds the copy to ctx,
+	// so an expression tree built by one session can be evaluated by another one.
+	CloneWithNewContext(ctx sessionctx.Context) Expression
+
 	// Equal checks whether two expressions are equal.
 	Equal(ctx sessionctx.Context, e Expression) bool
 
@@ -1077,11 +1081,49 @@ func canFuncBePushed(sf *ScalarFunction, storeType kv.StoreType) bool {
 	}
 
 	if ret {
-		ret = IsPushDownEnabled(sf.FuncName.L, storeType)
+		ret = IsPushDownEnabled(sf.FuncName.L, storeType) && !castDependsOnSessionVars(sf)
 	}
 	return ret
 }
 
+// castDependsOnSessionVars checks whether the result of the cast function sf depends on the session variables,
+// which aren't passed to the storage layer, so it must be evaluated in TiDB.
+func castDependsOnSessionVars(sf *ScalarFunction) bool {
+	if sf.FuncName.L != ast.Cast {
+		return false
+	}
+	vars := sf.GetCtx().GetSessionVars()
+	switch sf.Function.PbCode() {
+	case tipb.ScalarFuncSig_CastStringAsDecimal:
+		return vars.EnableLocaleAwareCast
+	case tipb.ScalarFuncSig_CastIntAsDuration, tipb.ScalarFuncSig_CastRealAsInt, tipb.ScalarFuncSig_CastRealAsDecimal,
+		tipb.ScalarFuncSig_CastDecimalAsInt, tipb.ScalarFuncSig_CastStringAsInt, tipb.ScalarFuncSig_CastTimeAsReal,
+		tipb.ScalarFuncSig_CastDecimalAsReal:
+		return vars.CastOverflowAsError
+	case tipb.ScalarFuncSig_CastTimeAsString:
+		return vars.TimestampCastFormat != "" && sf.GetArgs()[0].GetType().Tp == mysql.TypeTimestamp
+	case tipb.ScalarFuncSig_CastIntAsTime, tipb.ScalarFuncSig_CastRealAsTime, tipb.ScalarFuncSig_CastStringAsTime:
+		// The implicit cast skips the zero date check of sql_mode in DML, but the pb has no field for the
+		// implicit flag, so the cast rebuilt by the storage layer would check it.
+		sc := vars.StmtCtx
+		return isImplicitTimeCast(sf.Function) && (sc.InInsertStmt || sc.InUpdateStmt || sc.InDeleteStmt) &&
+			(vars.SQLMode.HasNoZeroDateMode() || vars.SQLMode.HasNoZeroInDateMode())
+	}
+	return false
+}
+
+func isImplicitTimeCast(f builtinFunc) bool {
+	switch sig := f.(type) {
+	case *builtinCastIntAsTimeSig:
+		return sig.implicit
+	case *builtinCastRealAsTimeSig:
+		return sig.implicit
+	case *builtinCastStringAsTimeSig:
+		return sig.implicit
+	}
+	return false
+}
+
 func storeTypeMask(storeType kv.StoreType) uint32 {
 	if storeType == kv.UnSpecified {
 		return 1<<kv.TiKV | 1<<kv.TiFlash | 1<<kv.TiDB
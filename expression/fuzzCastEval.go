@@ -0,0 +1,129 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
+	"github.com/pingcap/tidb/util/chunk"
+	"github.com/pingcap/tidb/util/mock"
+)
+
+// FuzzCastEval implements the fuzzer. The first byte of data chooses the type of the
+// value decoded from the rest bytes, and the value is cast to every type. The errors
+// returned by the casts are expected for the malformed values, only the panics are bugs.
+func FuzzCastEval(data []byte) int {
+	if len(data) < 2 {
+		return -1
+	}
+	ctx := mock.NewContext()
+	d, ok := fuzzCastDatum(ctx.GetSessionVars().StmtCtx, data[0], data[1:])
+	if !ok {
+		return -1
+	}
+	ft := new(types.FieldType)
+	types.DefaultTypeForValue(d.GetValue(), ft, mysql.DefaultCharset, mysql.DefaultCollationName)
+	col := &Column{RetType: ft, Index: 0}
+	row := chunk.MutRowFromDatums([]types.Datum{d}).ToRow()
+
+	ret := 1
+	for _, tp := range fuzzCastTargetTypes() {
+		cast := BuildCastFunction(ctx, col, tp)
+		if _, err := cast.Eval(row); err != nil {
+			ret = 0
+		}
+	}
+	return ret
+}
+
+func fuzzCastDatum(sc *stmtctx.StatementContext, kind byte, data []byte) (types.Datum, bool) {
+	switch kind % 8 {
+	case 0, 1, 2:
+		if len(data) < 8 {
+			return types.Datum{}, false
+		}
+		u := binary.BigEndian.Uint64(data)
+		switch kind % 8 {
+		case 0:
+			return types.NewIntDatum(int64(u)), true
+		case 1:
+			return types.NewUintDatum(u), true
+		}
+		f := math.Float64frombits(u)
+		// NaN and Inf can't be produced by SQL.
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return types.Datum{}, false
+		}
+		return types.NewFloat64Datum(f), true
+	case 3:
+		dec := new(types.MyDecimal)
+		if err := dec.FromString(data); err != nil {
+			return types.Datum{}, false
+		}
+		return types.NewDecimalDatum(dec), true
+	case 4:
+		return types.NewStringDatum(string(data)), true
+	case 5:
+		t, err := types.ParseTime(sc, string(data), mysql.TypeDatetime, types.MaxFsp)
+		if err != nil {
+			return types.Datum{}, false
+		}
+		return types.NewTimeDatum(t), true
+	case 6:
+		dur, err := types.ParseDuration(sc, string(data), types.MaxFsp)
+		if err != nil {
+			return types.Datum{}, false
+		}
+		return types.NewDurationDatum(dur), true
+	default:
+		j, err := json.ParseBinaryFromBytes(data)
+		if err != nil {
+			return types.Datum{}, false
+		}
+		return types.NewJSONDatum(j), true
+	}
+}
+
+// fuzzCastTargetTypes returns the types FuzzCastEval casts to, they are like the ones
+// built for CAST(... AS ...), so that every evalXX of the cast signatures is exercised.
+func fuzzCastTargetTypes() []*types.FieldType {
+	newTp := func(tp byte, flen, decimal int, flag uint) *types.FieldType {
+		ft := types.NewFieldType(tp)
+		ft.Flen, ft.Decimal, ft.Flag = flen, decimal, flag
+		if types.IsString(tp) {
+			ft.Charset, ft.Collate = mysql.DefaultCharset, mysql.DefaultCollationName
+		} else {
+			types.SetBinChsClnFlag(ft)
+		}
+		return ft
+	}
+	return []*types.FieldType{
+		newTp(mysql.TypeLonglong, mysql.MaxIntWidth, 0, 0),
+		newTp(mysql.TypeLonglong, mysql.MaxIntWidth, 0, mysql.UnsignedFlag),
+		newTp(mysql.TypeDouble, types.UnspecifiedLength, types.UnspecifiedLength, 0),
+		newTp(mysql.TypeNewDecimal, mysql.MaxDecimalWidth, mysql.MaxDecimalScale, 0),
+		newTp(mysql.TypeNewDecimal, 10, 0, 0),
+		newTp(mysql.TypeVarString, types.UnspecifiedLength, types.UnspecifiedLength, 0),
+		newTp(mysql.TypeVarString, 5, types.UnspecifiedLength, 0),
+		newTp(mysql.TypeDatetime, mysql.MaxDatetimeWidthWithFsp, int(types.MaxFsp), 0),
+		newTp(mysql.TypeDate, mysql.MaxDateWidth, 0, 0),
+		newTp(mysql.TypeDuration, mysql.MaxDurationWidthWithFsp, int(types.MaxFsp), 0),
+		newTp(mysql.TypeJSON, types.UnspecifiedLength, 0, 0),
+	}
+}
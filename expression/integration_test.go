@@ -34,6 +34,7 @@ import (
 	"github.com/pingcap/parser/terror"
 	"github.com/pingcap/tidb/ddl/placement"
 	"github.com/pingcap/tidb/domain"
+	"github.com/pingcap/tidb/errno"
 	"github.com/pingcap/tidb/expression"
 	"github.com/pingcap/tidb/kv"
 	plannercore "github.com/pingcap/tidb/planner/core"
@@ -1986,6 +1987,11 @@ func (s *testIntegrationSuite2) TestTimeBuiltin(c *C) {
 	result = tk.MustQuery(`select cast(dayname("2016-03-07") as double), cast(dayname("2016-03-08") as double)`)
 	result.Check(testkit.Rows("0 1"))
 
+	// monthname has no implicit path and is evaluated as a string, as in MySQL
+	result = tk.MustQuery(`select monthname("2016-03-07") + 0`)
+	result.Check(testkit.Rows("0"))
+	tk.MustQuery("show warnings").Check(testkit.Rows("Warning 1292 Truncated incorrect FLOAT value: 'March'"))
+
 	// for sec_to_time
 	result = tk.MustQuery("select sec_to_time(NULL)")
 	result.Check(testkit.Rows("<nil>"))
@@ -3536,6 +3542,7 @@ func (s *testIntegrationSuite) TestArithmeticBuiltin(c *C) {
 		"Warning|1292|Truncated incorrect DECIMAL value: '1.7976931348623157e+308'",
 		"Warning|1292|Truncated incorrect DECIMAL value: '1.7976931348623157e+308'",
 		"Warning|1292|Truncated incorrect DECIMAL value: '-1.7976931348623158e+307'",
+		"Warning|1292|Truncated incorrect DECIMAL value: '1e-82'",
 		"Warning|1365|Division by 0"))
 	rs, err = tk.Exec("select 1e300 DIV 1.5")
 	c.Assert(err, IsNil)
@@ -5488,6 +5495,52 @@ func (s *testIntegrationSuite) TestExprPushdownBlacklist(c *C) {
 	tk.MustExec("admin reload expr_pushdown_blacklist")
 }
 
+func (s *testIntegrationSuite) TestFoldCastChain(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t(a bigint)")
+	tk.MustExec("insert into t values (12), (123), (124)")
+	// Every CAST of a constant is folded when it's built, so the chains are folded bottom up at plan time.
+	sql := "select a from t where a in (cast(cast(cast('123' as decimal) as double) as signed), cast(cast('12' as signed) as signed)) order by a"
+	tk.MustQuery("explain format = 'brief' " + sql).Check(testkit.Rows(
+		"Sort 20.00 root  test.t.a",
+		"└─TableReader 20.00 root  data:Selection",
+		"  └─Selection 20.00 cop[tikv]  in(test.t.a, 123, 12)",
+		"    └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo"))
+	tk.MustQuery(sql).Check(testkit.Rows("12", "123"))
+}
+
+func (s *testIntegrationSuite) TestCastDecimalPushDown(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t(a decimal(20, 10))")
+	tk.MustExec("insert into t values (5.046), (-1.5), (0.4999999999), (0.5), (-0.5000000001), (123456789.9999999999), (0), (12.34), (-7.0000000001), (83959.5)")
+	// The mock store evaluates the pushed down casts with the same code as TiDB,
+	// so only the plans are checked here, the results are TiDB's own.
+	tests := []struct {
+		sql  string
+		cond string
+		res  []string
+	}{
+		{"select a from t where cast(a as signed) > 0 order by a", "gt(cast(test.t.a, bigint(22) BINARY), 0)", []string{"0.5000000000", "5.0460000000", "12.3400000000", "83959.5000000000", "123456789.9999999999"}},
+		{"select a from t where cast(a as signed) = -1 order by a", "eq(cast(test.t.a, bigint(22) BINARY), -1)", []string{"-0.5000000001"}},
+		{"select a from t where cast(a as double) > 0.4999999999 order by a", "gt(cast(test.t.a, double BINARY), 0.4999999999)", []string{"0.5000000000", "5.0460000000", "12.3400000000", "83959.5000000000", "123456789.9999999999"}},
+		{"select a from t where cast(a as double) = 5.046 order by a", "eq(cast(test.t.a, double BINARY), 5.046)", []string{"5.0460000000"}},
+		{"select a from t where cast(a as time) > '00:00:01' order by a", `gt(cast(cast(test.t.a, time BINARY), var_string(10)), "00:00:01")`, []string{"5.0460000000", "12.3400000000", "83959.5000000000"}},
+		{"select a from t where cast(a as decimal(10, 2)) >= 0.50 order by a", "ge(cast(test.t.a, decimal(10,2) BINARY), 0.50)", []string{"0.4999999999", "0.5000000000", "5.0460000000", "12.3400000000", "83959.5000000000", "123456789.9999999999"}},
+	}
+	for _, tt := range tests {
+		tk.MustQuery("explain format = 'brief' " + tt.sql).Check(testkit.Rows(
+			"Sort 8000.00 root  test.t.a",
+			"└─TableReader 8000.00 root  data:Selection",
+			"  └─Selection 8000.00 cop[tikv]  "+tt.cond,
+			"    └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo"))
+		tk.MustQuery(tt.sql).Check(testkit.Rows(tt.res...))
+	}
+}
+
 func (s *testIntegrationSuite) TestOptRuleBlacklist(c *C) {
 	tk := testkit.NewTestKit(c, s.store)
 	tk.MustQuery(`select * from mysql.opt_rule_blacklist`).Check(testkit.Rows())
@@ -9361,3 +9414,461 @@ func (s *testIntegrationSuite) TestEnumIndex(c *C) {
 	tk.MustQuery("select /*+ use_index(t,idx) */ col3 from t where col2 = 'b' and col1 is not null;").Check(
 		testkit.Rows("2"))
 }
+
+func (s *testIntegrationSuite) TestCastJSONAsTime(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("set @@time_zone = '+00:00'")
+	tk.MustQuery("select cast(cast(json_quote('2024-06-01') as json) as date)").Check(testkit.Rows("2024-06-01"))
+	tk.MustQuery("select cast(cast('\"2024-06-01 12:34:56\"' as json) as datetime)").Check(testkit.Rows("2024-06-01 12:34:56"))
+
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (j json)")
+	tk.MustExec(`insert into t values ('"2024-06-01"'), ('1717200000'), (null)`)
+	tk.MustQuery("select cast(j as date), cast(j as datetime) from t").Check(testkit.Rows(
+		"2024-06-01 2024-06-01 00:00:00",
+		"2024-06-01 2024-06-01 00:00:00",
+		"<nil> <nil>",
+	))
+}
+
+func (s *testIntegrationSuite) TestCastYearZeroAsTime(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("set @@sql_mode = ''")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (y year)")
+	tk.MustExec("insert into t values (0), (2001)")
+	tk.MustQuery("select cast(y as datetime) from t where y = 0").Check(testkit.Rows("0000-00-00 00:00:00"))
+	tk.MustQuery("select cast(y as date) from t where y = 0").Check(testkit.Rows("0000-00-00"))
+}
+
+func (s *testIntegrationSuite) TestCastAsEnumInPointUpdate(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	defer tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (id int primary key, e enum('a', 'b'))")
+	tk.MustExec("insert into t values (1, 'a')")
+
+	tk.MustExec("set @@sql_mode = ''")
+	for _, val := range []string{"'zzz'", "'ax'", "'c'"} {
+		tk.MustExec("update t set e = 'b' where id = 1")
+		tk.MustExec("update t set e = " + val + " where id = 1")
+		warnings := tk.Se.GetSessionVars().StmtCtx.GetWarnings()
+		c.Assert(len(warnings), Greater, 0)
+		for _, warn := range warnings {
+			c.Assert(types.ErrTruncated.Equal(warn.Err), IsTrue, Commentf("%v", warn.Err))
+		}
+		tk.MustQuery("select e, e + 0 from t where id = 1").Check(testkit.Rows(" 0"))
+	}
+	tk.MustExec("update t set e = 2 where id = 1")
+	tk.MustQuery("select e from t where id = 1").Check(testkit.Rows("b"))
+
+	tk.MustExec("set @@sql_mode = 'STRICT_TRANS_TABLES'")
+	for _, val := range []string{"'zzz'", "'ax'", "'c'"} {
+		_, err := tk.Exec("update t set e = " + val + " where id = 1")
+		c.Assert(types.ErrTruncated.Equal(err), IsTrue, Commentf("%v", err))
+	}
+	tk.MustQuery("select e from t where id = 1").Check(testkit.Rows("b"))
+}
+
+func (s *testIntegrationSuite) TestCastTimeAsDurationRoundFrac(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a datetime(6))")
+	tk.MustExec("insert into t values ('2020-01-01 12:34:56.123456'), ('2020-01-01 12:34:56.123500'), ('2020-01-01 12:34:56.999500')")
+	tk.MustQuery("select cast(a as time(3)) from t order by a").Check(testkit.Rows("12:34:56.123", "12:34:56.124", "12:34:57.000"))
+	tk.MustQuery("select cast(cast('2020-01-01 12:34:56.123500' as datetime(6)) as time(3))").Check(testkit.Rows("12:34:56.124"))
+	tk.MustQuery("select cast(cast('2020-01-01 12:34:56.123499' as datetime(6)) as time(3))").Check(testkit.Rows("12:34:56.123"))
+	tk.MustQuery("select cast(cast('2020-01-01 23:59:59.999600' as datetime(6)) as time(3))").Check(testkit.Rows("24:00:00.000"))
+	tk.MustQuery("select cast('2020-01-01 23:59:59.999600' as time(3))").Check(testkit.Rows("24:00:00.000"))
+}
+
+func (s *testIntegrationSuite) TestCastStringAsDecimalLocaleAware(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustQuery("select cast('$1,234.56' as decimal(10,2))").Check(testkit.Rows("0.00"))
+
+	tk.MustExec("set @@tidb_enable_locale_aware_cast = 1")
+	defer tk.MustExec("set @@tidb_enable_locale_aware_cast = 0")
+	tk.MustQuery("select cast('$1,234.56' as decimal(10,2)), cast('-$1,000' as decimal(10,2)), cast('$-12.5' as decimal(10,2))").Check(testkit.Rows("1234.56 -1000.00 -12.50"))
+	tk.MustQuery("select cast('$1,234.56' as decimal(10,2))")
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", "Note|1292|Truncated incorrect DECIMAL value: '$1,234.56'"))
+	tk.MustQuery("select cast('1234.56' as decimal(10,2))")
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+
+	tk.MustExec("set @@lc_numeric = 'de_DE'")
+	tk.MustQuery("select cast('1.234,56 €' as decimal(10,2)), cast('-€1.234,5' as decimal(10,2))").Check(testkit.Rows("1234.56 -1234.50"))
+
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a varchar(20))")
+	tk.MustExec("insert into t values ('1.234,56 €'), ('€ 7,5'), (null)")
+	tk.MustQuery("select cast(a as decimal(10,2)) from t").Sort().Check(testkit.Rows("1234.56", "7.50", "<nil>"))
+
+	_, err := tk.Exec("set @@lc_numeric = 'xx_XX'")
+	c.Assert(err, NotNil)
+
+	// The storage layer doesn't know the locale, so the cast isn't pushed down.
+	tk.MustQuery("explain format = 'brief' select * from t where cast(a as decimal(10,2)) > 1").Check(testkit.Rows(
+		"Selection 8000.00 root  gt(cast(test.t.a, decimal(10,2) BINARY), 1)",
+		"└─TableReader 10000.00 root  data:TableFullScan",
+		"  └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo",
+	))
+	tk.MustExec("set @@tidb_enable_locale_aware_cast = 0")
+	tk.MustQuery("explain format = 'brief' select * from t where cast(a as decimal(10,2)) > 1").Check(testkit.Rows(
+		"TableReader 8000.00 root  data:Selection",
+		"└─Selection 8000.00 cop[tikv]  gt(cast(test.t.a, decimal(10,2) BINARY), 1)",
+		"  └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo",
+	))
+}
+
+func (s *testIntegrationSuite) TestCastJSONAsUnsignedBigInt(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a json)")
+	tk.MustExec("insert into t values ('18446744073709551615'), ('9223372036854775808'), ('1')")
+	tk.MustQuery("select cast(a as unsigned) from t").Check(testkit.Rows("18446744073709551615", "9223372036854775808", "1"))
+	tk.MustQuery("select cast(cast('18446744073709551615' as json) as unsigned)").Check(testkit.Rows("18446744073709551615"))
+}
+
+func (s *testIntegrationSuite) TestCastPackedStringAsTime(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a varchar(20))")
+	tk.MustExec("insert into t values ('20240115134500'), ('20240115'), (' 20240115134500 ')")
+	tk.MustQuery("select cast(a as datetime), cast(a as date) from t").Check(testkit.Rows(
+		"2024-01-15 13:45:00 2024-01-15",
+		"2024-01-15 00:00:00 2024-01-15",
+		"2024-01-15 13:45:00 2024-01-15"))
+	tk.MustQuery("select cast('20240115134500' as datetime), cast('20240115' as datetime), cast('20240115134500.123' as datetime(3))").Check(testkit.Rows(
+		"2024-01-15 13:45:00 2024-01-15 00:00:00 2024-01-15 13:45:00.123"))
+	tk.MustQuery("select cast('20241315134500' as datetime)").Check(testkit.Rows("<nil>"))
+}
+
+func (s *testIntegrationSuite) TestCastAsIntOverflowWarnings(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	// Like MySQL, the overflow in CAST(... AS SIGNED/UNSIGNED) is reported as
+	// ER_TRUNCATED_WRONG_VALUE (1292) with the category of the source type.
+	tests := []struct {
+		sql     string
+		result  string
+		warning string
+	}{
+		{"select cast(18446744073709551616 as signed)", "9223372036854775807", "Warning|1292|Truncated incorrect DECIMAL value: '18446744073709551616'"},
+		{"select cast(-9223372036854775809 as signed)", "-9223372036854775808", "Warning|1292|Truncated incorrect DECIMAL value: '-9223372036854775809'"},
+		{"select cast(18446744073709551616 as unsigned)", "18446744073709551615", "Warning|1292|Truncated incorrect DECIMAL value: '18446744073709551616'"},
+		{"select cast('18446744073709551616' as unsigned)", "18446744073709551615", "Warning|1292|Truncated incorrect INTEGER value: '18446744073709551616'"},
+		{"select cast('-9223372036854775809' as signed)", "-9223372036854775808", "Warning|1292|Truncated incorrect INTEGER value: '-9223372036854775809'"},
+	}
+	for _, t := range tests {
+		tk.MustQuery(t.sql).Check(testkit.Rows(t.result))
+		tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", t.warning))
+	}
+}
+
+func (s *testIntegrationSuite) TestCastOverflowAsError(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a double, b varchar(30), c decimal(30, 0))")
+	tk.MustExec("insert into t values (1e20, '18446744073709551616', 18446744073709551616)")
+	sqls := []string{
+		"select cast(18446744073709551616 as signed)",
+		"select cast('18446744073709551616' as unsigned)",
+		"select cast(1e20 as signed)",
+		"select cast(a as signed) from t",
+		"select cast(b as unsigned) from t",
+		"select cast(c as unsigned) from t",
+	}
+
+	// The overflow is reported as a warning by default.
+	for _, sql := range sqls {
+		tk.MustQuery(sql)
+		c.Assert(tk.Se.GetSessionVars().StmtCtx.WarningCount(), Equals, uint16(1), Commentf("%s", sql))
+	}
+
+	tk.MustExec("set @@tidb_cast_overflow_as_error = 1")
+	defer tk.MustExec("set @@tidb_cast_overflow_as_error = default")
+	for _, sql := range sqls {
+		err := tk.QueryToErr(sql)
+		c.Assert(types.ErrOverflow.Equal(err), IsTrue, Commentf("%s: %v", sql, err))
+	}
+	// The casts without overflow are not affected.
+	tk.MustQuery("select cast(9223372036854775807 as signed), cast('18446744073709551615' as unsigned)").Check(
+		testkit.Rows("9223372036854775807 18446744073709551615"))
+
+	// The storage layer always clamps the overflowing value, so the cast isn't pushed down.
+	tk.MustQuery("explain format = 'brief' select * from t where cast(a as signed) > 1").Check(testkit.Rows(
+		"Selection 8000.00 root  gt(cast(test.t.a, bigint(22) BINARY), 1)",
+		"└─TableReader 10000.00 root  data:TableFullScan",
+		"  └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo",
+	))
+	tk.MustExec("set @@tidb_cast_overflow_as_error = 0")
+	tk.MustQuery("explain format = 'brief' select * from t where cast(a as signed) > 1").Check(testkit.Rows(
+		"TableReader 8000.00 root  data:Selection",
+		"└─Selection 8000.00 cop[tikv]  gt(cast(test.t.a, bigint(22) BINARY), 1)",
+		"  └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo",
+	))
+}
+
+func (s *testIntegrationSuite) TestTimestampCastFormat(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a timestamp, b datetime)")
+	tk.MustExec("insert into t values ('2021-03-04 05:06:07', '2021-03-04 05:06:07')")
+	tk.MustQuery("select cast(a as char), cast(b as char) from t").Check(
+		testkit.Rows("2021-03-04 05:06:07 2021-03-04 05:06:07"))
+
+	tk.MustExec("set @@tidb_timestamp_cast_format = '%d/%m/%Y %H.%i'")
+	defer tk.MustExec("set @@tidb_timestamp_cast_format = default")
+	// Only the TIMESTAMP values are formatted by the pattern.
+	tk.MustQuery("select cast(a as char), cast(b as char) from t").Check(
+		testkit.Rows("04/03/2021 05.06 2021-03-04 05:06:07"))
+	tk.MustQuery("select cast(a as char(10)) from t").Check(testkit.Rows("04/03/2021"))
+	tk.MustExec("set @@tidb_enable_vectorized_expression = 0")
+	defer tk.MustExec("set @@tidb_enable_vectorized_expression = default")
+	tk.MustQuery("select cast(a as char), cast(b as char) from t").Check(
+		testkit.Rows("04/03/2021 05.06 2021-03-04 05:06:07"))
+
+	// The storage layer always uses the default format, so the cast of TIMESTAMP isn't pushed down.
+	tk.MustQuery("explain format = 'brief' select * from t where cast(a as char) = '04/03/2021 05.06' and cast(b as char) = '2021-03-04 05:06:07'").Check(testkit.Rows(
+		"Selection 6400.00 root  eq(cast(test.t.a, var_string(5)), \"04/03/2021 05.06\")",
+		"└─TableReader 8000.00 root  data:Selection",
+		"  └─Selection 8000.00 cop[tikv]  eq(cast(test.t.b, var_string(5)), \"2021-03-04 05:06:07\")",
+		"    └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo",
+	))
+	tk.MustQuery("select * from t where cast(a as char) = '04/03/2021 05.06' and cast(b as char) = '2021-03-04 05:06:07'").Check(
+		testkit.Rows("2021-03-04 05:06:07 2021-03-04 05:06:07"))
+	tk.MustExec("set @@tidb_timestamp_cast_format = default")
+	tk.MustQuery("explain format = 'brief' select * from t where cast(a as char) = '2021-03-04 05:06:07'").Check(testkit.Rows(
+		"TableReader 8000.00 root  data:Selection",
+		"└─Selection 8000.00 cop[tikv]  eq(cast(test.t.a, var_string(5)), \"2021-03-04 05:06:07\")",
+		"  └─TableFullScan 10000.00 cop[tikv] table:t keep order:false, stats:pseudo",
+	))
+}
+
+func (s *testIntegrationSuite) TestCastJSONObjectAsDuration(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a json)")
+	tk.MustExec(`insert into t values ('{"hours": 2, "minutes": 30}'), ('{"hours": -1, "seconds": 1.5, "unit": "x"}'), ('{"microseconds": 250}'), ('"12:34:56"')`)
+
+	tk.MustExec("set @@tidb_json_object_as_duration = 1")
+	defer tk.MustExec("set @@tidb_json_object_as_duration = default")
+	for _, vec := range []string{"1", "0"} {
+		tk.MustExec("set @@tidb_enable_vectorized_expression = " + vec)
+		tk.MustQuery("select cast(a as time(6)) from t").Check(
+			testkit.Rows("02:30:00.000000", "-00:59:58.500000", "00:00:00.000250", "12:34:56.000000"))
+		tk.MustQuery("select cast(a as time) from t").Check(
+			testkit.Rows("02:30:00", "-00:59:58", "00:00:00", "12:34:56"))
+	}
+	tk.MustExec("set @@tidb_enable_vectorized_expression = default")
+
+	tk.MustQuery(`select cast(cast('{"hours": 900}' as json) as time)`).Check(testkit.Rows("838:59:59"))
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", `Warning|1292|Truncated incorrect time value: '{"hours": 900}'`))
+	tk.MustQuery(`select cast(cast('{"hours": "2"}' as json) as time)`).Check(testkit.Rows("00:00:00"))
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", `Warning|1292|Truncated incorrect time value: '{"hours": "2"}'`))
+	tk.MustQuery(`select cast(cast('{"days": 2}' as json) as time)`).Check(testkit.Rows("00:00:00"))
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", `Warning|1292|Truncated incorrect time value: '{"days": 2}'`))
+}
+
+func (s *testIntegrationSuite) TestCastStringWithLeadingZerosAsDecimal(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a varchar(20))")
+	tk.MustExec("insert into t values ('00001.10'), ('0000000001.10'), ('-00012.30'), ('000.0')")
+	defer tk.MustExec("set @@tidb_enable_vectorized_expression = default")
+	for _, vec := range []string{"1", "0"} {
+		tk.MustExec("set @@tidb_enable_vectorized_expression = " + vec)
+		// The leading zeros aren't significant digits, neither are the trailing zeros of the fraction,
+		// so the values fit DECIMAL(5,1) without any warning.
+		tk.MustQuery("select cast(a as decimal(5,1)) from t").Check(testkit.Rows("1.1", "1.1", "-12.3", "0.0"))
+		tk.MustQuery("show warnings").Check(testkit.Rows())
+	}
+	// Only the non-zero fractional digits which are rounded off are truncated.
+	tk.MustQuery("select cast('00001.15' as decimal(5,1))").Check(testkit.Rows("1.2"))
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", "Warning|1292|Truncated incorrect DECIMAL value: '1.15'"))
+}
+
+func (s *testIntegrationSuite) TestCastHexStringAsInt(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	// Like MySQL, only the hexadecimal literals are cast as their values, a string
+	// with the "0x" prefix is cast by its valid decimal prefix "0".
+	tk.MustQuery("select cast(0xFF as unsigned), cast(0x0 as unsigned), cast(0xFFFFFFFFFFFFFFFF as unsigned), cast(x'1a' as signed)").Check(
+		testkit.Rows("255 0 18446744073709551615 26"))
+	for _, str := range []string{"0xFF", "0XFF", "0x0", "0xFFFFFFFFFFFFFFFF", "0x1z"} {
+		tk.MustQuery(fmt.Sprintf("select cast('%s' as unsigned), cast('%s' as signed)", str, str)).Check(testkit.Rows("0 0"))
+		tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|",
+			fmt.Sprintf("Warning|1292|Truncated incorrect INTEGER value: '%s'", str),
+			fmt.Sprintf("Warning|1292|Truncated incorrect INTEGER value: '%s'", str)))
+	}
+}
+
+func (s *testIntegrationSuite) TestCastStringWithDaysAsTime(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a varchar(20))")
+	tk.MustExec("insert into t values ('2 13:45:00'), ('34 22:59:59'), ('35 00:00:00'), ('-35 00:00:00.5')")
+	// Like MySQL, the value out of the range of TIME is clamped with a warning instead of NULL.
+	tk.MustQuery("select cast(a as time) from t").Check(testkit.Rows("61:45:00", "838:59:59", "838:59:59", "-838:59:59"))
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|",
+		"Warning|1292|Truncated incorrect time value: '35 00:00:00'",
+		"Warning|1292|Truncated incorrect time value: '-35 00:00:00.5'"))
+	tk.MustQuery("select cast('35 00:00:00' as time), cast('2 13:45:00.5' as time(1))").Check(testkit.Rows("838:59:59 61:45:00.5"))
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", "Warning|1292|Truncated incorrect time value: '35 00:00:00'"))
+}
+
+func (s *testIntegrationSuite) TestCastDurationAsDecimal(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a time(6))")
+	tk.MustExec("insert into t values ('12:34:56.123456'), ('-837:59:59.999999'), ('00:00:00.000001')")
+	tk.MustQuery("select cast(time'12:34:56.123456' as decimal(15,6))").Check(testkit.Rows("123456.123456"))
+	tk.MustQuery("select cast(a as decimal(15,6)), cast(a as decimal(15,3)) from t").Check(testkit.Rows(
+		"123456.123456 123456.123",
+		"-8375959.999999 -8375960.000",
+		"0.000001 0.000"))
+}
+
+func (s *testIntegrationSuite) TestCastTimeAsInt(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a datetime(1), b date, c timestamp(6))")
+	tk.MustExec("insert into t values ('2024-01-15 13:45:00', '2024-01-15', '2024-01-15 13:45:00.499999'), ('2024-01-15 13:45:00.6', '2024-01-15', '2024-12-31 23:59:59.5')")
+	// Like MySQL, the date and time parts are both kept for DATETIME and TIMESTAMP,
+	// and only the fractional seconds are rounded.
+	tk.MustQuery("select cast(a as signed), cast(b as signed), cast(c as unsigned) from t").Check(testkit.Rows(
+		"20240115134500 20240115 20240115134500",
+		"20240115134501 20240115 20250101000000"))
+	tk.MustQuery("select cast(timestamp'2024-01-15 13:45:00' as signed), cast(date'2024-01-15' as signed)").Check(testkit.Rows(
+		"20240115134500 20240115"))
+}
+
+func (s *testIntegrationSuite) TestCastNegativeZeroDecimalAsReal(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a decimal(10, 2))")
+	tk.MustExec("insert into t values (0), (-1.5)")
+	tk.MustQuery("select cast(-0.00 as double), cast(-0.00 as float), cast(-0 as double)").Check(testkit.Rows("0 0 0"))
+	tk.MustQuery("show warnings").Check(testkit.Rows())
+	sql := "select cast(-a as double), cast(a * -1 as double), cast(a * 0 as double) from t"
+	tk.MustQuery(sql).Check(testkit.Rows("0 0 0", "1.5 1.5 0"))
+	tk.MustExec("set @@tidb_enable_vectorized_expression = 0")
+	defer tk.MustExec("set @@tidb_enable_vectorized_expression = default")
+	tk.MustQuery(sql).Check(testkit.Rows("0 0 0", "1.5 1.5 0"))
+}
+
+func (s *testIntegrationSuite) TestInsertTimeColumnIntoDatetime(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	defer s.cleanEnv(c)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t, t1")
+	tk.MustExec("create table t (a time)")
+	tk.MustExec("create table t1 (a datetime)")
+	tk.MustExec("insert into t values (131500), ('13:15:00')")
+	tk.MustQuery("select a from t").Check(testkit.Rows("13:15:00", "13:15:00"))
+	// The TIME column is cast by builtinCastDurationAsTimeSig, the date part is today.
+	tk.MustExec("insert into t1 select a from t")
+	tk.MustQuery("select time(a), date(a) = curdate() from t1").Check(testkit.Rows("13:15:00 1", "13:15:00 1"))
+}
+
+func (s *testIntegrationSuite) TestCastDurationAsTimeSessionTimeZone(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a time)")
+	tk.MustExec("insert into t values ('00:00:01'), ('23:59:59')")
+	defer tk.MustExec("set @@time_zone = default")
+	// The date part is today in the session time zone, the two time zones
+	// are 18 hours apart so they differ in date most of the day.
+	for _, tz := range []string{"+09:00", "-09:00", "+00:00"} {
+		tk.MustExec(fmt.Sprintf("set @@time_zone = '%s'", tz))
+		tk.MustQuery("select date(cast(time'00:00:01' as datetime)) = curdate()").Check(testkit.Rows("1"))
+		tk.MustQuery("select date(cast(a as datetime)) = curdate() from t").Check(testkit.Rows("1", "1"))
+	}
+}
+
+func (s *testIntegrationSuite) TestCastStringWithTimeZoneAsTime(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a varchar(30))")
+	tk.MustExec("insert into t values ('2024-01-15 13:00:00+09:00'), ('2024-01-15T13:00:00Z'), ('2024-01-15 13:00:00')")
+	defer tk.MustExec("set @@time_zone = default")
+	// The time with an offset is converted to the session time zone, the one without is kept.
+	tk.MustExec("set @@time_zone = '+00:00'")
+	tk.MustQuery("select cast('2024-01-15 13:00:00+09:00' as datetime)").Check(testkit.Rows("2024-01-15 04:00:00"))
+	tk.MustQuery("select cast(a as datetime) from t").Check(testkit.Rows(
+		"2024-01-15 04:00:00", "2024-01-15 13:00:00", "2024-01-15 13:00:00"))
+	tk.MustExec("set @@time_zone = '-02:00'")
+	tk.MustQuery("select cast(a as datetime) from t").Check(testkit.Rows(
+		"2024-01-15 02:00:00", "2024-01-15 11:00:00", "2024-01-15 13:00:00"))
+	tk.MustExec("set @@tidb_enable_vectorized_expression = 0")
+	defer tk.MustExec("set @@tidb_enable_vectorized_expression = default")
+	tk.MustQuery("select cast(a as datetime) from t").Check(testkit.Rows(
+		"2024-01-15 02:00:00", "2024-01-15 11:00:00", "2024-01-15 13:00:00"))
+}
+
+func (s *testIntegrationSuite) TestCastAsTimeZeroDateSQLMode(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (d date)")
+
+	// NO_ZERO_DATE rejects the all-zero date only.
+	tk.MustExec("set @@sql_mode = 'STRICT_TRANS_TABLES,NO_ZERO_DATE'")
+	tk.MustGetErrCode("insert into t select cast('0000-00-00' as date)", errno.ErrZeroDateNotAllowed)
+	tk.MustExec("insert into t select cast('2024-00-01' as date)")
+
+	// NO_ZERO_IN_DATE rejects the date with zero month or day only.
+	tk.MustExec("set @@sql_mode = 'STRICT_TRANS_TABLES,NO_ZERO_IN_DATE'")
+	tk.MustExec("insert into t select cast('0000-00-00' as date)")
+	tk.MustGetErrCode("insert into t select cast('2024-00-01' as date)", errno.ErrZeroInDateNotAllowed)
+	tk.MustGetErrCode("insert into t select cast(20240100 as date)", errno.ErrZeroInDateNotAllowed)
+
+	// Only warnings are reported in non-strict mode.
+	tk.MustExec("set @@sql_mode = 'NO_ZERO_DATE,NO_ZERO_IN_DATE'")
+	tk.MustExec("insert into t select cast('0000-00-00' as date)")
+	warnings := tk.Se.GetSessionVars().StmtCtx.GetWarnings()
+	c.Assert(len(warnings) > 0, IsTrue)
+	terr := errors.Cause(warnings[0].Err).(*terror.Error)
+	c.Assert(terr.Code(), Equals, errors.ErrCode(errno.ErrZeroDateNotAllowed))
+	tk.MustQuery("select cast('0000-00-00' as date)").Check(testkit.Rows("0000-00-00"))
+	tk.MustQuery("select * from t").Check(testkit.Rows("2024-00-01", "0000-00-00", "0000-00-00"))
+
+	// The implicit cast of a function argument leaves the zero date to the function.
+	tk.MustExec("insert into t select year('0000-00-00 00:00:00')")
+	tk.MustQuery("show warnings").Check(testutil.RowsWithSep("|", "Warning|1292|Incorrect datetime value: '0000-00-00 00:00:00.000000'"))
+}
+
+func (s *testIntegrationSuite) TestCastBitAsOtherTypes(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustQuery("select cast(b'1010' as unsigned), cast(b'1010' as signed)").Check(testkit.Rows("10 10"))
+
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a bit(4), b bit(64))")
+	tk.MustExec("insert into t values (b'1010', b'1111111111111111111111111111111111111111111111111111111111111111')")
+	tk.MustQuery("select cast(a as unsigned), cast(a as decimal), cast(b as unsigned) from t").Check(
+		testkit.Rows("10 10 18446744073709551615"))
+
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a bit(1), b bit(8), c bit(16), d bit(64))")
+	tk.MustExec("insert into t values (b'1', b'10101010', 0x1234, 0xffffffffffffffff), (b'0', b'1', 0x12, 1)")
+	tk.MustQuery("select hex(cast(a as char)), hex(cast(b as char)), hex(cast(c as char)), hex(cast(d as char)) from t").Sort().Check(testkit.Rows(
+		"00 01 0012 0000000000000001",
+		"01 AA 1234 FFFFFFFFFFFFFFFF"))
+	tk.MustExec("drop table if exists t")
+	tk.MustGetErrCode("create table t (a bit(65))", mysql.ErrTooBigDisplaywidth)
+}
@@ -224,12 +224,15 @@ func newFunctionImpl(ctx sessionctx.Context, fold int, funcName string, retType
 		Function: f,
 	}
 	if fold == 1 {
-		return FoldConstant(sf), nil
+		return FoldConstantWithTimeout(ctx, sf, ctx.GetSessionVars().GetConstantFoldTimeout())
 	} else if fold == -1 {
 		// try to fold constants, and return the original function if errors/warnings occur
 		sc := ctx.GetSessionVars().StmtCtx
 		beforeWarns := sc.WarningCount()
-		newSf := FoldConstant(sf)
+		newSf, err := FoldConstantWithTimeout(ctx, sf, ctx.GetSessionVars().GetConstantFoldTimeout())
+		if err != nil {
+			return nil, err
+		}
 		afterWarns := sc.WarningCount()
 		if afterWarns > beforeWarns {
 			sc.TruncateWarnings(int(beforeWarns))
@@ -284,6 +287,13 @@ func (sf *ScalarFunction) Clone() Expression {
 	return c
 }
 
+// CloneWithNewContext implements Expression interface.
+func (sf *ScalarFunction) CloneWithNewContext(ctx sessionctx.Context) Expression {
+	c := sf.Clone().(*ScalarFunction)
+	c.Function.setCtx(ctx)
+	return c
+}
+
 // GetType implements Expression interface.
 func (sf *ScalarFunction) GetType() *types.FieldType {
 	return sf.RetType
@@ -363,6 +373,10 @@ func (sf *ScalarFunction) Eval(row chunk.Row) (d types.Datum, err error) {
 		str, isNull, err = sf.EvalString(sf.GetCtx(), row)
 		if !isNull && err == nil && tp.Tp == mysql.TypeEnum {
 			res, err = types.ParseEnumName(tp.Elems, str, tp.Collate)
+			if err != nil && str == "" {
+				// The invalid value is truncated to the empty string, whose index is 0, like builtinCastAsEnumSig.
+				res, err = types.Enum{}, nil
+			}
 		} else {
 			res = str
 		}
@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/tidb/sessionctx/stmtctx"
 	"github.com/pingcap/tidb/types"
 	"github.com/pingcap/tidb/util/chunk"
+	"github.com/pingcap/tidb/util/mock"
 )
 
 func (s *testEvaluatorSuite) TestScalarFunction(c *C) {
@@ -77,3 +78,38 @@ func (s *testEvaluatorSuite) TestScalarFuncs2Exprs(c *C) {
 		c.Assert(exprs[i].Equal(s.ctx, funcs[i]), IsTrue)
 	}
 }
+
+func (s *testEvaluatorSuite) TestCloneWithNewContext(c *C) {
+	origCtx := mock.NewContext()
+	origCtx.GetSessionVars().PreparedParams = []types.Datum{types.NewIntDatum(1)}
+	a := &Column{
+		UniqueID: 1,
+		RetType:  types.NewFieldType(mysql.TypeDouble),
+	}
+	param := &Constant{
+		RetType:     types.NewFieldType(mysql.TypeLonglong),
+		ParamMarker: &ParamMarker{ctx: origCtx, order: 0},
+	}
+	plus := NewFunctionInternal(origCtx, ast.Plus, types.NewFieldType(mysql.TypeDouble), a, param)
+	sf := BuildCastFunction(origCtx, plus, types.NewFieldType(mysql.TypeVarString)).(*ScalarFunction)
+
+	ctx := mock.NewContext()
+	ctx.GetSessionVars().PreparedParams = []types.Datum{types.NewIntDatum(1)}
+	cloned, ok := sf.CloneWithNewContext(ctx).(*ScalarFunction)
+	c.Assert(ok, IsTrue)
+	c.Assert(cloned.Equal(origCtx, sf), IsTrue)
+	_, ok = cloned.Function.(*builtinCastRealAsStringSig)
+	c.Assert(ok, IsTrue)
+	c.Assert(cloned.GetCtx(), Equals, ctx)
+	clonedPlus := cloned.GetArgs()[0].(*ScalarFunction)
+	c.Assert(clonedPlus.GetCtx(), Equals, ctx)
+	// The param marker is wrapped by a deferred cast to match the type of the plus.
+	deferred := clonedPlus.GetArgs()[1].(*Constant).DeferredExpr.(*ScalarFunction)
+	c.Assert(deferred.GetCtx(), Equals, ctx)
+	c.Assert(deferred.GetArgs()[0].(*Constant).ParamMarker.ctx, Equals, ctx)
+
+	// The original expression is still bound to its own context.
+	c.Assert(sf.GetCtx(), Equals, origCtx)
+	c.Assert(plus.(*ScalarFunction).GetCtx(), Equals, origCtx)
+	c.Assert(param.ParamMarker.ctx, Equals, origCtx)
+}
@@ -1083,7 +1083,11 @@ func (er *expressionRewriter) Leave(originInNode ast.Node) (retNode ast.Node, ok
 			arg.SetCoercibility(expression.CoercibilityImplicit)
 		}
 
-		er.ctxStack[len(er.ctxStack)-1] = expression.BuildCastFunction(er.sctx, arg, v.Tp)
+		cast := expression.BuildCastFunction(er.sctx, arg, v.Tp)
+		if isCastOfBaseColumn(cast) {
+			cast = elideNoopCast(expression.RewriteCastChain(cast))
+		}
+		er.ctxStack[len(er.ctxStack)-1] = cast
 		er.ctxNameStk[len(er.ctxNameStk)-1] = types.EmptyName
 	case *ast.PatternLikeExpr:
 		er.patternLikeToExpression(v)
@@ -1176,6 +1180,40 @@ func (er *expressionRewriter) checkTimePrecision(ft *types.FieldType) error {
 	return nil
 }
 
+// isCastOfBaseColumn checks whether expr is a CAST, or nested CASTs, of a base table column. The columns
+// of base tables have positive IDs. Only their types are reliable, see expression.CanElideCast.
+func isCastOfBaseColumn(expr expression.Expression) bool {
+	f, ok := expr.(*expression.ScalarFunction)
+	if !ok || f.FuncName.L != ast.Cast {
+		return false
+	}
+	for ok && f.FuncName.L == ast.Cast {
+		expr = f.GetArgs()[0]
+		f, ok = expr.(*expression.ScalarFunction)
+	}
+	col, ok := expr.(*expression.Column)
+	return ok && col.ID > 0
+}
+
+// elideNoopCast removes the CAST of a base table column if it doesn't change the value, e.g.
+// CAST(a AS DECIMAL(10,2)) where a is a DECIMAL(10,2) column. The columns of base tables have
+// positive IDs, the other columns, like the output of projections, are kept as their types
+// inferred may be narrower than their values. A copy of the column with the type of the cast
+// is returned, so the flags of the column, like PriKeyFlag and NotNullFlag, don't leak.
+func elideNoopCast(expr expression.Expression) expression.Expression {
+	f, ok := expr.(*expression.ScalarFunction)
+	if !ok || f.FuncName.L != ast.Cast {
+		return expr
+	}
+	col, ok := f.GetArgs()[0].(*expression.Column)
+	if !ok || col.ID <= 0 || !expression.CanElideCast(col.RetType, f.RetType) {
+		return expr
+	}
+	newCol := col.Clone().(*expression.Column)
+	newCol.RetType = f.RetType
+	return newCol
+}
+
 func (er *expressionRewriter) useCache() bool {
 	return er.sctx.GetSessionVars().StmtCtx.UseCache
 }
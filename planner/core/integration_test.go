@@ -129,6 +129,69 @@ func (s *testIntegrationSuite) TestShowSubquery(c *C) {
 	))
 }
 
+func (s *testIntegrationSuite) TestRewriteCastChain(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (a int, b varchar(20))")
+	tk.MustExec("insert into t values (1, '1.5'), (-2, '-2.5')")
+	countCasts := func(sql string) int {
+		return strings.Count(fmt.Sprint(tk.MustQuery("explain "+sql).Rows()), "cast(")
+	}
+	sql := "select cast(cast(cast(a as decimal(20,0)) as double) as signed) from t"
+	c.Assert(countCasts(sql), Equals, 1)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-2", "1"))
+	// The values of b are changed by the intermediate casts, so the chain is kept.
+	sql = "select cast(cast(cast(b as decimal(20,1)) as double) as signed) from t"
+	c.Assert(countCasts(sql), Equals, 3)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-2", "2"))
+	// The derived table outputs the column of t as is, so its type is reliable.
+	sql = "select cast(cast(cast(x as decimal(20,0)) as double) as signed) from (select a as x from t) s"
+	c.Assert(countCasts(sql), Equals, 1)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-2", "1"))
+	// The type of the output column of a union is inferred, so the chain is kept.
+	sql = "select cast(cast(cast(x as decimal(20,0)) as double) as signed) from (select a as x from t union all select a from t) s"
+	c.Assert(countCasts(sql), Equals, 3)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-2", "-2", "1", "1"))
+}
+
+func (s *testIntegrationSuite) TestElideNoopCast(c *C) {
+	tk := testkit.NewTestKit(c, s.store)
+	tk.MustExec("use test")
+	tk.MustExec("drop table if exists t")
+	tk.MustExec("create table t (d decimal(10,2), s varchar(20))")
+	tk.MustExec("insert into t values (1.25, 'a'), (-3.5, 'b')")
+	countCasts := func(sql string) int {
+		return strings.Count(fmt.Sprint(tk.MustQuery("explain "+sql).Rows()), "cast(")
+	}
+	sql := "select cast(d as decimal(10,2)) from t"
+	c.Assert(countCasts(sql), Equals, 0)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-3.50", "1.25"))
+	sql = "select cast(d as decimal(10,1)) from t"
+	c.Assert(countCasts(sql), Equals, 1)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-3.5", "1.3"))
+	// The derived table outputs the column of t as is, so its type is reliable.
+	sql = "select cast(x as decimal(10,2)) from (select d as x from t) s"
+	c.Assert(countCasts(sql), Equals, 0)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-3.50", "1.25"))
+	// The type of the output column of a union is inferred, so the cast is kept.
+	sql = "select cast(x as decimal(10,2)) from (select d as x from t union all select d from t) s"
+	c.Assert(countCasts(sql), Equals, 1)
+	tk.MustQuery(sql).Sort().Check(testkit.Rows("-3.50", "-3.50", "1.25", "1.25"))
+
+	// The result of the elided cast has the type of the cast, not the flags of the column.
+	tk.MustExec("drop table if exists t1")
+	tk.MustExec("create table t1 (d decimal(10,2) primary key)")
+	sql = "select cast(d as decimal(10,2)) from t1"
+	c.Assert(countCasts(sql), Equals, 0)
+	rs, err := tk.Exec(sql)
+	c.Assert(err, IsNil)
+	flag := rs.Fields()[0].Column.Flag
+	c.Assert(mysql.HasPriKeyFlag(flag), IsFalse)
+	c.Assert(mysql.HasNotNullFlag(flag), IsFalse)
+	c.Assert(rs.Close(), IsNil)
+}
+
 func (s *testIntegrationSuite) TestPpdWithSetVar(c *C) {
 	tk := testkit.NewTestKit(c, s.store)
 	tk.MustExec("use test")
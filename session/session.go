@@ -539,13 +539,16 @@ func (s *session) doCommit(ctx context.Context) error {
 			}
 			for iter.Valid() && iter.Key().HasPrefix(seekKey) {
 				if err = memBuffer.Delete(iter.Key()); err != nil {
+					iter.Close()
 					return errors.Trace(err)
 				}
 				s.txn.UpdateEntriesCountAndSize()
 				if err = iter.Next(); err != nil {
+					iter.Close()
 					return errors.Trace(err)
 				}
 			}
+			iter.Close()
 		}
 	}
 
@@ -2565,6 +2568,10 @@ var builtinGlobalVariable = []string{
 	variable.TiDBDisableTxnAutoRetry,
 	variable.TiDBEnableWindowFunction,
 	variable.TiDBEnableStrictDoubleTypeCheck,
+	variable.TiDBCastOverflowAsError,
+	variable.TiDBTimestampCastFormat,
+	variable.TiDBConstantFoldTimeoutMs,
+	variable.TiDBJSONObjectAsDuration,
 	variable.TiDBEnableTablePartition,
 	variable.TiDBEnableVectorizedExpression,
 	variable.TiDBEnableFastAnalyze,
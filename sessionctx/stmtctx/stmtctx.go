@@ -164,6 +164,10 @@ type StatementContext struct {
 	TblInfo2UnionScan     map[*model.TableInfo]bool
 	TaskID                uint64 // unique ID for an execution of a statement
 	TaskMapBakTS          uint64 // counter for
+	// ConstantFoldDeadline is the deadline of folding the current constant expression, it's zero if unlimited.
+	ConstantFoldDeadline time.Time
+	// ConstantFoldTimedOut indicates whether the ConstantFoldDeadline has passed while folding the current constant expression.
+	ConstantFoldTimedOut bool
 }
 
 // StmtHints are SessionVars related sql hints.
@@ -599,6 +599,18 @@ type SessionVars struct {
 	// EnableStrictDoubleTypeCheck enables table field double type check.
 	EnableStrictDoubleTypeCheck bool
 
+	// CastOverflowAsError makes an overflowing CAST return an error instead of the clamped value.
+	CastOverflowAsError bool
+
+	// TimestampCastFormat is the DATE_FORMAT pattern used to cast TIMESTAMP values as strings if it's not empty.
+	TimestampCastFormat string
+
+	// ConstantFoldTimeoutMs is the time limit of folding a constant expression, in milliseconds.
+	ConstantFoldTimeoutMs uint64
+
+	// JSONObjectAsDuration makes a JSON object cast as duration from its fields like "hours" and "minutes".
+	JSONObjectAsDuration bool
+
 	// EnableVectorizedExpression  enables the vectorized expression evaluation.
 	EnableVectorizedExpression bool
 
@@ -836,6 +848,13 @@ type SessionVars struct {
 	// CTEMaxRecursionDepth indicates The common table expression (CTE) maximum recursion depth.
 	// see https://dev.mysql.com/doc/refman/8.0/en/server-system-variables.html#sysvar_cte_max_recursion_depth
 	CTEMaxRecursionDepth int
+
+	// EnableLocaleAwareCast indicates whether to strip the currency symbol and thousands separators of
+	// LcNumeric when casting a string as decimal.
+	EnableLocaleAwareCast bool
+
+	// LcNumeric is the locale used by the locale aware cast.
+	LcNumeric string
 }
 
 // AllocMPPTaskID allocates task id for mpp tasks. It will reset the task id if the query's
@@ -1027,6 +1046,7 @@ func NewSessionVars() *SessionVars {
 		SlowQueryFile:               config.GetGlobalConfig().Log.SlowQueryFile,
 		WaitSplitRegionFinish:       DefTiDBWaitSplitRegionFinish,
 		WaitSplitRegionTimeout:      DefWaitSplitRegionTimeout,
+		ConstantFoldTimeoutMs:       DefTiDBConstantFoldTimeoutMs,
 		enableIndexMerge:            false,
 		EnableNoopFuncs:             DefTiDBEnableNoopFuncs,
 		replicaRead:                 tikvstore.ReplicaReadLeader,
@@ -1064,6 +1084,8 @@ func NewSessionVars() *SessionVars {
 		EnableIndexMergeJoin:        DefTiDBEnableIndexMergeJoin,
 		AllowFallbackToTiKV:         make(map[kv.StoreType]struct{}),
 		CTEMaxRecursionDepth:        DefCTEMaxRecursionDepth,
+		EnableLocaleAwareCast:       DefTiDBEnableLocaleAwareCast,
+		LcNumeric:                   DefLcNumeric,
 	}
 	vars.KVVars = tikvstore.NewVariables(&vars.Killed)
 	vars.Concurrency = Concurrency{
@@ -1201,6 +1223,11 @@ func (s *SessionVars) GetSplitRegionTimeout() time.Duration {
 	return time.Duration(s.WaitSplitRegionTimeout) * time.Second
 }
 
+// GetConstantFoldTimeout gets the time limit of folding a constant expression.
+func (s *SessionVars) GetConstantFoldTimeout() time.Duration {
+	return time.Duration(s.ConstantFoldTimeoutMs) * time.Millisecond
+}
+
 // GetIsolationReadEngines gets isolation read engines.
 func (s *SessionVars) GetIsolationReadEngines() map[kv.StoreType]struct{} {
 	return s.IsolationReadEngines
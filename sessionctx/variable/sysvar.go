@@ -1080,6 +1080,22 @@ var defaultSysVars = []*SysVar{
 		s.EnableStrictDoubleTypeCheck = TiDBOptOn(val)
 		return nil
 	}},
+	{Scope: ScopeGlobal | ScopeSession, Name: TiDBCastOverflowAsError, Value: BoolToOnOff(DefTiDBCastOverflowAsError), Type: TypeBool, SetSession: func(s *SessionVars, val string) error {
+		s.CastOverflowAsError = TiDBOptOn(val)
+		return nil
+	}},
+	{Scope: ScopeGlobal | ScopeSession, Name: TiDBTimestampCastFormat, Value: DefTiDBTimestampCastFormat, Type: TypeStr, SetSession: func(s *SessionVars, val string) error {
+		s.TimestampCastFormat = val
+		return nil
+	}},
+	{Scope: ScopeGlobal | ScopeSession, Name: TiDBConstantFoldTimeoutMs, Value: strconv.Itoa(DefTiDBConstantFoldTimeoutMs), Type: TypeUnsigned, MinValue: 0, MaxValue: math.MaxInt32, SetSession: func(s *SessionVars, val string) error {
+		s.ConstantFoldTimeoutMs = uint64(tidbOptInt64(val, DefTiDBConstantFoldTimeoutMs))
+		return nil
+	}},
+	{Scope: ScopeGlobal | ScopeSession, Name: TiDBJSONObjectAsDuration, Value: BoolToOnOff(DefTiDBJSONObjectAsDuration), Type: TypeBool, SetSession: func(s *SessionVars, val string) error {
+		s.JSONObjectAsDuration = TiDBOptOn(val)
+		return nil
+	}},
 	{Scope: ScopeGlobal | ScopeSession, Name: TiDBEnableVectorizedExpression, Value: BoolToOnOff(DefEnableVectorizedExpression), Type: TypeBool, SetSession: func(s *SessionVars, val string) error {
 		s.EnableVectorizedExpression = TiDBOptOn(val)
 		return nil
@@ -1457,6 +1473,14 @@ var defaultSysVars = []*SysVar{
 		s.TiDBEnableExchangePartition = TiDBOptOn(val)
 		return nil
 	}},
+	{Scope: ScopeGlobal | ScopeSession, Name: TiDBEnableLocaleAwareCast, Value: BoolToOnOff(DefTiDBEnableLocaleAwareCast), Type: TypeBool, SetSession: func(s *SessionVars, val string) error {
+		s.EnableLocaleAwareCast = TiDBOptOn(val)
+		return nil
+	}},
+	{Scope: ScopeGlobal | ScopeSession, Name: LcNumeric, Value: DefLcNumeric, Type: TypeEnum, PossibleValues: []string{"en_US", "en_GB", "de_DE", "fr_FR", "ja_JP", "zh_CN"}, SetSession: func(s *SessionVars, val string) error {
+		s.LcNumeric = val
+		return nil
+	}},
 	{Scope: ScopeNone, Name: TiDBEnableEnhancedSecurity, Value: Off, Type: TypeBool},
 
 	/* tikv gc metrics */
@@ -408,6 +408,22 @@ const (
 	// tidb_enable_strict_double_type_check is used to control table field double type syntax check.
 	TiDBEnableStrictDoubleTypeCheck = "tidb_enable_strict_double_type_check"
 
+	// tidb_cast_overflow_as_error is used to control whether an overflowing CAST returns an error
+	// instead of the clamped value with a warning.
+	TiDBCastOverflowAsError = "tidb_cast_overflow_as_error"
+
+	// tidb_timestamp_cast_format is the DATE_FORMAT pattern used to cast TIMESTAMP values as strings,
+	// the default format is used if it's empty.
+	TiDBTimestampCastFormat = "tidb_timestamp_cast_format"
+
+	// tidb_constant_fold_timeout_ms is the time limit in milliseconds of folding a constant expression, the statement
+	// fails if it's exceeded. 0 means no limit.
+	TiDBConstantFoldTimeoutMs = "tidb_constant_fold_timeout_ms"
+
+	// tidb_json_object_as_duration makes a JSON object like {"hours": 2, "minutes": 30} cast as duration
+	// from its fields, instead of parsing the object as a string.
+	TiDBJSONObjectAsDuration = "tidb_json_object_as_duration"
+
 	// tidb_enable_vectorized_expression is used to control whether to enable the vectorized expression evaluation.
 	TiDBEnableVectorizedExpression = "tidb_enable_vectorized_expression"
 
@@ -533,6 +549,13 @@ const (
 
 	// TiDBEnableDynamicPrivileges enables MySQL 8.0 compatible dynamic privileges (experimental).
 	TiDBEnableDynamicPrivileges = "tidb_enable_dynamic_privileges"
+
+	// TiDBEnableLocaleAwareCast indicates whether to strip the currency symbol and thousands separators
+	// defined by lc_numeric when casting a string as decimal.
+	TiDBEnableLocaleAwareCast = "tidb_enable_locale_aware_cast"
+
+	// LcNumeric is the locale used by the locale aware cast.
+	LcNumeric = "lc_numeric"
 )
 
 // TiDB vars that have only global scope
@@ -636,6 +659,10 @@ const (
 	DefTiDBUseRadixJoin                = false
 	DefEnableWindowFunction            = true
 	DefEnableStrictDoubleTypeCheck     = true
+	DefTiDBCastOverflowAsError         = false
+	DefTiDBTimestampCastFormat         = ""
+	DefTiDBConstantFoldTimeoutMs       = 100
+	DefTiDBJSONObjectAsDuration        = false
 	DefEnableVectorizedExpression      = true
 	DefTiDBOptJoinReorderThreshold     = 0
 	DefTiDBDDLSlowOprThreshold         = 300
@@ -675,6 +702,8 @@ const (
 	DefTiDBEnableIndexMergeJoin        = false
 	DefTiDBTrackAggregateMemoryUsage   = true
 	DefTiDBEnableExchangePartition     = false
+	DefTiDBEnableLocaleAwareCast       = false
+	DefLcNumeric                       = "en_US"
 	DefCTEMaxRecursionDepth            = 1000
 )
 
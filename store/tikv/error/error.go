@@ -31,6 +31,10 @@ var (
 	ErrNotExist = errors.New("not exist")
 	// ErrCannotSetNilValue is the error when sets an empty value.
 	ErrCannotSetNilValue = errors.New("can not set nil value")
+	// ErrInvalidCheckpoint is the error when rolls back the MemDB to an invalid checkpoint.
+	ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+	// ErrAliveIterators is the error when compacts the MemDB while its iterators are not closed.
+	ErrAliveIterators = errors.New("cannot compact the arena of MemDB with alive iterators")
 	// ErrInvalidTxn is the error when commits or rollbacks in an invalid transaction.
 	ErrInvalidTxn = errors.New("invalid transaction")
 	// ErrTiKVServerTimeout is the error when tikv server is timeout.
@@ -165,6 +169,15 @@ func (e *ErrEntryTooLarge) Error() string {
 	return fmt.Sprintf("entry size too large, size: %v,limit: %v.", e.Size, e.Limit)
 }
 
+// ErrMergeConflict is the error when a key to merge already has a value in the target MemDB.
+type ErrMergeConflict struct {
+	Key []byte
+}
+
+func (e *ErrMergeConflict) Error() string {
+	return fmt.Sprintf("merge conflict, key: %q.", e.Key)
+}
+
 // ErrPDServerTimeout is the error when pd server is timeout.
 type ErrPDServerTimeout struct {
 	msg string
@@ -7,6 +7,12 @@ import (
 	"github.com/pingcap/tidb/store/tikv/util"
 )
 
+// Pair is a key-value pair.
+type Pair struct {
+	Key   []byte
+	Value []byte
+}
+
 // ReturnedValue pairs the Value and AlreadyLocked flag for PessimisticLock return values result.
 type ReturnedValue struct {
 	Value         []byte
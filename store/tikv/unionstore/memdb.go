@@ -15,11 +15,18 @@ package unionstore
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
 	"math"
+	"math/bits"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 
+	"github.com/pingcap/errors"
 	tikverr "github.com/pingcap/tidb/store/tikv/error"
 	"github.com/pingcap/tidb/store/tikv/kv"
 )
@@ -60,20 +67,67 @@ type MemDB struct {
 	entrySizeLimit  uint64
 	bufferSizeLimit uint64
 	count           int
+	liveCount       int
 	size            int
+	keySize         int
 
 	vlogInvalid bool
 	dirty       bool
 	stages      []memdbCheckpoint
+
+	// savepoints is the checkpoints created by Checkpoint in order, lastCheckpointID is the ID of the latest one.
+	savepoints       []memdbSavepoint
+	lastCheckpointID CheckpointID
+	// namedCheckpoints is the checkpoints created by NamedCheckpoint, it's name => ID.
+	namedCheckpoints map[string]CheckpointID
+
+	// snapshotCp is the checkpoint of the latest snapshot taken by Snapshot, the values before it aren't overwritten
+	// in place, so that the snapshot keeps seeing them.
+	snapshotCp *memdbCheckpoint
+
+	// expireAt is the expiration time of the keys set by SetWithTTL, it's nil until the first call.
+	expireAt map[string]time.Time
+	// hasExpireAt is 1 once expireAt is created, so that Get needn't take the read lock to check the expiration if it's 0.
+	hasExpireAt int32
+	// now returns the current time to check the expiration, it's replaced by tests.
+	now func() time.Time
+
+	// watchers is the subscribers of the keys watched by Watch, it's string(key) => *memdbWatchList.
+	watchers sync.Map
+	// watchedKeys is the number of keys in watchers, so that the writes needn't look up watchers if it's 0.
+	watchedKeys int32
+	// aliveIters is the number of the iterators returned by Iter, IterReverse and PrefixScan which are not closed.
+	aliveIters int32
+	// liveCntEnabled is 1 if the live counts of the nodes are maintained. They're built by the first RangeCount
+	// or KeyAt, so the writes don't walk up the tree to update them unless they're used.
+	liveCntEnabled int32
+}
+
+// ArenaOptions is the options of the arenas of a MemDB.
+type ArenaOptions struct {
+	// Alignment is the boundary the values are aligned to in the value log, so that the values can be
+	// loaded by the aligned SIMD instructions directly, e.g. 16 for SSE2 and 32 for AVX2. It must be 0
+	// or a power of 2 no larger than 4096, 0 and 1 mean the values aren't aligned, which takes no padding.
+	// The nodes are always aligned to 8 bytes.
+	Alignment int
 }
 
 func newMemDB() *MemDB {
+	return newMemDBWithOptions(ArenaOptions{})
+}
+
+func newMemDBWithOptions(opts ArenaOptions) *MemDB {
+	if opts.Alignment < 0 || opts.Alignment > initBlockSize || opts.Alignment&(opts.Alignment-1) != 0 {
+		panic(fmt.Sprintf("invalid alignment %d", opts.Alignment))
+	}
 	db := new(MemDB)
+	db.vlog.alignment = opts.Alignment
 	db.allocator.init()
 	db.root = nullAddr
 	db.stages = make([]memdbCheckpoint, 0, 2)
 	db.entrySizeLimit = math.MaxUint64
 	db.bufferSizeLimit = math.MaxUint64
+	db.now = time.Now
 	return db
 }
 
@@ -127,9 +181,451 @@ func (db *MemDB) Cleanup(h int) {
 		if !curr.isSamePosition(cp) {
 			db.vlog.revertToCheckpoint(db, cp)
 			db.vlog.truncate(cp)
+			db.discardSavepointsAfter(cp)
 		}
 	}
 	db.stages = db.stages[:h-1]
+	db.maybeCompact()
+}
+
+// CheckpointID identifies a checkpoint created by MemDB.Checkpoint.
+type CheckpointID uint64
+
+type memdbSavepoint struct {
+	id CheckpointID
+	cp memdbCheckpoint
+}
+
+// Checkpoint records the current state of the values for the SQL savepoints, RollbackTo reverts the values to it later.
+// Unlike Staging, it needn't be released, the writes after it are still in the current staging buffer.
+// The checkpoint becomes invalid if the writes before it are discarded, i.e. by Cleanup, RollbackTo an earlier checkpoint or Reset.
+func (db *MemDB) Checkpoint() CheckpointID {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.Lock()
+	defer db.Unlock()
+	db.lastCheckpointID++
+	db.savepoints = append(db.savepoints, memdbSavepoint{id: db.lastCheckpointID, cp: db.vlog.checkpoint()})
+	return db.lastCheckpointID
+}
+
+// RollbackTo discards the value changes after the checkpoint id by replaying the value log in reverse, like Cleanup.
+// The flags are not rollbackable, the same as Cleanup. The checkpoint is kept, so it's possible to roll back to it again,
+// but the checkpoints after it become invalid.
+// It returns ErrInvalidCheckpoint if the checkpoint is invalid or earlier than the current staging buffer.
+func (db *MemDB) RollbackTo(id CheckpointID) error {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.Lock()
+	defer db.Unlock()
+	i := sort.Search(len(db.savepoints), func(i int) bool { return db.savepoints[i].id >= id })
+	if i == len(db.savepoints) || db.savepoints[i].id != id {
+		return tikverr.ErrInvalidCheckpoint
+	}
+	cp := db.savepoints[i].cp
+	if len(db.stages) > 0 && cp.isBefore(&db.stages[len(db.stages)-1]) {
+		return tikverr.ErrInvalidCheckpoint
+	}
+	curr := db.vlog.checkpoint()
+	if !curr.isSamePosition(&cp) {
+		db.vlog.revertToCheckpoint(db, &cp)
+		db.vlog.truncate(&cp)
+	}
+	db.savepoints = db.savepoints[:i+1]
+	db.maybeCompact()
+	return nil
+}
+
+// NamedCheckpoint creates a checkpoint by Checkpoint and names it, so that the SQL savepoint can be rolled back
+// to by its name in the later statements. The checkpoint of the same name is replaced, like SAVEPOINT of MySQL.
+// The name is compared as is, the caller should normalize it if it's case-insensitive.
+func (db *MemDB) NamedCheckpoint(name string) CheckpointID {
+	id := db.Checkpoint()
+	db.Lock()
+	defer db.Unlock()
+	if db.namedCheckpoints == nil {
+		db.namedCheckpoints = make(map[string]CheckpointID)
+	}
+	db.namedCheckpoints[name] = id
+	return id
+}
+
+// RollbackToNamed rolls back to the checkpoint created by NamedCheckpoint with name like RollbackTo.
+// The name is kept, but the names of the checkpoints after it are removed, like ROLLBACK TO SAVEPOINT of MySQL.
+// It returns ErrInvalidCheckpoint if there is no checkpoint of the name or the checkpoint is invalid.
+func (db *MemDB) RollbackToNamed(name string) error {
+	db.RLock()
+	id, ok := db.namedCheckpoints[name]
+	db.RUnlock()
+	if !ok {
+		return tikverr.ErrInvalidCheckpoint
+	}
+	if err := db.RollbackTo(id); err != nil {
+		return err
+	}
+	db.Lock()
+	defer db.Unlock()
+	for n, i := range db.namedCheckpoints {
+		if i > id {
+			delete(db.namedCheckpoints, n)
+		}
+	}
+	return nil
+}
+
+// discardSavepointsAfter removes the savepoints invalidated by truncating the value log to cp.
+func (db *MemDB) discardSavepointsAfter(cp *memdbCheckpoint) {
+	i := len(db.savepoints)
+	for i > 0 && cp.isBefore(&db.savepoints[i-1].cp) {
+		i--
+	}
+	db.savepoints = db.savepoints[:i]
+}
+
+// maybeCompact compacts the MemDB if the freed nodes take a quarter of the node arena. It's skipped if there are
+// staging buffers or alive iterators, since compact invalidates them, the freed nodes are reclaimed by a later call.
+func (db *MemDB) maybeCompact() {
+	if len(db.stages) == 0 && atomic.LoadInt32(&db.aliveIters) == 0 && db.allocator.needCompact() {
+		db.compact()
+	}
+}
+
+// compact copies all alive nodes to a new arena to reclaim the memory of freed nodes,
+// and updates the node addresses stored in vlog.
+// It invalidates all MemKeyHandles and iterators, so it's only called when there are no staging buffers.
+func (db *MemDB) compact() {
+	var allocator nodeAllocator
+	allocator.init()
+	addrMap := make(map[memdbArenaAddr]memdbArenaAddr, db.count)
+	db.root = db.copySubtree(&allocator, db.root, nullAddr, addrMap)
+	if !db.vlogInvalid {
+		db.vlog.relocateNodes(addrMap)
+	}
+	db.allocator = allocator
+}
+
+func (db *MemDB) copySubtree(dst *nodeAllocator, addr, up memdbArenaAddr, addrMap map[memdbArenaAddr]memdbArenaAddr) memdbArenaAddr {
+	if addr.isNull() {
+		return nullAddr
+	}
+	old := db.allocator.getNode(addr)
+	newAddr, n := dst.allocNode(old.getKey())
+	addrMap[addr] = newAddr
+	n.up = up
+	n.vptr = old.vptr
+	n.liveCnt = old.liveCnt
+	n.flags = old.flags
+	n.left = db.copySubtree(dst, old.left, newAddr, addrMap)
+	n.right = db.copySubtree(dst, old.right, newAddr, addrMap)
+	return newAddr
+}
+
+// GC compacts the MemDB if the ratio of dead nodes, i.e. the nodes of deleted keys and flags only keys,
+// exceeds freeRatio. It returns the number of bytes freed, measured by EstimateMemoryFootprint.
+// The alive nodes and only the latest value of each node are copied to new arenas, the value history
+// and the freed nodes are reclaimed. The tombstones are kept, since the deletions need to be committed.
+// Like compact, it invalidates all MemKeyHandles and iterators, and it does nothing if there are staging buffers.
+// It does nothing if there are checkpoints either, since RollbackTo needs the value history.
+func (db *MemDB) GC(freeRatio float64) int64 {
+	db.Lock()
+	defer db.Unlock()
+	if len(db.stages) > 0 || len(db.savepoints) > 0 || db.count == 0 {
+		return 0
+	}
+	if float64(db.count-db.liveCount) <= freeRatio*float64(db.count) {
+		return 0
+	}
+
+	return db.rebuildArenas()
+}
+
+// CompactArena defragments the MemDB by copying the alive nodes and their latest values to new arenas
+// tightly, and returns the number of bytes reclaimed, measured by EstimateMemoryFootprint.
+// Unlike GC, it always compacts, so the holes left by the overwritten values are reclaimed too.
+// The nodes are copied in DFS order for the cache locality of the lookups.
+// It invalidates all MemKeyHandles and iterators, so it returns ErrAliveIterators without compacting if any
+// iterator returned by Iter, IterReverse or PrefixScan is not closed.
+// It does nothing if there are staging buffers or checkpoints, since they need the value history.
+func (db *MemDB) CompactArena() (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if atomic.LoadInt32(&db.aliveIters) > 0 {
+		return 0, tikverr.ErrAliveIterators
+	}
+	if len(db.stages) > 0 || len(db.savepoints) > 0 || db.count == 0 {
+		return 0, nil
+	}
+	return db.rebuildArenas(), nil
+}
+
+// rebuildArenas copies the alive nodes and their latest values to new arenas, and returns the number of bytes freed.
+func (db *MemDB) rebuildArenas() int64 {
+	before := db.EstimateMemoryFootprint()
+	if db.vlogInvalid {
+		db.compact()
+		return before - db.EstimateMemoryFootprint()
+	}
+	var (
+		allocator nodeAllocator
+		vlog      memdbVlog
+	)
+	allocator.init()
+	vlog.alignment = db.vlog.alignment
+	db.root = db.copySubtreeWithValue(&allocator, &vlog, db.root, nullAddr)
+	db.allocator = allocator
+	db.vlog = vlog
+	return before - db.EstimateMemoryFootprint()
+}
+
+// copySubtreeWithValue is like copySubtree, but the latest values are copied to vlog too.
+func (db *MemDB) copySubtreeWithValue(dst *nodeAllocator, vlog *memdbVlog, addr, up memdbArenaAddr) memdbArenaAddr {
+	if addr.isNull() {
+		return nullAddr
+	}
+	old := db.allocator.getNode(addr)
+	newAddr, n := dst.allocNode(old.getKey())
+	n.up = up
+	n.liveCnt = old.liveCnt
+	n.flags = old.flags
+	if !old.vptr.isNull() {
+		n.vptr = vlog.appendValue(newAddr, nullAddr, db.vlog.getValue(old.vptr))
+	}
+	n.left = db.copySubtreeWithValue(dst, vlog, old.left, newAddr)
+	n.right = db.copySubtreeWithValue(dst, vlog, old.right, newAddr)
+	return newAddr
+}
+
+// Clone returns a copy of the MemDB, the copy and the origin can be read and written independently.
+// The value log is append only, so its blocks are shared by the copy and the origin rather than copied,
+// the new values of either one are appended to its own new blocks. The tree nodes are updated in place
+// by the writes, so the used part of the node arena blocks is copied as a whole, the tree doesn't need
+// to be rebuilt. The staging buffers are cloned too.
+func (db *MemDB) Clone() *MemDB {
+	// The write lock is required since the value log blocks of db become shared.
+	db.Lock()
+	defer db.Unlock()
+
+	c := &MemDB{
+		root:            db.root,
+		entrySizeLimit:  db.entrySizeLimit,
+		bufferSizeLimit: db.bufferSizeLimit,
+		count:           db.count,
+		liveCount:       db.liveCount,
+		size:            db.size,
+		keySize:         db.keySize,
+		vlogInvalid:     db.vlogInvalid,
+		dirty:           db.dirty,
+		now:             db.now,
+		liveCntEnabled:  atomic.LoadInt32(&db.liveCntEnabled),
+		hasExpireAt:     atomic.LoadInt32(&db.hasExpireAt),
+	}
+	if db.expireAt != nil {
+		c.expireAt = make(map[string]time.Time, len(db.expireAt))
+		for k, t := range db.expireAt {
+			c.expireAt[k] = t
+		}
+	}
+	c.allocator.memdbArena = db.allocator.clone()
+	c.allocator.nullNode = db.allocator.nullNode
+	c.allocator.freedSize = db.allocator.freedSize
+	c.vlog.memdbArena = db.vlog.share()
+	c.vlog.alignment = db.vlog.alignment
+	c.stages = make([]memdbCheckpoint, len(db.stages), cap(db.stages))
+	copy(c.stages, db.stages)
+	c.savepoints = append([]memdbSavepoint(nil), db.savepoints...)
+	c.lastCheckpointID = db.lastCheckpointID
+	if db.namedCheckpoints != nil {
+		c.namedCheckpoints = make(map[string]CheckpointID, len(db.namedCheckpoints))
+		for name, id := range db.namedCheckpoints {
+			c.namedCheckpoints[name] = id
+		}
+	}
+	return c
+}
+
+// Filter returns a new MemDB with the entries of db which pred returns true for, their flags are copied too.
+// The latest value of the key is passed to pred, it's empty for a tombstone and nil for a flags only key,
+// the key and value are only valid until pred returns. The value history and the staging buffers are not copied.
+// The entries are visited in order, so the tree of the new MemDB is built balanced directly,
+// instead of inserting the entries one by one.
+func (db *MemDB) Filter(pred func(key, value []byte) bool) *MemDB {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	var matched []memdbNodeAddr
+	for it := db.IterWithFlags(nil, nil); it.Valid(); _ = it.Next() {
+		var value []byte
+		if it.HasValue() {
+			value = it.Value()
+		}
+		if pred(it.Key(), value) {
+			matched = append(matched, it.curr)
+		}
+	}
+
+	f := newMemDBWithOptions(ArenaOptions{Alignment: db.vlog.alignment})
+	f.entrySizeLimit = db.entrySizeLimit
+	f.bufferSizeLimit = db.bufferSizeLimit
+	f.now = db.now
+	if len(matched) > 0 {
+		// All the leaves of a balanced tree are at the deepest two levels,
+		// the nodes at the deepest level are red so that every path has the same black nodes.
+		redDepth := bits.Len(uint(len(matched))) - 1
+		f.root = f.buildBalanced(db, matched, nullAddr, 0, redDepth)
+		f.dirty = true
+	}
+	return f
+}
+
+// buildBalanced copies the sorted nodes of src to a balanced subtree and returns its root.
+func (db *MemDB) buildBalanced(src *MemDB, nodes []memdbNodeAddr, up memdbArenaAddr, depth, redDepth int) memdbArenaAddr {
+	if len(nodes) == 0 {
+		return nullAddr
+	}
+	mid := len(nodes) / 2
+	old := nodes[mid]
+	x := db.allocNode(old.getKey())
+	x.up = up
+	x.setKeyFlags(old.getKeyFlags())
+	if depth > 0 && depth == redDepth {
+		x.setRed()
+	} else {
+		x.setBlack()
+	}
+	if !old.vptr.isNull() {
+		value := src.vlog.getValue(old.vptr)
+		x.vptr = db.vlog.appendValue(x.addr, nullAddr, value)
+		db.size += len(value)
+		if len(value) > 0 {
+			db.liveCount++
+		}
+	}
+	x.left = db.buildBalanced(src, nodes[:mid], x.addr, depth+1, redDepth)
+	x.right = db.buildBalanced(src, nodes[mid+1:], x.addr, depth+1, redDepth)
+	db.updateLiveCount(x)
+	return x.addr
+}
+
+// SplitAt returns two new MemDBs split from db at splitKey, left has the entries whose keys are less than
+// splitKey and right has the others. Like Filter, the latest values and the flags are copied, including
+// the tombstones and the flags only keys, while the value history and the staging buffers are not.
+// The expiration times set by SetWithTTL are copied as well. db is not modified.
+func (db *MemDB) SplitAt(splitKey []byte) (left, right *MemDB, err error) {
+	if len(splitKey) == 0 {
+		return nil, nil, errors.New("the split key of MemDB must not be empty")
+	}
+	left = db.Filter(func(key, _ []byte) bool { return bytes.Compare(key, splitKey) < 0 })
+	right = db.Filter(func(key, _ []byte) bool { return bytes.Compare(key, splitKey) >= 0 })
+
+	db.RLock()
+	defer db.RUnlock()
+	for key, expireAt := range db.expireAt {
+		part := left
+		if key >= string(splitKey) {
+			part = right
+		}
+		if part.expireAt == nil {
+			part.expireAt = make(map[string]time.Time)
+			atomic.StoreInt32(&part.hasExpireAt, 1)
+		}
+		part.expireAt[key] = expireAt
+	}
+	return left, right, nil
+}
+
+// Diff compares the latest values of db and other by walking their keys in order, and returns the
+// changes from db to other. A key is live if its value is not a tombstone. The keys only live in other
+// are added, the keys live in db but deleted or absent in other are removed, and the keys live in both
+// with different values are modified. The values of added and modified are the ones in other, the
+// values of removed are the ones in db. The flags only keys are skipped, the returned pairs are copied.
+func (db *MemDB) Diff(other *MemDB) (added, removed, modified []kv.Pair, err error) {
+	if db.vlogInvalid || other.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+	if db == other {
+		return nil, nil, nil, nil
+	}
+
+	// The MemDBs are locked in the order of their addresses like MergeTo, otherwise a.Diff(b) and b.Diff(a)
+	// can deadlock with the writers waiting for the locks.
+	first, second := db, other
+	if uintptr(unsafe.Pointer(other)) < uintptr(unsafe.Pointer(db)) {
+		first, second = other, db
+	}
+	first.RLock()
+	defer first.RUnlock()
+	second.RLock()
+	defer second.RUnlock()
+
+	it1, err := db.Iter(nil, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer it1.Close()
+	it2, err := other.Iter(nil, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer it2.Close()
+
+	newPair := func(it Iterator) kv.Pair {
+		return kv.Pair{
+			Key:   append([]byte(nil), it.Key()...),
+			Value: append([]byte(nil), it.Value()...),
+		}
+	}
+	for it1.Valid() || it2.Valid() {
+		cmp := -1
+		if !it1.Valid() {
+			cmp = 1
+		} else if it2.Valid() {
+			cmp = bytes.Compare(it1.Key(), it2.Key())
+		}
+
+		switch {
+		case cmp < 0:
+			if !IsTombstone(it1.Value()) {
+				removed = append(removed, newPair(it1))
+			}
+		case cmp > 0:
+			if !IsTombstone(it2.Value()) {
+				added = append(added, newPair(it2))
+			}
+		default:
+			live1, live2 := !IsTombstone(it1.Value()), !IsTombstone(it2.Value())
+			if live1 && !live2 {
+				removed = append(removed, newPair(it1))
+			} else if !live1 && live2 {
+				added = append(added, newPair(it2))
+			} else if live1 && !bytes.Equal(it1.Value(), it2.Value()) {
+				modified = append(modified, newPair(it2))
+			}
+		}
+
+		if cmp <= 0 {
+			if err = it1.Next(); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		if cmp >= 0 {
+			if err = it2.Next(); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	return added, removed, modified, nil
 }
 
 // Reset resets the MemBuffer to initial states.
@@ -139,9 +635,18 @@ func (db *MemDB) Reset() {
 	db.dirty = false
 	db.vlogInvalid = false
 	db.size = 0
+	db.keySize = 0
 	db.count = 0
+	db.liveCount = 0
+	db.expireAt = nil
+	atomic.StoreInt32(&db.hasExpireAt, 0)
+	db.savepoints = nil
+	db.namedCheckpoints = nil
+	db.snapshotCp = nil
+	atomic.StoreInt32(&db.liveCntEnabled, 0)
 	db.vlog.reset()
 	db.allocator.reset()
+	db.notifyAllWatchers()
 }
 
 // DiscardValues releases the memory used by all values.
@@ -175,9 +680,31 @@ func (db *MemDB) Get(key []byte) ([]byte, error) {
 		// A flag only key, act as value not exists
 		return nil, tikverr.ErrNotExist
 	}
+	if db.isExpired(key) {
+		return nil, tikverr.ErrNotExist
+	}
 	return db.vlog.getValue(x.vptr), nil
 }
 
+// SizeOf returns the length of the value for key k, it reads only the length stored in the value log,
+// so the value is never touched. It returns -1 if the value is a tombstone.
+// Like Get, it returns ErrNotExist if the key does not exist, is a flags only key or is expired.
+func (db *MemDB) SizeOf(key []byte) (int, error) {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	x := db.traverse(key, false)
+	if x.isNull() || x.vptr.isNull() || db.isExpired(key) {
+		return 0, tikverr.ErrNotExist
+	}
+	if n := db.vlog.getValueLen(x.vptr); n > 0 {
+		return n, nil
+	}
+	return -1, nil
+}
+
 // SelectValueHistory select the latest value which makes `predicate` returns true from the modification history.
 func (db *MemDB) SelectValueHistory(key []byte, predicate func(value []byte) bool) ([]byte, error) {
 	x := db.traverse(key, false)
@@ -198,6 +725,7 @@ func (db *MemDB) SelectValueHistory(key []byte, predicate func(value []byte) boo
 }
 
 // GetFlags returns the latest flags associated with key.
+// It only reads the key node, the value is never accessed, so it still works after DiscardValues.
 func (db *MemDB) GetFlags(key []byte) (kv.KeyFlags, error) {
 	x := db.traverse(key, false)
 	if x.isNull() {
@@ -229,6 +757,237 @@ func (db *MemDB) SetWithFlags(key []byte, value []byte, ops ...kv.FlagsOp) error
 	return db.set(key, value, ops...)
 }
 
+// BatchSet sets all the key-value pairs into the MemDB while holding the lock only once.
+// The pairs are sorted by key in place, so the nodes are inserted in key order.
+// No pair is written if any value is nil or empty, or any pair exceeds the entry size limit.
+func (db *MemDB) BatchSet(pairs []kv.Pair) error {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+	for _, p := range pairs {
+		if len(p.Value) == 0 {
+			return tikverr.ErrCannotSetNilValue
+		}
+		if size := uint64(len(p.Key) + len(p.Value)); size > db.entrySizeLimit {
+			return &tikverr.ErrEntryTooLarge{
+				Limit: db.entrySizeLimit,
+				Size:  size,
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].Key, pairs[j].Key) < 0
+	})
+
+	db.Lock()
+	defer db.Unlock()
+
+	if len(pairs) > 0 && len(db.stages) == 0 {
+		db.dirty = true
+	}
+	for _, p := range pairs {
+		x := db.traverse(p.Key, true)
+		db.setValue(x, p.Value)
+	}
+	if uint64(db.Size()) > db.bufferSizeLimit {
+		return &tikverr.ErrTxnTooLarge{Size: db.Size()}
+	}
+	return nil
+}
+
+// ConflictPolicy decides how MergeTo handles a key which has a value in both MemDBs.
+type ConflictPolicy int
+
+const (
+	// ConflictLastWins overwrites the value in the parent with the merged one.
+	ConflictLastWins ConflictPolicy = iota
+	// ConflictFirstWins keeps the value in the parent.
+	ConflictFirstWins
+	// ConflictError fails the merge with ErrMergeConflict, the parent is left untouched.
+	ConflictError
+	// ConflictAccumulate takes the values as little-endian int64 counters and writes their sum to the parent.
+	// If either value is a tombstone, the merged value overwrites the parent's like ConflictLastWins.
+	// The merge fails if either value is not 8 bytes long, the parent is left untouched.
+	ConflictAccumulate
+)
+
+// MergeTo applies the latest values and the flags of all keys in db to parent as a whole, the
+// conflicting keys are handled according to policy. The flags are always merged.
+// Like BatchSet, the values are written to the current staging buffer of parent, nothing is written
+// if any entry is too large or conflicts under ConflictError. db is read locked and parent is write
+// locked during the merge.
+func (db *MemDB) MergeTo(parent *MemDB, policy ConflictPolicy) error {
+	if db == parent {
+		panic("cannot merge MemDB to itself")
+	}
+	if db.vlogInvalid || parent.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	// The MemDBs are locked in the order of their addresses, so merging two MemDBs to each other
+	// concurrently can't deadlock.
+	if uintptr(unsafe.Pointer(db)) < uintptr(unsafe.Pointer(parent)) {
+		db.RLock()
+		parent.Lock()
+	} else {
+		parent.Lock()
+		db.RLock()
+	}
+	defer db.RUnlock()
+	defer parent.Unlock()
+
+	// Check all the entries before writing any of them.
+	for it := db.IterWithFlags(nil, nil); it.Valid(); _ = it.Next() {
+		if !it.HasValue() {
+			continue
+		}
+		if size := uint64(len(it.Key()) + len(it.Value())); size > parent.entrySizeLimit {
+			return &tikverr.ErrEntryTooLarge{
+				Limit: parent.entrySizeLimit,
+				Size:  size,
+			}
+		}
+		switch policy {
+		case ConflictError:
+			if x := parent.traverse(it.Key(), false); !x.isNull() && !x.vptr.isNull() {
+				return &tikverr.ErrMergeConflict{Key: append([]byte(nil), it.Key()...)}
+			}
+		case ConflictAccumulate:
+			if x := parent.traverse(it.Key(), false); !x.isNull() && !x.vptr.isNull() {
+				old, value := parent.vlog.getValue(x.vptr), it.Value()
+				if !IsTombstone(old) && !IsTombstone(value) && (len(old) != 8 || len(value) != 8) {
+					return errors.Errorf("cannot accumulate the values of key %q, the lengths are %d and %d", it.Key(), len(old), len(value))
+				}
+			}
+		}
+	}
+
+	if db.count > 0 && len(parent.stages) == 0 {
+		parent.dirty = true
+	}
+	var sumBuf [8]byte
+	for it := db.IterWithFlags(nil, nil); it.Valid(); _ = it.Next() {
+		x := parent.traverse(it.Key(), true)
+		if flags := it.Flags(); flags != 0 {
+			flags |= x.getKeyFlags()
+			if flags.AndPersistent() != 0 {
+				parent.dirty = true
+			}
+			x.setKeyFlags(flags)
+		}
+		if !it.HasValue() || (policy == ConflictFirstWins && !x.vptr.isNull()) {
+			continue
+		}
+		value := it.Value()
+		if policy == ConflictAccumulate && !x.vptr.isNull() {
+			if old := parent.vlog.getValue(x.vptr); !IsTombstone(old) && !IsTombstone(value) {
+				sum := int64(binary.LittleEndian.Uint64(old)) + int64(binary.LittleEndian.Uint64(value))
+				binary.LittleEndian.PutUint64(sumBuf[:], uint64(sum))
+				value = sumBuf[:]
+			}
+		}
+		parent.setValue(x, value)
+	}
+	if uint64(parent.Size()) > parent.bufferSizeLimit {
+		return &tikverr.ErrTxnTooLarge{Size: parent.Size()}
+	}
+	return nil
+}
+
+// SetWithTTL is like Set, but the key expires at expireAt, it's used for the cache-like usage.
+// Since then Get returns ErrNotExist for the key, and PurgeExpired removes it from the MemDB.
+// Only Get checks the expiration, the iterators still see the expired keys until they are purged.
+// Like the flags, the expiration is not rollbackable. Setting or deleting the key again clears it.
+func (db *MemDB) SetWithTTL(key, value []byte, expireAt time.Time) error {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+	if len(value) == 0 {
+		return tikverr.ErrCannotSetNilValue
+	}
+	if size := uint64(len(key) + len(value)); size > db.entrySizeLimit {
+		return &tikverr.ErrEntryTooLarge{
+			Limit: db.entrySizeLimit,
+			Size:  size,
+		}
+	}
+
+	// The value and its expiration are written in the same critical section,
+	// so no reader can see the value without the expiration.
+	db.Lock()
+	defer db.Unlock()
+	if err := db.setLocked(key, value); err != nil {
+		return err
+	}
+	if db.expireAt == nil {
+		db.expireAt = make(map[string]time.Time)
+		atomic.StoreInt32(&db.hasExpireAt, 1)
+	}
+	db.expireAt[string(key)] = expireAt
+	return nil
+}
+
+// PurgeExpired removes the expired keys set by SetWithTTL from the tree, and returns the number of them.
+// The memory of the removed nodes is reclaimed by the compaction, like the one after Cleanup.
+// It does nothing if there are staging buffers or checkpoints, since the removal can't be rolled back.
+func (db *MemDB) PurgeExpired() int {
+	db.Lock()
+	defer db.Unlock()
+	if len(db.stages) > 0 || len(db.savepoints) > 0 || len(db.expireAt) == 0 {
+		return 0
+	}
+
+	now := db.now()
+	purged := 0
+	for key, expireAt := range db.expireAt {
+		if now.Before(expireAt) {
+			continue
+		}
+		delete(db.expireAt, key)
+		x := db.traverse([]byte(key), false)
+		if x.isNull() {
+			continue
+		}
+		if !db.vlogInvalid && !x.vptr.isNull() {
+			value := db.vlog.getValue(x.vptr)
+			db.size -= len(value)
+			if len(value) > 0 {
+				db.liveCount--
+			}
+		}
+		db.notifyWatchers(x)
+		db.deleteNode(x)
+		purged++
+	}
+	if purged > 0 {
+		db.maybeCompact()
+	}
+	return purged
+}
+
+func (db *MemDB) isExpired(key []byte) bool {
+	if atomic.LoadInt32(&db.hasExpireAt) == 0 {
+		return false
+	}
+	// The reads of the tree are lock free, but expireAt is a map written by SetWithTTL,
+	// so it must be read under the read lock.
+	db.RLock()
+	defer db.RUnlock()
+	return db.isExpiredLocked(key)
+}
+
+// isExpiredLocked is isExpired without the read lock, the caller must hold the lock.
+func (db *MemDB) isExpiredLocked(key []byte) bool {
+	if len(db.expireAt) == 0 {
+		return false
+	}
+	expireAt, ok := db.expireAt[string(key)]
+	return ok && !db.now().Before(expireAt)
+}
+
 // Delete removes the entry for key k from kv store.
 func (db *MemDB) Delete(key []byte) error {
 	return db.set(key, tombstone)
@@ -239,6 +998,53 @@ func (db *MemDB) DeleteWithFlags(key []byte, ops ...kv.FlagsOp) error {
 	return db.set(key, tombstone, ops...)
 }
 
+// CAS sets the value of key to newValue only if its current value equals expectedValue.
+// A nil expectedValue means the key must not exist, a deleted or expired key is treated as not existing like Get.
+// The comparison and the update are done under the same lock, it returns whether the swap succeeded.
+func (db *MemDB) CAS(key, expectedValue, newValue []byte) (bool, error) {
+	if len(newValue) == 0 {
+		return false, tikverr.ErrCannotSetNilValue
+	}
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+	if size := uint64(len(key) + len(newValue)); size > db.entrySizeLimit {
+		return false, &tikverr.ErrEntryTooLarge{
+			Limit: db.entrySizeLimit,
+			Size:  size,
+		}
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	x := db.traverse(key, false)
+	var oldVal []byte
+	if !x.isNull() && !x.vptr.isNull() && !db.isExpiredLocked(key) {
+		oldVal = db.vlog.getValue(x.vptr)
+	}
+	if expectedValue == nil {
+		if len(oldVal) != 0 {
+			return false, nil
+		}
+	} else if len(oldVal) == 0 || !bytes.Equal(oldVal, expectedValue) {
+		return false, nil
+	}
+
+	if len(db.stages) == 0 {
+		db.dirty = true
+	}
+	if x.isNull() {
+		x = db.traverse(key, true)
+	}
+	db.setValue(x, newValue)
+	if uint64(db.Size()) > db.bufferSizeLimit {
+		return true, &tikverr.ErrTxnTooLarge{Size: db.Size()}
+	}
+	return true, nil
+}
+
 // GetKeyByHandle returns key by handle.
 func (db *MemDB) GetKeyByHandle(handle MemKeyHandle) []byte {
 	x := db.getNode(handle.toAddr())
@@ -257,16 +1063,217 @@ func (db *MemDB) GetValueByHandle(handle MemKeyHandle) ([]byte, bool) {
 	return db.vlog.getValue(x.vptr), true
 }
 
-// Len returns the number of entries in the DB.
+// Len returns the number of entries in the DB. The deleted keys and the flags only keys are
+// counted too, it's used as the size of the transaction, like the size hint of the 2PC mutations.
 func (db *MemDB) Len() int {
 	return db.count
 }
 
+// LiveLen returns the number of keys with a non-tombstone value in the DB.
+// Unlike Len, the deleted keys and the flags only keys are not counted. The cost is O(1).
+func (db *MemDB) LiveLen() int {
+	return db.liveCount
+}
+
+// RangeCount returns the number of keys with a non-tombstone value in [start, end). A nil start or end
+// means the range is unbounded on that side. Like LiveLen, the expired keys are counted until they're purged.
+// Every node keeps the number of such keys in its subtree, so the cost is O(log n) instead of a scan.
+// The counts are built by the first call in O(n), and then maintained by the writes.
+func (db *MemDB) RangeCount(start, end []byte) int {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.enableLiveCounts()
+	lo, hi := 0, int(db.getRoot().liveCnt)
+	if start != nil {
+		lo = db.countLiveBefore(start)
+	}
+	if end != nil {
+		hi = db.countLiveBefore(end)
+	}
+	if hi < lo {
+		return 0
+	}
+	return hi - lo
+}
+
+// countLiveBefore returns the number of keys with a non-tombstone value which are less than key.
+func (db *MemDB) countLiveBefore(key []byte) int {
+	cnt := 0
+	x := db.getRoot()
+	for !x.isNull() {
+		if bytes.Compare(key, x.getKey()) <= 0 {
+			x = x.getLeft(db)
+			continue
+		}
+		cnt += int(x.getLeft(db).liveCnt)
+		if db.isLiveNode(x) {
+			cnt++
+		}
+		x = x.getRight(db)
+	}
+	return cnt
+}
+
+// KeyAt returns the key of the index-th (0-indexed) key with a non-tombstone value in the key order,
+// the expired keys are counted like RangeCount. It chooses the child by the live counts of the subtrees,
+// so the cost is O(log n), except for the first call which builds the counts like RangeCount.
+func (db *MemDB) KeyAt(index int) ([]byte, error) {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.enableLiveCounts()
+	x := db.getRoot()
+	if index < 0 || index >= int(x.liveCnt) {
+		return nil, errors.Errorf("index %d out of range [0, %d)", index, x.liveCnt)
+	}
+	for {
+		left := x.getLeft(db)
+		if index < int(left.liveCnt) {
+			x = left
+			continue
+		}
+		index -= int(left.liveCnt)
+		if db.isLiveNode(x) {
+			if index == 0 {
+				return x.getKey(), nil
+			}
+			index--
+		}
+		x = x.getRight(db)
+	}
+}
+
 // Size returns sum of keys and values length.
 func (db *MemDB) Size() int {
 	return db.size
 }
 
+// MemDBMemStats is the memory usage statistics of a MemDB.
+type MemDBMemStats struct {
+	// ArenaBytes is the total size of the arena blocks allocated for nodes and values.
+	ArenaBytes int64
+	// NodeCount is the number of nodes in the tree, including the flags only nodes.
+	NodeCount int64
+	// KeyBytes is the sum of keys length.
+	KeyBytes int64
+	// ValueBytes is the sum of the latest values length.
+	ValueBytes int64
+}
+
+// MemUsage returns the memory usage statistics of the MemDB.
+// It only reads the counters maintained by the arena and the tree, so the cost is O(1).
+func (db *MemDB) MemUsage() MemDBMemStats {
+	return MemDBMemStats{
+		ArenaBytes: int64(db.allocator.capacity + db.vlog.capacity),
+		NodeCount:  int64(db.count),
+		KeyBytes:   int64(db.keySize),
+		ValueBytes: int64(db.size - db.keySize),
+	}
+}
+
+// EstimateMemoryFootprint returns the estimated memory held by the MemDB in bytes.
+// Unlike MemUsage, it also counts the node headers, the alignment padding and the unused tail
+// of the arena blocks, the block and checkpoint descriptors and the MemDB struct itself.
+// The node headers live in the arena blocks, so they are counted by the arena capacity.
+func (db *MemDB) EstimateMemoryFootprint() int64 {
+	footprint := int64(unsafe.Sizeof(*db))
+	footprint += db.allocator.footprint() + db.vlog.footprint()
+	footprint += int64(cap(db.stages)) * int64(unsafe.Sizeof(memdbCheckpoint{}))
+	return footprint
+}
+
+// MemDBBucket is a bucket of the key space of a MemDB returned by Histogram.
+type MemDBBucket struct {
+	// LowKey and HighKey are the first and the last keys in the bucket, both are inclusive.
+	LowKey  []byte
+	HighKey []byte
+	// Count is the number of keys in the bucket, including the tombstones and the flags only keys.
+	Count int
+	// AvgValueSize is the average length of the latest values in the bucket, the tombstones and
+	// the flags only keys are counted as 0.
+	AvgValueSize float64
+}
+
+// Histogram divides the sorted keys of the MemDB into the given number of buckets with equal key counts,
+// the first buckets have one more key if the keys can't be divided evenly. The number of buckets is
+// reduced to Len() if it's greater, so no bucket is empty, and nil is returned for an empty MemDB or a
+// non-positive buckets. The keys are walked in order once, so the cost is O(n), the returned keys are copied.
+func (db *MemDB) Histogram(buckets int) []MemDBBucket {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	if buckets > db.count {
+		buckets = db.count
+	}
+	if buckets <= 0 {
+		return nil
+	}
+	hist := make([]MemDBBucket, 0, buckets)
+	perBucket, remainder := db.count/buckets, db.count%buckets
+
+	x := db.getRoot()
+	for !x.left.isNull() {
+		x = x.getLeft(db)
+	}
+	var bucket MemDBBucket
+	valueSize := 0
+	for ; !x.isNull(); x = db.successor(x) {
+		if bucket.Count == 0 {
+			bucket.LowKey = append([]byte(nil), x.getKey()...)
+		}
+		bucket.Count++
+		if !x.vptr.isNull() {
+			valueSize += db.vlog.getValueLen(x.vptr)
+		}
+		size := perBucket
+		if len(hist) < remainder {
+			size++
+		}
+		if bucket.Count == size {
+			bucket.HighKey = append([]byte(nil), x.getKey()...)
+			bucket.AvgValueSize = float64(valueSize) / float64(bucket.Count)
+			hist = append(hist, bucket)
+			bucket, valueSize = MemDBBucket{}, 0
+		}
+	}
+	return hist
+}
+
+// InspectArena calls fn for every node allocated in the node arena in address order, it's used by
+// the heap dump tooling to find out the memory held by the freed nodes.
+// The offset counts from the start of the first block, and the size includes the node header and
+// the key, but not the alignment padding. isLive reports whether the node is still reachable from
+// the tree. The values live in the vlog, they are not reported.
+// The read lock is held during the walk, so fn must not modify the MemDB or the arena memory.
+func (db *MemDB) InspectArena(fn func(offset int, size int, isLive bool)) {
+	db.RLock()
+	defer db.RUnlock()
+
+	base := 0
+	for i, block := range db.allocator.blocks {
+		for off := 0; off < block.length; {
+			addr := memdbArenaAddr{uint32(i), uint32(off)}
+			node := db.allocator.getNode(addr)
+			size := nodeSize(int(node.klen))
+			// The keys are unique in the tree, a freed node is not the one found by its key.
+			fn(base+off, size, db.traverse(node.getKey(), false).addr == addr)
+			// The next node is aligned, see memdbArenaBlock.alloc.
+			off = (off + size + 7) & alignMask
+		}
+		base += len(block.buf)
+	}
+}
+
 // Dirty returns whether the root staging buffer is updated.
 func (db *MemDB) Dirty() bool {
 	return db.dirty
@@ -289,7 +1296,11 @@ func (db *MemDB) set(key []byte, value []byte, ops ...kv.FlagsOp) error {
 
 	db.Lock()
 	defer db.Unlock()
+	return db.setLocked(key, value, ops...)
+}
 
+// setLocked is set without the checks, the caller must hold the write lock.
+func (db *MemDB) setLocked(key []byte, value []byte, ops ...kv.FlagsOp) error {
 	if len(db.stages) == 0 {
 		db.dirty = true
 	}
@@ -315,17 +1326,33 @@ func (db *MemDB) set(key []byte, value []byte, ops ...kv.FlagsOp) error {
 }
 
 func (db *MemDB) setValue(x memdbNodeAddr, value []byte) {
+	if len(db.expireAt) > 0 {
+		// The new value doesn't inherit the expiration set by SetWithTTL.
+		delete(db.expireAt, string(x.getKey()))
+	}
+	db.notifyWatchers(x)
 	var activeCp *memdbCheckpoint
 	if len(db.stages) > 0 {
 		activeCp = &db.stages[len(db.stages)-1]
 	}
+	if len(db.savepoints) > 0 {
+		// The values written before the latest checkpoint must be kept for RollbackTo.
+		if cp := &db.savepoints[len(db.savepoints)-1].cp; activeCp == nil || activeCp.isBefore(cp) {
+			activeCp = cp
+		}
+	}
+	if cp := db.snapshotCp; cp != nil && (activeCp == nil || activeCp.isBefore(cp)) {
+		// The values written before the latest snapshot must be kept for Marshal.
+		activeCp = cp
+	}
 
 	var oldVal []byte
 	if !x.vptr.isNull() {
 		oldVal = db.vlog.getValue(x.vptr)
 	}
 
-	if len(oldVal) > 0 && db.vlog.canModify(activeCp, x.vptr) {
+	// The value in the blocks shared by Clone is read only.
+	if len(oldVal) > 0 && db.vlog.canModify(activeCp, x.vptr) && !db.vlog.isShared(x.vptr) {
 		// For easier to implement, we only consider this case.
 		// It is the most common usage in TiDB's transaction buffers.
 		if len(oldVal) == len(value) {
@@ -335,6 +1362,13 @@ func (db *MemDB) setValue(x memdbNodeAddr, value []byte) {
 	}
 	x.vptr = db.vlog.appendValue(x.addr, x.vptr, value)
 	db.size = db.size - len(oldVal) + len(value)
+	if len(oldVal) == 0 && len(value) > 0 {
+		db.liveCount++
+		db.updateLiveCounts(x)
+	} else if len(oldVal) > 0 && len(value) == 0 {
+		db.liveCount--
+		db.updateLiveCounts(x)
+	}
 }
 
 // traverse search for and if not found and insert is true, will add a new node in.
@@ -499,6 +1533,10 @@ func (db *MemDB) leftRotate(x memdbNodeAddr) {
 	y.left = x.addr
 	// Set X's parent to be Y
 	x.up = y.addr
+
+	// Y takes the place of X, so its subtree has the same live keys.
+	y.liveCnt = x.liveCnt
+	db.updateLiveCount(x)
 }
 
 func (db *MemDB) rightRotate(y memdbNodeAddr) {
@@ -533,6 +1571,10 @@ func (db *MemDB) rightRotate(y memdbNodeAddr) {
 	x.right = y.addr
 	// Set Y's parent to be X
 	y.up = x.addr
+
+	// X takes the place of Y, so its subtree has the same live keys.
+	x.liveCnt = y.liveCnt
+	db.updateLiveCount(y)
 }
 
 func (db *MemDB) deleteNode(z memdbNodeAddr) {
@@ -540,12 +1582,18 @@ func (db *MemDB) deleteNode(z memdbNodeAddr) {
 
 	db.count--
 	db.size -= int(z.klen)
+	db.keySize -= int(z.klen)
 
 	if z.left.isNull() || z.right.isNull() {
 		y = z
 	} else {
 		y = db.successor(z)
 	}
+	// The live counts change from the parent of y up to the root, and y takes the place of z if they differ.
+	liveCntFrom := y.up
+	if liveCntFrom == z.addr {
+		liveCntFrom = y.addr
+	}
 
 	if !y.left.isNull() {
 		x = y.getLeft(db)
@@ -573,6 +1621,7 @@ func (db *MemDB) deleteNode(z memdbNodeAddr) {
 	if y != z {
 		db.replaceNode(z, y)
 	}
+	db.updateLiveCounts(db.getNode(liveCntFrom))
 
 	if needFix {
 		db.deleteNodeFix(x)
@@ -679,6 +1728,66 @@ func (db *MemDB) deleteNodeFix(x memdbNodeAddr) {
 	x.setBlack()
 }
 
+// isLiveNode checks whether x has a non-tombstone value.
+func (db *MemDB) isLiveNode(x memdbNodeAddr) bool {
+	return !x.vptr.isNull() && db.vlog.getValueLen(x.vptr) > 0
+}
+
+// enableLiveCounts builds the live counts of all nodes if they're not maintained yet.
+func (db *MemDB) enableLiveCounts() {
+	if atomic.LoadInt32(&db.liveCntEnabled) == 1 {
+		return
+	}
+	db.Lock()
+	defer db.Unlock()
+	if db.liveCntEnabled == 0 {
+		db.buildLiveCounts(db.getRoot())
+		atomic.StoreInt32(&db.liveCntEnabled, 1)
+	}
+}
+
+// buildLiveCounts computes the live counts of the subtree of x from the bottom up.
+func (db *MemDB) buildLiveCounts(x memdbNodeAddr) {
+	if x.isNull() {
+		return
+	}
+	db.buildLiveCounts(x.getLeft(db))
+	db.buildLiveCounts(x.getRight(db))
+	db.computeLiveCount(x)
+}
+
+// updateLiveCount recomputes the live count of x, if the live counts are maintained.
+func (db *MemDB) updateLiveCount(x memdbNodeAddr) {
+	if atomic.LoadInt32(&db.liveCntEnabled) == 0 {
+		return
+	}
+	db.computeLiveCount(x)
+}
+
+// computeLiveCount computes the live count of x from its value and its children.
+func (db *MemDB) computeLiveCount(x memdbNodeAddr) {
+	if x.isNull() || db.vlogInvalid {
+		// The live counts are not maintained since the values are discarded.
+		return
+	}
+	cnt := x.getLeft(db).liveCnt + x.getRight(db).liveCnt
+	if db.isLiveNode(x) {
+		cnt++
+	}
+	x.liveCnt = cnt
+}
+
+// updateLiveCounts recomputes the live counts from x up to the root, if they're maintained.
+func (db *MemDB) updateLiveCounts(x memdbNodeAddr) {
+	if atomic.LoadInt32(&db.liveCntEnabled) == 0 {
+		return
+	}
+	for !x.isNull() {
+		db.computeLiveCount(x)
+		x = x.getUp(db)
+	}
+}
+
 func (db *MemDB) successor(x memdbNodeAddr) (y memdbNodeAddr) {
 	if !x.right.isNull() {
 		// If right is not NULL then go right one and
@@ -739,6 +1848,7 @@ func (db *MemDB) getRoot() memdbNodeAddr {
 
 func (db *MemDB) allocNode(key []byte) memdbNodeAddr {
 	db.size += len(key)
+	db.keySize += len(key)
 	db.count++
 	x, xn := db.allocator.allocNode(key)
 	return memdbNodeAddr{xn, x}
@@ -770,8 +1880,10 @@ type memdbNode struct {
 	left  memdbArenaAddr
 	right memdbArenaAddr
 	vptr  memdbArenaAddr
-	klen  uint16
-	flags uint8
+	// liveCnt is the number of the nodes with a non-tombstone value in the subtree, see RangeCount.
+	liveCnt uint32
+	klen    uint16
+	flags   uint8
 }
 
 func (n *memdbNode) isRed() bool {
@@ -58,6 +58,11 @@ func (addr *memdbArenaAddr) load(src []byte) {
 type memdbArena struct {
 	blockSize int
 	blocks    []memdbArenaBlock
+	// capacity is the sum of the buffer size of all blocks.
+	capacity int
+	// shared is the number of the leading blocks shared with other arenas by share, they're read only,
+	// so nothing is allocated in them any more.
+	shared int
 }
 
 func (a *memdbArena) alloc(size int, align bool) (memdbArenaAddr, []byte) {
@@ -90,10 +95,14 @@ func (a *memdbArena) enlarge(allocSize, blockSize int) {
 	a.blocks = append(a.blocks, memdbArenaBlock{
 		buf: make([]byte, a.blockSize),
 	})
+	a.capacity += a.blockSize
 }
 
 func (a *memdbArena) allocInLastBlock(size int, align bool) (memdbArenaAddr, []byte) {
 	idx := len(a.blocks) - 1
+	if idx < a.shared {
+		return nullAddr, nil
+	}
 	offset, data := a.blocks[idx].alloc(size, align)
 	if offset == nullBlockOffset {
 		return nullAddr, nil
@@ -101,12 +110,63 @@ func (a *memdbArena) allocInLastBlock(size int, align bool) (memdbArenaAddr, []b
 	return memdbArenaAddr{uint32(idx), offset}, data
 }
 
+// clone returns a copy of the arena, only the used part of blocks is copied.
+func (a *memdbArena) clone() memdbArena {
+	c := memdbArena{
+		blockSize: a.blockSize,
+		blocks:    make([]memdbArenaBlock, len(a.blocks)),
+		capacity:  a.capacity,
+	}
+	for i, block := range a.blocks {
+		buf := make([]byte, len(block.buf))
+		copy(buf, block.buf[:block.length])
+		c.blocks[i] = memdbArenaBlock{buf: buf, length: block.length}
+	}
+	return c
+}
+
+// share returns an arena sharing all the blocks of a without copying them. The blocks become read only
+// for both arenas, the new allocations of either arena go to new blocks. The shared blocks are freed
+// by GC once no arena refers to them.
+func (a *memdbArena) share() memdbArena {
+	a.shared = len(a.blocks)
+	return memdbArena{
+		blockSize: a.blockSize,
+		blocks:    append([]memdbArenaBlock(nil), a.blocks...),
+		capacity:  a.capacity,
+		shared:    len(a.blocks),
+	}
+}
+
+// unshare replaces the blocks shared by share with copies of their used part, so that they can be written again.
+func (a *memdbArena) unshare() {
+	for i := 0; i < a.shared; i++ {
+		block := &a.blocks[i]
+		buf := make([]byte, len(block.buf))
+		copy(buf, block.buf[:block.length])
+		block.buf = buf
+	}
+	a.shared = 0
+}
+
+// isShared returns whether addr is in the blocks shared by share.
+func (a *memdbArena) isShared(addr memdbArenaAddr) bool {
+	return int(addr.idx) < a.shared
+}
+
+// footprint returns the memory held by the arena, including the block descriptors.
+func (a *memdbArena) footprint() int64 {
+	return int64(a.capacity) + int64(cap(a.blocks))*int64(unsafe.Sizeof(memdbArenaBlock{}))
+}
+
 func (a *memdbArena) reset() {
 	for i := range a.blocks {
 		a.blocks[i].reset()
 	}
 	a.blocks = a.blocks[:0]
 	a.blockSize = 0
+	a.capacity = 0
+	a.shared = 0
 }
 
 type memdbArenaBlock struct {
@@ -144,6 +204,10 @@ func (cp *memdbCheckpoint) isSamePosition(other *memdbCheckpoint) bool {
 	return cp.blocks == other.blocks && cp.offsetInBlock == other.offsetInBlock
 }
 
+func (cp *memdbCheckpoint) isBefore(other *memdbCheckpoint) bool {
+	return cp.blocks < other.blocks || (cp.blocks == other.blocks && cp.offsetInBlock < other.offsetInBlock)
+}
+
 func (a *memdbArena) checkpoint() memdbCheckpoint {
 	snap := memdbCheckpoint{
 		blockSize: a.blockSize,
@@ -157,9 +221,13 @@ func (a *memdbArena) checkpoint() memdbCheckpoint {
 
 func (a *memdbArena) truncate(snap *memdbCheckpoint) {
 	for i := snap.blocks; i < len(a.blocks); i++ {
+		a.capacity -= len(a.blocks[i].buf)
 		a.blocks[i] = memdbArenaBlock{}
 	}
 	a.blocks = a.blocks[:snap.blocks]
+	if a.shared > len(a.blocks) {
+		a.shared = len(a.blocks)
+	}
 	if len(a.blocks) > 0 {
 		a.blocks[len(a.blocks)-1].length = snap.offsetInBlock
 	}
@@ -173,6 +241,8 @@ type nodeAllocator struct {
 	// We then use this instead of NULL to mean the top or bottom
 	// end of the rb tree. It is a black node.
 	nullNode memdbNode
+	// freedSize is the total size of the nodes freed since the last reset.
+	freedSize int
 }
 
 func (a *nodeAllocator) init() {
@@ -192,11 +262,15 @@ func (a *nodeAllocator) getNode(addr memdbArenaAddr) *memdbNode {
 	return (*memdbNode)(unsafe.Pointer(&a.blocks[addr.idx].buf[addr.off]))
 }
 
+func nodeSize(klen int) int {
+	return 8*4 + 4 + 2 + 1 + klen
+}
+
 func (a *nodeAllocator) allocNode(key []byte) (memdbArenaAddr, *memdbNode) {
-	nodeSize := 8*4 + 2 + 1 + len(key)
-	addr, mem := a.alloc(nodeSize, true)
+	addr, mem := a.alloc(nodeSize(len(key)), true)
 	n := (*memdbNode)(unsafe.Pointer(&mem[0]))
 	n.vptr = nullAddr
+	n.liveCnt = 0
 	n.klen = uint16(len(key))
 	copy(n.getKey(), key)
 	return addr, n
@@ -205,6 +279,7 @@ func (a *nodeAllocator) allocNode(key []byte) (memdbArenaAddr, *memdbNode) {
 var testMode = false
 
 func (a *nodeAllocator) freeNode(addr memdbArenaAddr) {
+	a.freedSize += nodeSize(int(a.getNode(addr).klen))
 	if testMode {
 		// Make it easier for debug.
 		n := a.getNode(addr)
@@ -219,13 +294,24 @@ func (a *nodeAllocator) freeNode(addr memdbArenaAddr) {
 	// TODO: reuse freed nodes.
 }
 
+// needCompact returns true if more than 1/4 of the arena is occupied by freed nodes.
+func (a *nodeAllocator) needCompact() bool {
+	return a.freedSize*4 > a.capacity
+}
+
 func (a *nodeAllocator) reset() {
 	a.memdbArena.reset()
 	a.init()
+	a.freedSize = 0
 }
 
 type memdbVlog struct {
 	memdbArena
+	// alignment is the boundary the values are aligned to, see ArenaOptions.Alignment.
+	// Every entry is padded between the value and the header to a multiple of it, the blocks are allocated
+	// in the power of 2 sizes which are aligned to at least 4096 by the Go allocator, so the values
+	// starting the entries are aligned too.
+	alignment int
 }
 
 const memdbVlogHdrSize = 8 + 8 + 4
@@ -254,13 +340,22 @@ func (hdr *memdbVlogHdr) load(src []byte) {
 	hdr.nodeAddr.load(src[cursor:])
 }
 
+// entrySize returns the size of the entry of a value of valueLen, including the header and the padding.
+func (l *memdbVlog) entrySize(valueLen int) int {
+	size := memdbVlogHdrSize + valueLen
+	if l.alignment > 1 {
+		size = (size + l.alignment - 1) &^ (l.alignment - 1)
+	}
+	return size
+}
+
 func (l *memdbVlog) appendValue(nodeAddr memdbArenaAddr, oldValue memdbArenaAddr, value []byte) memdbArenaAddr {
-	size := memdbVlogHdrSize + len(value)
+	size := l.entrySize(len(value))
 	addr, mem := l.alloc(size, false)
 
 	copy(mem, value)
 	hdr := memdbVlogHdr{nodeAddr, oldValue, uint32(len(value))}
-	hdr.store(mem[len(value):])
+	hdr.store(mem[size-memdbVlogHdrSize:])
 
 	addr.off += uint32(size)
 	return addr
@@ -273,8 +368,14 @@ func (l *memdbVlog) getValue(addr memdbArenaAddr) []byte {
 	if valueLen == 0 {
 		return tombstone
 	}
-	valueOff := lenOff - valueLen
-	return block[valueOff:lenOff:lenOff]
+	valueOff := addr.off - uint32(l.entrySize(int(valueLen)))
+	valueEnd := valueOff + valueLen
+	return block[valueOff:valueEnd:valueEnd]
+}
+
+func (l *memdbVlog) getValueLen(addr memdbArenaAddr) int {
+	lenOff := addr.off - memdbVlogHdrSize
+	return int(endian.Uint32(l.blocks[addr.idx].buf[lenOff:]))
 }
 
 func (l *memdbVlog) getSnapshotValue(addr memdbArenaAddr, snap *memdbCheckpoint) ([]byte, bool) {
@@ -284,7 +385,7 @@ func (l *memdbVlog) getSnapshotValue(addr memdbArenaAddr, snap *memdbCheckpoint)
 	if result.isNull() {
 		return nil, false
 	}
-	return l.getValue(addr), true
+	return l.getValue(result), true
 }
 
 func (l *memdbVlog) selectValueHistory(addr memdbArenaAddr, predicate func(memdbArenaAddr) bool) memdbArenaAddr {
@@ -307,9 +408,17 @@ func (l *memdbVlog) revertToCheckpoint(db *MemDB, cp *memdbCheckpoint) {
 		var hdr memdbVlogHdr
 		hdr.load(block[hdrOff:])
 		node := db.getNode(hdr.nodeAddr)
+		db.notifyWatchers(node)
 
 		node.vptr = hdr.oldValue
+		db.updateLiveCounts(node)
 		db.size -= int(hdr.valueLen)
+		if hdr.valueLen > 0 {
+			db.liveCount--
+		}
+		if !hdr.oldValue.isNull() && len(l.getValue(hdr.oldValue)) > 0 {
+			db.liveCount++
+		}
 		// oldValue.isNull() == true means this is a newly added value.
 		if hdr.oldValue.isNull() {
 			// If there are no flags associated with this key, we need to delete this node.
@@ -340,7 +449,7 @@ func (l *memdbVlog) inspectKVInLog(db *MemDB, head, tail *memdbCheckpoint, f fun
 
 		// Skip older versions.
 		if node.vptr == cursorAddr {
-			value := block[hdrOff-hdr.valueLen : hdrOff]
+			value := l.getValue(cursorAddr)
 			f(node.getKey(), node.getKeyFlags(), value)
 		}
 
@@ -348,8 +457,27 @@ func (l *memdbVlog) inspectKVInLog(db *MemDB, head, tail *memdbCheckpoint, f fun
 	}
 }
 
+// relocateNodes updates the node address in all vlog headers according to the addrMap.
+func (l *memdbVlog) relocateNodes(addrMap map[memdbArenaAddr]memdbArenaAddr) {
+	// The headers in the blocks shared by Clone are read by the other MemDB, they mustn't be rewritten in place.
+	l.unshare()
+	cursor := l.checkpoint()
+	for cursor.blocks > 0 {
+		hdrOff := cursor.offsetInBlock - memdbVlogHdrSize
+		block := l.blocks[cursor.blocks-1].buf
+		var hdr memdbVlogHdr
+		hdr.load(block[hdrOff:])
+		if newAddr, ok := addrMap[hdr.nodeAddr]; ok {
+			hdr.nodeAddr = newAddr
+			hdr.store(block[hdrOff:])
+		}
+
+		l.moveBackCursor(&cursor, &hdr)
+	}
+}
+
 func (l *memdbVlog) moveBackCursor(cursor *memdbCheckpoint, hdr *memdbVlogHdr) {
-	cursor.offsetInBlock -= (memdbVlogHdrSize + int(hdr.valueLen))
+	cursor.offsetInBlock -= l.entrySize(int(hdr.valueLen))
 	if cursor.offsetInBlock == 0 {
 		cursor.blocks--
 		if cursor.blocks > 0 {
@@ -15,8 +15,11 @@ package unionstore
 
 import (
 	"encoding/binary"
+	"fmt"
 	"math/rand"
 	"testing"
+
+	"github.com/pingcap/tidb/store/tikv/kv"
 )
 
 const (
@@ -99,6 +102,71 @@ func BenchmarkGetRandom(b *testing.B) {
 	}
 }
 
+func BenchmarkGetAlignment(b *testing.B) {
+	for _, alignment := range []int{8, 32} {
+		b.Run(fmt.Sprintf("align-%d", alignment), func(b *testing.B) {
+			db := newMemDBWithOptions(ArenaOptions{Alignment: alignment})
+			keys := make([][]byte, opCnt)
+			for i := range keys {
+				keys[i] = encodeInt(i)
+				_ = db.Set(keys[i], make([]byte, 1+i%valueSize))
+			}
+			vlogSize := 0
+			for _, block := range db.vlog.blocks {
+				vlogSize += block.length
+			}
+			b.ReportMetric(float64(vlogSize)/float64(opCnt), "vlog-bytes/value")
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var sum byte
+				for _, k := range keys {
+					val, _ := db.Get(k)
+					for _, v := range val {
+						sum += v
+					}
+				}
+				_ = sum
+			}
+		})
+	}
+}
+
+func BenchmarkMemDbGetLargeValue(b *testing.B) {
+	benchmarkLargeValue(b, func(p *MemDB, key []byte) {
+		if val, err := p.Get(key); err != nil || len(val) > 64<<10 {
+			b.Fatal(err)
+		}
+	})
+}
+
+func BenchmarkMemDbSizeOfLargeValue(b *testing.B) {
+	benchmarkLargeValue(b, func(p *MemDB, key []byte) {
+		if n, err := p.SizeOf(key); err != nil || n > 64<<10 {
+			b.Fatal(err)
+		}
+	})
+}
+
+// benchmarkLargeValue calls read on the keys of 4KB values in order.
+func benchmarkLargeValue(b *testing.B, read func(p *MemDB, key []byte)) {
+	const cnt = 10000
+	var key [keySize]byte
+	val := make([]byte, 4<<10)
+	p := newMemDB()
+	for i := 0; i < cnt; i++ {
+		binary.BigEndian.PutUint32(key[:], uint32(i))
+		_ = p.Set(key[:], val)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.BigEndian.PutUint32(key[:], uint32(i%cnt))
+		read(p, key[:])
+	}
+}
+
 var opCnt = 100000
 
 func BenchmarkMemDbBufferSequential(b *testing.B) {
@@ -128,6 +196,202 @@ func BenchmarkMemDbIter(b *testing.B) {
 	b.ReportAllocs()
 }
 
+func BenchmarkMemDbForEach(b *testing.B) {
+	buffer := newMemDB()
+	for k := 0; k < opCnt; k++ {
+		_ = buffer.Set(encodeInt(k), encodeInt(k))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := buffer.ForEach(func(key, value []byte) bool {
+			return true
+		})
+		if err != nil {
+			b.Error(err)
+		}
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkMemDbPrefixScan(b *testing.B) {
+	benchmarkPrefixScan(b, func(db *MemDB, prefix []byte) (Iterator, error) {
+		return db.PrefixScan(prefix)
+	})
+}
+
+func BenchmarkMemDbPrefixIter(b *testing.B) {
+	benchmarkPrefixScan(b, func(db *MemDB, prefix []byte) (Iterator, error) {
+		return db.Iter(prefix, prefixNext(prefix))
+	})
+}
+
+// benchmarkPrefixScan scans 256 keys under every 2 bytes prefix by the given iterator constructor.
+func benchmarkPrefixScan(b *testing.B, iter func(db *MemDB, prefix []byte) (Iterator, error)) {
+	db := newMemDB()
+	var buf [4]byte
+	for k := 0; k < 1<<16; k++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(k))
+		_ = db.Set(buf[1:], buf[1:])
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for p := 0; p < 1<<8; p++ {
+			it, err := iter(db, []byte{0, byte(p)})
+			if err != nil {
+				b.Fatal(err)
+			}
+			for it.Valid() {
+				_ = it.Next()
+			}
+			it.Close()
+		}
+	}
+	b.ReportAllocs()
+}
+
+func prefixNext(k []byte) []byte {
+	buf := make([]byte, len(k))
+	copy(buf, k)
+	var i int
+	for i = len(k) - 1; i >= 0; i-- {
+		buf[i]++
+		if buf[i] != 0 {
+			break
+		}
+	}
+	if i == -1 {
+		copy(buf, k)
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func BenchmarkMemDbBatchDelete(b *testing.B) {
+	const cnt = 1000000
+	benchmarkBatchDelete(b, cnt, rand.Perm(cnt)[:cnt/2])
+}
+
+func BenchmarkMemDbBatchDeleteRange(b *testing.B) {
+	const cnt = 1000000
+	idxs := make([]int, 0, cnt/2)
+	for i := cnt / 4; i < cnt/4*3; i++ {
+		idxs = append(idxs, i)
+	}
+	benchmarkBatchDelete(b, cnt, idxs)
+}
+
+// benchmarkBatchDelete inserts cnt sequential keys, then deletes the keys at idxs in order.
+func benchmarkBatchDelete(b *testing.B, cnt int, idxs []int) {
+	buf := make([][keySize]byte, cnt)
+	for i := range buf {
+		binary.BigEndian.PutUint32(buf[i][:], uint32(i))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db := newMemDB()
+		for k := range buf {
+			_ = db.Set(buf[k][:], buf[k][:])
+		}
+		b.StartTimer()
+		for _, k := range idxs {
+			_ = db.Delete(buf[k][:])
+		}
+	}
+	b.ReportAllocs()
+}
+
+// BenchmarkMemDbOverwrite overwrites every key of a populated MemDB in random order,
+// it reports the arena growth per overwrite besides the wall time.
+func BenchmarkMemDbOverwrite(b *testing.B) {
+	data := make([][]byte, opCnt)
+	for i := 0; i < opCnt; i++ {
+		data[i] = encodeInt(i)
+	}
+	db := newMemDB()
+	for _, k := range data {
+		_ = db.Set(k, k)
+	}
+	shuffle(data)
+	val := make([]byte, len(data[0]))
+	arenaSize := db.allocator.capacity + db.vlog.capacity
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, k := range data {
+			copy(val, k)
+			val[len(val)-1] ^= byte(i)
+			_ = db.Set(k, val)
+		}
+	}
+	b.StopTimer()
+	growth := db.allocator.capacity + db.vlog.capacity - arenaSize
+	b.ReportMetric(float64(growth)/float64(b.N*len(data)), "arena-B/op")
+	b.ReportAllocs()
+}
+
+func BenchmarkMemDbSetLoop(b *testing.B) {
+	pairs := sequentialPairs(opCnt)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db := newMemDB()
+		for _, p := range pairs {
+			_ = db.Set(p.Key, p.Value)
+		}
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkMemDbBatchSet(b *testing.B) {
+	pairs := sequentialPairs(opCnt)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db := newMemDB()
+		_ = db.BatchSet(pairs)
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkMemDbFilter(b *testing.B) {
+	benchmarkFilter(b, func(db *MemDB, pred func(key, value []byte) bool) *MemDB {
+		return db.Filter(pred)
+	})
+}
+
+func BenchmarkMemDbFilterLoop(b *testing.B) {
+	benchmarkFilter(b, func(db *MemDB, pred func(key, value []byte) bool) *MemDB {
+		f := newMemDB()
+		_ = db.ForEach(func(key, value []byte) bool {
+			if pred(key, value) {
+				_ = f.Set(key, value)
+			}
+			return true
+		})
+		return f
+	})
+}
+
+func benchmarkFilter(b *testing.B, filter func(db *MemDB, pred func(key, value []byte) bool) *MemDB) {
+	db := newMemDB()
+	_ = db.BatchSet(sequentialPairs(opCnt))
+	pred := func(key, value []byte) bool {
+		return key[len(key)-1]%2 == 0
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter(db, pred)
+	}
+	b.ReportAllocs()
+}
+
+func sequentialPairs(cnt int) []kv.Pair {
+	pairs := make([]kv.Pair, cnt)
+	for i := range pairs {
+		k := encodeInt(i)
+		pairs[i] = kv.Pair{Key: k, Value: k}
+	}
+	return pairs
+}
+
 func BenchmarkMemDbCreation(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		newMemDB()
@@ -135,6 +399,59 @@ func BenchmarkMemDbCreation(b *testing.B) {
 	b.ReportAllocs()
 }
 
+func BenchmarkMemDbStagingCleanup(b *testing.B) {
+	buffer := newMemDB()
+	for k := 0; k < opCnt; k++ {
+		_ = buffer.Set(encodeInt(k), encodeInt(k))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h := buffer.Staging()
+		_ = buffer.Set(encodeInt(opCnt+i), encodeInt(i))
+		buffer.Cleanup(h)
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkMemDbStagingNested(b *testing.B) {
+	const keysPerLevel = 1000
+	for depth := 1; depth <= 10; depth++ {
+		b.Run(fmt.Sprintf("depth-%d", depth), func(b *testing.B) {
+			keys := make([][]byte, depth*keysPerLevel)
+			for i := range keys {
+				keys[i] = encodeInt(i)
+			}
+			handles := make([]int, depth)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				buffer := newMemDB()
+				for l := 0; l < depth; l++ {
+					handles[l] = buffer.Staging()
+					for _, k := range keys[l*keysPerLevel : (l+1)*keysPerLevel] {
+						_ = buffer.Set(k, k)
+					}
+				}
+				for l := depth - 1; l >= 0; l-- {
+					buffer.Release(handles[l])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMemDbMemUsage(b *testing.B) {
+	buffer := newMemDB()
+	for k := 0; k < opCnt; k++ {
+		_ = buffer.Set(encodeInt(k), encodeInt(k))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = buffer.MemUsage()
+	}
+	b.ReportAllocs()
+}
+
 func shuffle(slc [][]byte) {
 	N := len(slc)
 	for i := 0; i < N; i++ {
@@ -171,3 +488,27 @@ func benchIterator(b *testing.B, buffer *MemDB) {
 		iter.Close()
 	}
 }
+
+func BenchmarkUnionWith(b *testing.B) {
+	const cnt = 100000
+	// The two MemDBs share half of the keys.
+	newDB := func(start int) *MemDB {
+		db := newMemDB()
+		var buf [valueSize]byte
+		for i := start; i < start+cnt; i++ {
+			binary.BigEndian.PutUint32(buf[:], uint32(i))
+			_ = db.Set(buf[:keySize], buf[:])
+		}
+		return db
+	}
+	other := newDB(cnt / 2)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		db := newDB(0)
+		b.StartTimer()
+		if err := db.UnionWith(other, LastWriteWins); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
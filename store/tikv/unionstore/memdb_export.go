@@ -0,0 +1,162 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"io"
+
+	"github.com/pingcap/errors"
+)
+
+// ExportFormat is the format of the key-value pairs written by MemDB.Export.
+type ExportFormat int
+
+const (
+	// BinaryFormat writes every pair as the big-endian uint32 length of the key, the key,
+	// the big-endian uint32 length of the value and the value.
+	BinaryFormat ExportFormat = iota
+	// CSVFormat writes every pair as a line of the hex-encoded key and value separated by a comma.
+	CSVFormat
+)
+
+// Export writes the key-value pairs of db to w in key order. The tombstones are written with
+// empty values, the flags only keys and the flags are not written. The pairs are written one by one,
+// so the content is never buffered as a whole.
+func (db *MemDB) Export(w io.Writer, format ExportFormat) error {
+	it, err := db.Iter(nil, nil)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	switch format {
+	case BinaryFormat:
+		bw := bufio.NewWriter(w)
+		var lenBuf [4]byte
+		for it.Valid() {
+			for _, b := range [][]byte{it.Key(), it.Value()} {
+				binary.BigEndian.PutUint32(lenBuf[:], uint32(len(b)))
+				if _, err = bw.Write(lenBuf[:]); err != nil {
+					return errors.Trace(err)
+				}
+				if _, err = bw.Write(b); err != nil {
+					return errors.Trace(err)
+				}
+			}
+			if err = it.Next(); err != nil {
+				return err
+			}
+		}
+		return errors.Trace(bw.Flush())
+	case CSVFormat:
+		cw := csv.NewWriter(w)
+		record := make([]string, 2)
+		for it.Valid() {
+			record[0], record[1] = hex.EncodeToString(it.Key()), hex.EncodeToString(it.Value())
+			if err = cw.Write(record); err != nil {
+				return errors.Trace(err)
+			}
+			if err = it.Next(); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return errors.Trace(cw.Error())
+	default:
+		return errors.Errorf("unknown export format %d", format)
+	}
+}
+
+// Import reads the key-value pairs written by Export from r and writes them into db,
+// a pair with the empty value is imported as a tombstone by Delete.
+// The pairs are written as they are read, the ones read before an error are kept in db.
+func (db *MemDB) Import(r io.Reader, format ExportFormat) error {
+	switch format {
+	case BinaryFormat:
+		br := bufio.NewReader(r)
+		var lenBuf [4]byte
+		readBytes := func() ([]byte, error) {
+			if _, err := io.ReadFull(br, lenBuf[:]); err != nil {
+				return nil, err
+			}
+			n := binary.BigEndian.Uint32(lenBuf[:])
+			if uint64(n) > db.entrySizeLimit {
+				return nil, errors.Errorf("the length %d exceeds the entry size limit %d", n, db.entrySizeLimit)
+			}
+			b := make([]byte, n)
+			if _, err := io.ReadFull(br, b); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			return b, nil
+		}
+		for {
+			key, err := readBytes()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+			value, err := readBytes()
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return errors.Trace(err)
+			}
+			if err = db.importPair(key, value); err != nil {
+				return err
+			}
+		}
+	case CSVFormat:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = 2
+		cr.ReuseRecord = true
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+			key, err := hex.DecodeString(record[0])
+			if err != nil {
+				return errors.Trace(err)
+			}
+			value, err := hex.DecodeString(record[1])
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if err = db.importPair(key, value); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("unknown export format %d", format)
+	}
+}
+
+func (db *MemDB) importPair(key, value []byte) error {
+	if IsTombstone(value) {
+		return db.Delete(key)
+	}
+	return db.Set(key, value)
+}
@@ -15,6 +15,7 @@ package unionstore
 
 import (
 	"bytes"
+	"sync/atomic"
 
 	"github.com/pingcap/tidb/store/tikv/kv"
 )
@@ -25,13 +26,17 @@ type MemdbIterator struct {
 	curr         memdbNodeAddr
 	start        []byte
 	end          []byte
+	prefix       []byte
 	reverse      bool
 	includeFlags bool
+	// tracked is true if the iterator is counted in MemDB.aliveIters until it's closed.
+	tracked bool
 }
 
 // Iter creates an Iterator positioned on the first entry that k <= entry's key.
 // If such entry is not found, it returns an invalid Iterator with no error.
 // It yields only keys that < upperBound. If upperBound is nil, it means the upperBound is unbounded.
+// A nil or empty k and upperBound are treated the same way, so Iter(nil, nil) scans everything.
 // The Iterator must be Closed after use.
 func (db *MemDB) Iter(k []byte, upperBound []byte) (Iterator, error) {
 	i := &MemdbIterator{
@@ -40,6 +45,22 @@ func (db *MemDB) Iter(k []byte, upperBound []byte) (Iterator, error) {
 		end:   upperBound,
 	}
 	i.init()
+	i.track()
+	return i, nil
+}
+
+// PrefixScan creates an Iterator positioned on the first entry which key has the given prefix.
+// It yields only keys that have the prefix, and stops walking the tree as soon as the
+// key of the next node no longer has the prefix, so the caller needn't derive the upper bound.
+// The Iterator must be Closed after use.
+func (db *MemDB) PrefixScan(prefix []byte) (Iterator, error) {
+	i := &MemdbIterator{
+		db:     db,
+		start:  prefix,
+		prefix: prefix,
+	}
+	i.init()
+	i.track()
 	return i, nil
 }
 
@@ -54,6 +75,7 @@ func (db *MemDB) IterReverse(k []byte) (Iterator, error) {
 		reverse: true,
 	}
 	i.init()
+	i.track()
 	return i, nil
 }
 
@@ -81,6 +103,83 @@ func (db *MemDB) IterReverseWithFlags(k []byte) *MemdbIterator {
 	return i
 }
 
+// ForEach calls fn for every key-value pair in ascending key order, until fn returns false.
+// It walks the tree directly instead of creating an Iterator, so it's allocation free.
+// Like Iter, the flags only keys are skipped. The key and value are only valid until fn returns.
+func (db *MemDB) ForEach(fn func(key, value []byte) bool) error {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	x := db.getRoot()
+	if x.isNull() {
+		return nil
+	}
+	for !x.left.isNull() {
+		x = x.getLeft(db)
+	}
+	for ; !x.isNull(); x = db.successor(x) {
+		if x.vptr.isNull() {
+			continue
+		}
+		if !fn(x.getKey(), db.vlog.getValue(x.vptr)) {
+			break
+		}
+	}
+	return nil
+}
+
+// MemDBNode is a read-only view of a key-value pair stored in MemDB.
+// It's only valid in the callback of WalkRange.
+type MemDBNode struct {
+	db   *MemDB
+	node memdbNodeAddr
+}
+
+// Key returns the key of the node, the returned slice is backed by the arena and must not be modified.
+func (n *MemDBNode) Key() []byte {
+	return n.node.getKey()
+}
+
+// Value returns the value of the node, the returned slice is backed by the arena and must not be modified.
+func (n *MemDBNode) Value() []byte {
+	return n.db.vlog.getValue(n.node.vptr)
+}
+
+// Flags returns the flags associated with the key of the node.
+func (n *MemDBNode) Flags() kv.KeyFlags {
+	return n.node.getKeyFlags()
+}
+
+// WalkRange calls fn for every key-value pair in [start, end) in ascending key order, until fn returns false.
+// A nil start or end means unbounded. Like Iter, the flags only keys are skipped.
+// The keys and values are read in place from the arena without allocation, writes to the MemDB are
+// blocked during the walk, so fn must not modify the MemDB.
+func (db *MemDB) WalkRange(start, end []byte, fn func(node *MemDBNode) bool) {
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	db.RLock()
+	defer db.RUnlock()
+
+	it := MemdbIterator{
+		db:    db,
+		start: start,
+		end:   end,
+	}
+	it.init()
+	node := MemDBNode{db: db}
+	for ; it.Valid(); _ = it.Next() {
+		node.node = it.curr
+		if !fn(&node) {
+			break
+		}
+	}
+}
+
 func (i *MemdbIterator) init() {
 	if i.reverse {
 		if len(i.end) == 0 {
@@ -100,12 +199,21 @@ func (i *MemdbIterator) init() {
 		err := i.Next()
 		_ = err // memdbIterator will never fail
 	}
+	i.checkPrefix()
+}
+
+// checkPrefix invalidates the iterator once the current key is out of the prefix range.
+func (i *MemdbIterator) checkPrefix() {
+	if i.prefix != nil && !i.curr.isNull() && !bytes.HasPrefix(i.Key(), i.prefix) {
+		i.curr = memdbNodeAddr{nil, nullAddr}
+	}
 }
 
 // Valid returns true if the current iterator is valid.
 func (i *MemdbIterator) Valid() bool {
 	if !i.reverse {
-		return !i.curr.isNull() && (i.end == nil || bytes.Compare(i.Key(), i.end) < 0)
+		// Keep consistent with init, an empty end is unbounded as well.
+		return !i.curr.isNull() && (len(i.end) == 0 || bytes.Compare(i.Key(), i.end) < 0)
 	}
 	return !i.curr.isNull()
 }
@@ -154,6 +262,9 @@ func (i *MemdbIterator) Next() error {
 			i.curr = i.db.successor(i.curr)
 		}
 
+		// Stop as soon as the key is out of the prefix range.
+		i.checkPrefix()
+
 		// We need to skip persistent flags only nodes.
 		if i.includeFlags || !i.isFlagsOnly() {
 			break
@@ -163,7 +274,18 @@ func (i *MemdbIterator) Next() error {
 }
 
 // Close closes the current iterator.
-func (i *MemdbIterator) Close() {}
+func (i *MemdbIterator) Close() {
+	if i.tracked {
+		i.tracked = false
+		atomic.AddInt32(&i.db.aliveIters, -1)
+	}
+}
+
+// track counts the iterator in MemDB.aliveIters, so that CompactArena can detect it.
+func (i *MemdbIterator) track() {
+	i.tracked = true
+	atomic.AddInt32(&i.db.aliveIters, 1)
+}
 
 func (i *MemdbIterator) seekToFirst() {
 	y := memdbNodeAddr{nil, nullAddr}
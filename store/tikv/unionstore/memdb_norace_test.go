@@ -22,6 +22,7 @@ import (
 	. "github.com/pingcap/check"
 	"github.com/pingcap/goleveldb/leveldb/comparer"
 	leveldb "github.com/pingcap/goleveldb/leveldb/memdb"
+	"github.com/pingcap/goleveldb/leveldb/util"
 )
 
 // The test takes too long under the race detector.
@@ -61,6 +62,72 @@ func (s testMemDBSuite) TestRandom(c *C) {
 	s.checkConsist(c, p1, p2)
 }
 
+// The test takes too long under the race detector.
+func (s testMemDBSuite) TestRandomIterRange(c *C) {
+	c.Parallel()
+	const cnt = 10000
+	// Short keys make the random bounds hit the existing keys often.
+	randKey := func() []byte {
+		k := make([]byte, rand.Intn(3)+1)
+		rand.Read(k)
+		return k
+	}
+	keys := make([][]byte, cnt)
+	db := newMemDB()
+	golden := leveldb.New(comparer.DefaultComparer, 4*1024)
+	for i := range keys {
+		keys[i] = randKey()
+		_ = db.Set(keys[i], keys[i])
+		_ = golden.Put(keys[i], keys[i])
+	}
+
+	randBound := func() []byte {
+		switch op := rand.Float64(); {
+		case op < 0.2:
+			return nil
+		case op < 0.4:
+			return []byte{}
+		case op < 0.7:
+			return keys[rand.Intn(cnt)]
+		default:
+			return randKey()
+		}
+	}
+	// The golden iterator treats an empty limit as an empty range, while MemDB treats it as unbounded.
+	toLimit := func(bound []byte) []byte {
+		if len(bound) == 0 {
+			return nil
+		}
+		return bound
+	}
+
+	for i := 0; i < 1000; i++ {
+		start, end := randBound(), randBound()
+		it, err := db.Iter(start, end)
+		c.Assert(err, IsNil)
+		gIt := golden.NewIterator(&util.Range{Start: start, Limit: toLimit(end)})
+		for gIt.First(); gIt.Valid(); gIt.Next() {
+			c.Assert(it.Valid(), IsTrue, Commentf("start %v end %v", start, end))
+			c.Assert(it.Key(), BytesEquals, gIt.Key())
+			c.Assert(it.Value(), BytesEquals, gIt.Value())
+			c.Assert(it.Next(), IsNil)
+		}
+		c.Assert(it.Valid(), IsFalse, Commentf("start %v end %v", start, end))
+		gIt.Release()
+
+		it, err = db.IterReverse(end)
+		c.Assert(err, IsNil)
+		gIt = golden.NewIterator(&util.Range{Limit: toLimit(end)})
+		for ok := gIt.Last(); ok; ok = gIt.Prev() {
+			c.Assert(it.Valid(), IsTrue, Commentf("end %v", end))
+			c.Assert(it.Key(), BytesEquals, gIt.Key())
+			c.Assert(it.Next(), IsNil)
+		}
+		c.Assert(it.Valid(), IsFalse, Commentf("end %v", end))
+		gIt.Release()
+	}
+}
+
 // The test takes too long under the race detector.
 func (s testMemDBSuite) TestRandomDerive(c *C) {
 	c.Parallel()
@@ -0,0 +1,88 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	tikverr "github.com/pingcap/tidb/store/tikv/error"
+	"github.com/pingcap/tidb/store/tikv/kv"
+)
+
+// ReadonlyMemDB is the read-only access to a MemDB, it's handed to the workers which only read the
+// write-set, e.g. the parallel scan workers, so that they can't modify it by mistake.
+// It's a view rather than a snapshot, the reads see the current state of the MemDB, and it's not safe
+// to read it concurrently with the writes of the MemDB.
+type ReadonlyMemDB interface {
+	Getter
+	// GetWithFlags returns the value and the flags of key. Like Get, it returns ErrNotExist if the value
+	// doesn't exist, but the flags are still returned for a flags only key.
+	GetWithFlags(key []byte) ([]byte, kv.KeyFlags, error)
+	// Iter creates an Iterator positioned on the first entry that k <= entry's key.
+	// It yields only keys that < upperBound. If upperBound is nil, it means the upperBound is unbounded.
+	Iter(k []byte, upperBound []byte) (Iterator, error)
+	// IterReverse creates a reversed Iterator positioned on the first entry which key is less than k.
+	IterReverse(k []byte) (Iterator, error)
+	// Len returns the number of entries in the MemDB.
+	Len() int
+	// SizeOf returns the length of the value of key, -1 is returned for a tombstone.
+	SizeOf(key []byte) (int, error)
+}
+
+// memdbReadonlyView implements ReadonlyMemDB. It wraps the MemDB instead of exposing it with a narrower
+// interface, so the writes can't be reached by a type assertion.
+type memdbReadonlyView struct {
+	db *MemDB
+}
+
+// ReadonlyView returns a ReadonlyMemDB backed by db.
+func (db *MemDB) ReadonlyView() ReadonlyMemDB {
+	return memdbReadonlyView{db: db}
+}
+
+func (v memdbReadonlyView) Get(key []byte) ([]byte, error) {
+	return v.db.Get(key)
+}
+
+func (v memdbReadonlyView) GetWithFlags(key []byte) ([]byte, kv.KeyFlags, error) {
+	db := v.db
+	if db.vlogInvalid {
+		// panic for easier debugging.
+		panic("vlog is resetted")
+	}
+
+	x := db.traverse(key, false)
+	if x.isNull() {
+		return nil, 0, tikverr.ErrNotExist
+	}
+	flags := x.getKeyFlags()
+	if x.vptr.isNull() || db.isExpired(key) {
+		return nil, flags, tikverr.ErrNotExist
+	}
+	return db.vlog.getValue(x.vptr), flags, nil
+}
+
+func (v memdbReadonlyView) Iter(k []byte, upperBound []byte) (Iterator, error) {
+	return v.db.Iter(k, upperBound)
+}
+
+func (v memdbReadonlyView) IterReverse(k []byte) (Iterator, error) {
+	return v.db.IterReverse(k)
+}
+
+func (v memdbReadonlyView) Len() int {
+	return v.db.Len()
+}
+
+func (v memdbReadonlyView) SizeOf(key []byte) (int, error) {
+	return v.db.SizeOf(key)
+}
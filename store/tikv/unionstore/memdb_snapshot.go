@@ -14,6 +14,9 @@
 package unionstore
 
 import (
+	"encoding/binary"
+
+	"github.com/pingcap/errors"
 	tikverr "github.com/pingcap/tidb/store/tikv/error"
 )
 
@@ -27,18 +30,97 @@ func (db *MemDB) SnapshotGetter() Getter {
 
 // SnapshotIter returns a Iterator for a snapshot of MemBuffer.
 func (db *MemDB) SnapshotIter(start, end []byte) Iterator {
+	return db.snapshotIter(start, end, db.getSnapshot())
+}
+
+func (db *MemDB) snapshotIter(start, end []byte, cp memdbCheckpoint) *memdbSnapIter {
 	it := &memdbSnapIter{
 		MemdbIterator: &MemdbIterator{
 			db:    db,
 			start: start,
 			end:   end,
 		},
-		cp: db.getSnapshot(),
+		cp: cp,
 	}
 	it.init()
 	return it
 }
 
+// MemDBSnapshot is a snapshot of MemDB which can be marshaled to bytes and restored by RestoreSnapshot.
+type MemDBSnapshot struct {
+	db *MemDB
+	cp memdbCheckpoint
+}
+
+// Snapshot returns a snapshot of the MemDB, it includes the writes before it except the ones in the staging buffers.
+// The values in the snapshot are not overwritten in place by the later writes, but the snapshot becomes invalid
+// if they are discarded, i.e. by Cleanup, RollbackTo, GC, CompactArena or Reset.
+func (db *MemDB) Snapshot() (MemDBSnapshot, error) {
+	if db.vlogInvalid {
+		return MemDBSnapshot{}, errors.New("cannot take snapshot after values are discarded")
+	}
+	db.Lock()
+	defer db.Unlock()
+	cp := db.getSnapshot()
+	db.snapshotCp = &cp
+	return MemDBSnapshot{db: db, cp: cp}, nil
+}
+
+// Marshal encodes the key-value pairs in the snapshot to a length-prefixed list, that is
+// uvarint(len(key)) + key + uvarint(len(value)) + value for each pair, in key order.
+// A deleted key is encoded with an empty value.
+// The format is independent of the internal arena layout, so it keeps stable across versions.
+func (snap MemDBSnapshot) Marshal() ([]byte, error) {
+	if snap.db == nil {
+		return nil, errors.New("invalid snapshot")
+	}
+	var lenBuf [binary.MaxVarintLen64]byte
+	data := make([]byte, 0, snap.db.Size()+snap.db.Len()*2)
+	it := snap.db.snapshotIter(nil, nil, snap.cp)
+	defer it.Close()
+	// memdbSnapIter will never fail.
+	for ; it.Valid(); _ = it.Next() {
+		key, value := it.Key(), it.Value()
+		data = append(data, lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(key)))]...)
+		data = append(data, key...)
+		data = append(data, lenBuf[:binary.PutUvarint(lenBuf[:], uint64(len(value)))]...)
+		data = append(data, value...)
+	}
+	return data, nil
+}
+
+// RestoreSnapshot writes the key-value pairs encoded by MemDBSnapshot.Marshal to the MemDB.
+func (db *MemDB) RestoreSnapshot(data []byte) error {
+	for len(data) > 0 {
+		key, rest, err := decodeSnapshotBytes(data)
+		if err != nil {
+			return err
+		}
+		value, rest, err := decodeSnapshotBytes(rest)
+		if err != nil {
+			return err
+		}
+		data = rest
+		if len(value) == 0 {
+			err = db.Delete(key)
+		} else {
+			err = db.Set(key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeSnapshotBytes(data []byte) ([]byte, []byte, error) {
+	l, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < l {
+		return nil, nil, errors.New("invalid snapshot data")
+	}
+	return data[n : n+int(l)], data[n+int(l):], nil
+}
+
 func (db *MemDB) getSnapshot() memdbCheckpoint {
 	if len(db.stages) > 0 {
 		return db.stages[0]
@@ -16,12 +16,20 @@
 package unionstore
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unsafe"
 
 	. "github.com/pingcap/check"
 	leveldb "github.com/pingcap/goleveldb/leveldb/memdb"
+	tikverr "github.com/pingcap/tidb/store/tikv/error"
 	"github.com/pingcap/tidb/store/tikv/kv"
 	"github.com/pingcap/tidb/store/tikv/util/testleak"
 )
@@ -105,6 +113,167 @@ func (s *testMemDBSuite) TestIterator(c *C) {
 	c.Assert(i, Equals, -1)
 }
 
+func (s *testMemDBSuite) TestIterSingleKey(c *C) {
+	db := newMemDB()
+	key := []byte{0}
+	c.Assert(db.Set(key, key), IsNil)
+
+	checkIter := func(it Iterator) {
+		c.Assert(it.Valid(), IsTrue)
+		c.Assert(it.Key(), BytesEquals, key)
+		c.Assert(it.Value(), BytesEquals, key)
+		c.Assert(it.Next(), IsNil)
+		c.Assert(it.Valid(), IsFalse)
+		it.Close()
+	}
+	for _, bounds := range [][2][]byte{{nil, nil}, {{}, {}}, {nil, {}}, {{}, nil}, {nil, {1}}} {
+		it, err := db.Iter(bounds[0], bounds[1])
+		c.Assert(err, IsNil)
+		checkIter(it)
+		checkIter(db.IterWithFlags(bounds[0], bounds[1]))
+	}
+	for _, bound := range [][]byte{nil, {}, {1}} {
+		it, err := db.IterReverse(bound)
+		c.Assert(err, IsNil)
+		checkIter(it)
+	}
+
+	// The key is the only one in the tree, so it's also the root.
+	var cnt int
+	db.WalkRange(nil, nil, func(node *MemDBNode) bool {
+		c.Assert(node.Key(), BytesEquals, key)
+		cnt++
+		return true
+	})
+	c.Assert(cnt, Equals, 1)
+
+	// A flags only key before it must be skipped.
+	db.UpdateFlags([]byte{}, kv.SetPresumeKeyNotExists)
+	it, err := db.Iter(nil, nil)
+	c.Assert(err, IsNil)
+	checkIter(it)
+}
+
+func (s *testMemDBSuite) TestPrefixScan(c *C) {
+	const cnt = 10000
+	db := s.fillDB(cnt)
+	// The flags only keys are skipped.
+	db.UpdateFlags([]byte{0, 0, 0x10, 0x00, 0}, kv.SetPresumeKeyNotExists)
+	db.UpdateFlags([]byte{0, 0, 0x11}, kv.SetPresumeKeyNotExists)
+
+	checkPrefix := func(prefix []byte, start, end int) {
+		var buf [4]byte
+		i := start
+		it, err := db.PrefixScan(prefix)
+		c.Assert(err, IsNil)
+		for ; it.Valid(); _ = it.Next() {
+			binary.BigEndian.PutUint32(buf[:], uint32(i))
+			c.Assert(it.Key(), BytesEquals, buf[:])
+			c.Assert(it.Value(), BytesEquals, buf[:])
+			i++
+		}
+		it.Close()
+		c.Assert(i, Equals, end)
+	}
+	checkPrefix([]byte{0, 0, 0x10}, 0x1000, 0x1100)
+	checkPrefix([]byte{0, 0, 0x27}, 0x2700, cnt)
+	checkPrefix([]byte{0, 0, 0x12, 0x34}, 0x1234, 0x1235)
+	checkPrefix([]byte{0, 0x01}, 0, 0)
+	checkPrefix(nil, 0, cnt)
+}
+
+func (s *testMemDBSuite) TestWalkRange(c *C) {
+	const cnt = 10000
+	db := s.fillDB(cnt)
+	db.UpdateFlags([]byte{0, 0, 0x10, 0x00, 0}, kv.SetPresumeKeyNotExists)
+
+	var buf [4]byte
+	i := 0x1000
+	db.WalkRange([]byte{0, 0, 0x10, 0x00}, []byte{0, 0, 0x11, 0x00}, func(node *MemDBNode) bool {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(node.Key(), BytesEquals, buf[:])
+		c.Assert(node.Value(), BytesEquals, buf[:])
+		c.Assert(node.Flags(), Equals, kv.KeyFlags(0))
+		i++
+		return true
+	})
+	c.Assert(i, Equals, 0x1100)
+
+	i = 0
+	db.WalkRange(nil, nil, func(node *MemDBNode) bool {
+		i++
+		return i < 10
+	})
+	c.Assert(i, Equals, 10)
+}
+
+func (s *testMemDBSuite) TestWalkRangeConcurrentWrite(c *C) {
+	db := s.fillDB(100)
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], 50)
+
+	walking := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		<-walking
+		// Set a value with the same length, which would be modified in place if not blocked.
+		c.Check(db.Set(key[:], []byte{0xff, 0xff, 0xff, 0xff}), IsNil)
+		close(done)
+	}()
+
+	db.WalkRange(key[:], nil, func(node *MemDBNode) bool {
+		close(walking)
+		val := node.Value()
+		select {
+		case <-done:
+			c.Fatal("MemDB is modified during WalkRange")
+		case <-time.After(50 * time.Millisecond):
+		}
+		c.Assert(val, BytesEquals, key[:])
+		c.Assert(node.Value(), BytesEquals, key[:])
+		return false
+	})
+	<-done
+
+	val, err := db.Get(key[:])
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte{0xff, 0xff, 0xff, 0xff})
+}
+
+func (s *testMemDBSuite) TestForEach(c *C) {
+	const cnt = 10000
+	db := s.fillDB(cnt)
+	db.UpdateFlags([]byte{0xff}, kv.SetPresumeKeyNotExists)
+
+	var buf [4]byte
+	var i int
+	err := db.ForEach(func(key, value []byte) bool {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(key, BytesEquals, buf[:])
+		c.Assert(value, BytesEquals, buf[:])
+		i++
+		return true
+	})
+	c.Assert(err, IsNil)
+	c.Assert(i, Equals, cnt)
+
+	i = 0
+	err = db.ForEach(func(key, value []byte) bool {
+		i++
+		return i < 10
+	})
+	c.Assert(err, IsNil)
+	c.Assert(i, Equals, 10)
+
+	i = 0
+	err = newMemDB().ForEach(func(key, value []byte) bool {
+		i++
+		return true
+	})
+	c.Assert(err, IsNil)
+	c.Assert(i, Equals, 0)
+}
+
 func (s *testMemDBSuite) TestDiscard(c *C) {
 	const cnt = 10000
 	db := newMemDB()
@@ -158,6 +327,73 @@ func (s *testMemDBSuite) TestDiscard(c *C) {
 	c.Assert(it.Valid(), IsFalse)
 }
 
+func (s *testMemDBSuite) TestCleanupCompact(c *C) {
+	const cnt = 100
+	db := s.fillDB(cnt)
+	capacity := db.allocator.capacity
+
+	db.Cleanup(s.deriveAndFill(cnt, 100*cnt, 0, db))
+	c.Assert(db.allocator.freedSize, Equals, 0)
+	c.Assert(db.allocator.capacity, Equals, capacity)
+	c.Assert(db.Len(), Equals, cnt)
+
+	var buf [4]byte
+	for i := 0; i < 100*cnt; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		v, err := db.Get(buf[:])
+		if i < cnt {
+			c.Assert(err, IsNil)
+			c.Assert(v, BytesEquals, buf[:])
+		} else {
+			c.Assert(err, NotNil)
+		}
+	}
+
+	// The node addresses in vlog should be relocated.
+	var i int
+	head, tail := memdbCheckpoint{}, db.vlog.checkpoint()
+	db.vlog.inspectKVInLog(db, &head, &tail, func(k []byte, kf kv.KeyFlags, v []byte) {
+		binary.BigEndian.PutUint32(buf[:], uint32(cnt-1-i))
+		c.Assert(k, BytesEquals, buf[:])
+		c.Assert(v, BytesEquals, buf[:])
+		i++
+	})
+	c.Assert(i, Equals, cnt)
+
+	db.Cleanup(s.deriveAndFill(0, cnt, 1, db))
+	for i := 0; i < cnt; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		v, err := db.Get(buf[:])
+		c.Assert(err, IsNil)
+		c.Assert(v, BytesEquals, buf[:])
+	}
+}
+
+func (s *testMemDBSuite) TestCleanupCompactAliveIterator(c *C) {
+	const cnt = 100
+	db := s.fillDB(cnt)
+	it, err := db.Iter(nil, nil)
+	c.Assert(err, IsNil)
+
+	// The compaction is skipped while the iterator is alive, since it would move the nodes under the iterator.
+	db.Cleanup(s.deriveAndFill(cnt, 100*cnt, 0, db))
+	c.Assert(db.allocator.freedSize, Not(Equals), 0)
+	var buf [4]byte
+	for i := 0; i < cnt; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(it.Valid(), IsTrue)
+		c.Assert(it.Key(), BytesEquals, buf[:])
+		c.Assert(it.Value(), BytesEquals, buf[:])
+		c.Assert(it.Next(), IsNil)
+	}
+	c.Assert(it.Valid(), IsFalse)
+	it.Close()
+
+	db.Cleanup(s.deriveAndFill(cnt, 2*cnt, 0, db))
+	c.Assert(db.allocator.freedSize, Equals, 0)
+	c.Assert(db.Len(), Equals, cnt)
+}
+
 func (s *testMemDBSuite) TestFlushOverwrite(c *C) {
 	const cnt = 10000
 	db := newMemDB()
@@ -462,6 +698,215 @@ func (s *testMemDBSuite) TestDirty(c *C) {
 	c.Assert(db.Dirty(), IsFalse)
 }
 
+func (s *testMemDBSuite) TestMemUsage(c *C) {
+	const cnt = 1000
+	db := newMemDB()
+	stats := db.MemUsage()
+	c.Assert(stats, Equals, MemDBMemStats{})
+
+	h := s.deriveAndFill(0, cnt, 0, db)
+	stats = db.MemUsage()
+	c.Assert(stats.NodeCount, Equals, int64(cnt))
+	c.Assert(stats.KeyBytes, Equals, int64(cnt*4))
+	c.Assert(stats.ValueBytes, Equals, int64(cnt*4))
+	c.Assert(stats.ArenaBytes >= int64(cnt*(4+4+memdbVlogHdrSize)), IsTrue)
+	c.Assert(stats.KeyBytes+stats.ValueBytes, Equals, int64(db.Size()))
+
+	db.Cleanup(h)
+	stats = db.MemUsage()
+	c.Assert(stats.NodeCount, Equals, int64(0))
+	c.Assert(stats.KeyBytes, Equals, int64(0))
+	c.Assert(stats.ValueBytes, Equals, int64(0))
+
+	db.Reset()
+	c.Assert(db.MemUsage(), Equals, MemDBMemStats{})
+}
+
+func (s *testMemDBSuite) TestEstimateMemoryFootprint(c *C) {
+	const cnt = 100000
+	var before, after runtime.MemStats
+	key, val := make([]byte, 16), make([]byte, 64)
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	db := newMemDB()
+	for i := uint64(0); i < cnt; i++ {
+		binary.BigEndian.PutUint64(key, i)
+		binary.BigEndian.PutUint64(val, i)
+		c.Assert(db.Set(key, val), IsNil)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	estimate := db.EstimateMemoryFootprint()
+	c.Assert(estimate > db.MemUsage().ArenaBytes, IsTrue)
+	delta := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	diff := estimate - delta
+	if diff < 0 {
+		diff = -diff
+	}
+	c.Assert(diff <= delta/10, IsTrue, Commentf("estimate %d, heap delta %d", estimate, delta))
+	runtime.KeepAlive(db)
+
+	db.Reset()
+	c.Assert(db.EstimateMemoryFootprint() < estimate, IsTrue)
+}
+
+func (s *testMemDBSuite) TestInspectArena(c *C) {
+	const cnt = 10000
+	db := s.fillDB(cnt)
+	// The nodes of the keys added in a cleaned up staging buffer are freed,
+	// the outer staging buffer prevents them from being compacted.
+	outer := db.Staging()
+	h := db.Staging()
+	var buf [4]byte
+	for i := cnt; i < 2*cnt; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(db.Set(buf[:], buf[:]), IsNil)
+	}
+	db.Cleanup(h)
+
+	var live, dead, lastOffset int
+	lastOffset = -1
+	db.InspectArena(func(offset int, size int, isLive bool) {
+		c.Assert(offset > lastOffset, IsTrue)
+		c.Assert(offset%8, Equals, 0)
+		c.Assert(size, Equals, nodeSize(len(buf)))
+		lastOffset = offset
+		if isLive {
+			live++
+		} else {
+			dead++
+		}
+	})
+	c.Assert(live, Equals, cnt)
+	c.Assert(dead, Equals, cnt)
+	c.Assert(dead*nodeSize(len(buf)), Equals, db.allocator.freedSize)
+	db.Release(outer)
+}
+
+func (s *testMemDBSuite) TestSetWithTTL(c *C) {
+	db := newMemDB()
+	now := time.Unix(1600000000, 0)
+	db.now = func() time.Time { return now }
+
+	const cnt = 10000
+	var buf [4]byte
+	for i := 0; i < cnt; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		// The keys expire one second after another.
+		c.Assert(db.SetWithTTL(buf[:], buf[:], now.Add(time.Duration(i)*time.Second)), IsNil)
+	}
+	// The key without TTL never expires, and setting the key again clears its TTL.
+	c.Assert(db.Set([]byte{0xff}, []byte{0xff}), IsNil)
+	binary.BigEndian.PutUint32(buf[:], uint32(cnt-1))
+	c.Assert(db.Set(buf[:], buf[:]), IsNil)
+
+	checkGet := func(i int, expired bool) {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		v, err := db.Get(buf[:])
+		if expired {
+			c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+			c.Assert(v, IsNil)
+		} else {
+			c.Assert(err, IsNil)
+			c.Assert(v, BytesEquals, buf[:])
+		}
+	}
+
+	// The key expires exactly at the expiration time.
+	now = now.Add(cnt / 2 * time.Second)
+	checkGet(cnt/2-1, true)
+	checkGet(cnt/2, true)
+	checkGet(cnt/2+1, false)
+	// Get doesn't remove the expired keys.
+	c.Assert(db.Len(), Equals, cnt+1)
+
+	h := db.Staging()
+	c.Assert(db.PurgeExpired(), Equals, 0)
+	db.Cleanup(h)
+	c.Assert(db.PurgeExpired(), Equals, cnt/2+1)
+	c.Assert(db.Len(), Equals, cnt/2)
+	c.Assert(db.LiveLen(), Equals, cnt/2)
+	c.Assert(db.Size(), Equals, (cnt/2-1)*8+2)
+	for i := 0; i < cnt; i++ {
+		checkGet(i, i <= cnt/2)
+	}
+	it, err := db.Iter(nil, nil)
+	c.Assert(err, IsNil)
+	binary.BigEndian.PutUint32(buf[:], uint32(cnt/2+1))
+	c.Assert(it.Key(), BytesEquals, buf[:])
+
+	now = now.Add(cnt * time.Second)
+	c.Assert(db.PurgeExpired(), Equals, cnt/2-2)
+	c.Assert(db.Len(), Equals, 2)
+	checkGet(cnt-1, false)
+	v, err := db.Get([]byte{0xff})
+	c.Assert(err, IsNil)
+	c.Assert(v, BytesEquals, []byte{0xff})
+	c.Assert(db.PurgeExpired(), Equals, 0)
+}
+
+func (s *testMemDBSuite) TestGC(c *C) {
+	const (
+		cnt     = 100000
+		deleted = 90000
+	)
+	db := newMemDB()
+	key, val := make([]byte, 8), make([]byte, 256)
+	for i := uint64(0); i < cnt; i++ {
+		binary.BigEndian.PutUint64(key, i)
+		binary.BigEndian.PutUint64(val, i)
+		c.Assert(db.Set(key, val), IsNil)
+	}
+	for i := uint64(0); i < deleted; i++ {
+		binary.BigEndian.PutUint64(key, i)
+		c.Assert(db.Delete(key), IsNil)
+	}
+
+	c.Assert(db.GC(0.95), Equals, int64(0))
+	h := db.Staging()
+	c.Assert(db.GC(0.3), Equals, int64(0))
+	db.Cleanup(h)
+
+	before := db.EstimateMemoryFootprint()
+	freed := db.GC(0.3)
+	after := db.EstimateMemoryFootprint()
+	c.Assert(freed, Equals, before-after)
+	// The tombstones are kept, so the nodes of the deleted keys are not reclaimed.
+	c.Assert(after*2 <= before, IsTrue, Commentf("before %d, after %d", before, after))
+	c.Assert(db.Len(), Equals, cnt)
+	c.Assert(db.LiveLen(), Equals, cnt-deleted)
+
+	for i := uint64(0); i < cnt; i++ {
+		binary.BigEndian.PutUint64(key, i)
+		v, err := db.Get(key)
+		c.Assert(err, IsNil)
+		if i < deleted {
+			c.Assert(IsTombstone(v), IsTrue)
+		} else {
+			binary.BigEndian.PutUint64(val, i)
+			c.Assert(v, BytesEquals, val)
+		}
+	}
+
+	// The MemDB is still writable and rollbackable after GC.
+	h = db.Staging()
+	binary.BigEndian.PutUint64(key, cnt)
+	c.Assert(db.Set(key, key), IsNil)
+	binary.BigEndian.PutUint64(key, cnt-1)
+	c.Assert(db.Delete(key), IsNil)
+	db.Cleanup(h)
+	v, err := db.Get(key)
+	c.Assert(err, IsNil)
+	binary.BigEndian.PutUint64(val, cnt-1)
+	c.Assert(v, BytesEquals, val)
+	binary.BigEndian.PutUint64(key, cnt)
+	_, err = db.Get(key)
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+	c.Assert(db.Len(), Equals, cnt)
+}
+
 func (s *testMemDBSuite) TestFlags(c *C) {
 	const cnt = 10000
 	db := newMemDB()
@@ -525,81 +970,1365 @@ func (s *testMemDBSuite) TestFlags(c *C) {
 	}
 }
 
-func (s *testMemDBSuite) checkConsist(c *C, p1 *MemDB, p2 *leveldb.DB) {
-	c.Assert(p1.Len(), Equals, p2.Len())
-	c.Assert(p1.Size(), Equals, p2.Size())
-
-	it1, _ := p1.Iter(nil, nil)
-	it2 := p2.NewIterator(nil)
-
-	var prevKey, prevVal []byte
-	for it2.First(); it2.Valid(); it2.Next() {
-		v, err := p1.Get(it2.Key())
-		c.Assert(err, IsNil)
-		c.Assert(v, BytesEquals, it2.Value())
-
-		c.Assert(it1.Key(), BytesEquals, it2.Key())
-		c.Assert(it1.Value(), BytesEquals, it2.Value())
-
-		it, _ := p1.Iter(it2.Key(), nil)
-		c.Assert(it.Key(), BytesEquals, it2.Key())
-		c.Assert(it.Value(), BytesEquals, it2.Value())
-
-		if prevKey != nil {
-			it, _ = p1.IterReverse(it2.Key())
-			c.Assert(it.Key(), BytesEquals, prevKey)
-			c.Assert(it.Value(), BytesEquals, prevVal)
-		}
-
-		_ = it1.Next()
-		prevKey = it2.Key()
-		prevVal = it2.Value()
-	}
+func (s *testMemDBSuite) TestGetFlagsAfterDiscardValues(c *C) {
+	db := newMemDB()
+	c.Assert(db.SetWithFlags([]byte{1}, []byte{1}, kv.SetKeyLocked), IsNil)
+	c.Assert(db.Set([]byte{2}, []byte{2}), IsNil)
+	db.DiscardValues()
 
-	it1, _ = p1.IterReverse(nil)
-	for it2.Last(); it2.Valid(); it2.Prev() {
-		c.Assert(it1.Key(), BytesEquals, it2.Key())
-		c.Assert(it1.Value(), BytesEquals, it2.Value())
-		_ = it1.Next()
-	}
+	flags, err := db.GetFlags([]byte{1})
+	c.Assert(err, IsNil)
+	c.Assert(flags.HasLocked(), IsTrue)
+	flags, err = db.GetFlags([]byte{2})
+	c.Assert(err, IsNil)
+	c.Assert(flags.HasLocked(), IsFalse)
+	_, err = db.GetFlags([]byte{3})
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
 }
 
-func (s *testMemDBSuite) fillDB(cnt int) *MemDB {
+func (s *testMemDBSuite) TestSnapshotGetter(c *C) {
 	db := newMemDB()
-	h := s.deriveAndFill(0, cnt, 0, db)
-	db.Release(h)
-	return db
-}
+	c.Assert(db.Set([]byte{1}, []byte{1}), IsNil)
+	c.Assert(db.Set([]byte{2}, []byte{2}), IsNil)
+	c.Assert(db.Set([]byte{3}, []byte{3}), IsNil)
+	c.Assert(db.Delete([]byte{3}), IsNil)
 
-func (s *testMemDBSuite) deriveAndFill(start, end, valueBase int, db *MemDB) int {
+	// The snapshot excludes the changes in the staging buffer.
 	h := db.Staging()
-	var kbuf, vbuf [4]byte
-	for i := start; i < end; i++ {
-		binary.BigEndian.PutUint32(kbuf[:], uint32(i))
-		binary.BigEndian.PutUint32(vbuf[:], uint32(i+valueBase))
-		db.Set(kbuf[:], vbuf[:])
+	c.Assert(db.Set([]byte{1}, []byte{11}), IsNil)
+	c.Assert(db.Delete([]byte{2}), IsNil)
+	c.Assert(db.Set([]byte{3}, []byte{33}), IsNil)
+	c.Assert(db.Set([]byte{4}, []byte{44}), IsNil)
+
+	snap := db.SnapshotGetter()
+	v, err := snap.Get([]byte{1})
+	c.Assert(err, IsNil)
+	c.Assert(v, BytesEquals, []byte{1})
+	v, err = snap.Get([]byte{2})
+	c.Assert(err, IsNil)
+	c.Assert(v, BytesEquals, []byte{2})
+	v, err = snap.Get([]byte{3})
+	c.Assert(err, IsNil)
+	c.Assert(v, HasLen, 0)
+	_, err = snap.Get([]byte{4})
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+
+	it := db.SnapshotIter(nil, nil)
+	for _, expected := range [][2][]byte{{{1}, {1}}, {{2}, {2}}, {{3}, {}}} {
+		c.Assert(it.Valid(), IsTrue)
+		c.Assert(it.Key(), BytesEquals, expected[0])
+		c.Assert(it.Value(), BytesEquals, expected[1])
+		c.Assert(it.Next(), IsNil)
 	}
-	return h
+	c.Assert(it.Valid(), IsFalse)
+	it.Close()
+	db.Cleanup(h)
 }
 
-const (
-	startIndex = 0
-	testCount  = 2
-	indexStep  = 2
-)
-
-type testKVSuite struct {
-	bs []*MemDB
-}
+func (s *testMemDBSuite) TestCAS(c *C) {
+	db := newMemDB()
+	key := []byte("k")
 
-func (s *testKVSuite) SetUpSuite(c *C) {
-	s.bs = make([]*MemDB, 1)
-	s.bs[0] = newMemDB()
-}
+	ok, err := db.CAS(key, []byte("v0"), []byte("v1"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+	c.Assert(db.Len(), Equals, 0)
 
-func (s *testKVSuite) ResetMembuffers() {
-	s.bs[0] = newMemDB()
-}
+	ok, err = db.CAS(key, nil, []byte("v1"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	ok, err = db.CAS(key, nil, []byte("v2"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+
+	ok, err = db.CAS(key, []byte("v2"), []byte("v3"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+	ok, err = db.CAS(key, []byte("v1"), []byte("v2"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	val, err := db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("v2"))
+
+	// A deleted key acts as not existing.
+	c.Assert(db.Delete(key), IsNil)
+	ok, err = db.CAS(key, []byte("v2"), []byte("v3"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+	ok, err = db.CAS(key, nil, []byte("v3"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+
+	// The swap can be rollbacked by staging.
+	h := db.Staging()
+	ok, err = db.CAS(key, []byte("v3"), []byte("v4"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	db.Cleanup(h)
+	val, err = db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("v3"))
+
+	_, err = db.CAS(key, []byte("v3"), nil)
+	c.Assert(err, NotNil)
+
+	// An expired key doesn't exist for CAS either.
+	now := time.Unix(1600000000, 0)
+	db.now = func() time.Time { return now }
+	c.Assert(db.SetWithTTL(key, []byte("v5"), now.Add(time.Second)), IsNil)
+	now = now.Add(time.Second)
+	ok, err = db.CAS(key, []byte("v5"), []byte("v6"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsFalse)
+	ok, err = db.CAS(key, nil, []byte("v6"))
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+	val, err = db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("v6"))
+}
+
+func (s *testMemDBSuite) TestBatchSet(c *C) {
+	const cnt = 10000
+	db := newMemDB()
+	pairs := make([]kv.Pair, 0, cnt)
+	for _, i := range rand.Perm(cnt) {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		pairs = append(pairs, kv.Pair{Key: buf[:], Value: buf[:]})
+	}
+	c.Assert(db.BatchSet(pairs), IsNil)
+	c.Assert(db.Dirty(), IsTrue)
+	checkBatchSet := func() {
+		c.Assert(db.Len(), Equals, cnt)
+		var buf [4]byte
+		i := 0
+		for it, _ := db.Iter(nil, nil); it.Valid(); _ = it.Next() {
+			binary.BigEndian.PutUint32(buf[:], uint32(i))
+			c.Assert(it.Key(), BytesEquals, buf[:])
+			c.Assert(it.Value(), BytesEquals, buf[:])
+			i++
+		}
+		c.Assert(i, Equals, cnt)
+	}
+	checkBatchSet()
+
+	// Overwrite in a staging buffer and discard it.
+	h := db.Staging()
+	c.Assert(db.BatchSet([]kv.Pair{{Key: []byte{0, 0, 0, 1}, Value: []byte{1}}, {Key: []byte{0xff}, Value: []byte{2}}}), IsNil)
+	val, err := db.Get([]byte{0, 0, 0, 1})
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte{1})
+	db.Cleanup(h)
+	checkBatchSet()
+
+	// Nothing is written if any of the values is empty.
+	err = db.BatchSet([]kv.Pair{{Key: []byte{0xfe}, Value: []byte{1}}, {Key: []byte{0xff}, Value: nil}})
+	c.Assert(err, NotNil)
+	_, err = db.Get([]byte{0xfe})
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+}
+
+func (s *testMemDBSuite) TestMergeTo(c *C) {
+	newDB := func(kvs ...string) *MemDB {
+		db := newMemDB()
+		for i := 0; i < len(kvs); i += 2 {
+			c.Assert(db.Set([]byte(kvs[i]), []byte(kvs[i+1])), IsNil)
+		}
+		return db
+	}
+	checkDB := func(db *MemDB, kvs ...string) {
+		c.Assert(db.LiveLen(), Equals, len(kvs)/2)
+		for i := 0; i < len(kvs); i += 2 {
+			v, err := db.Get([]byte(kvs[i]))
+			c.Assert(err, IsNil)
+			c.Assert(string(v), Equals, kvs[i+1])
+		}
+	}
+
+	parent := newDB("a", "1", "b", "2")
+	c.Assert(newDB("b", "3", "c", "4").MergeTo(parent, ConflictLastWins), IsNil)
+	checkDB(parent, "a", "1", "b", "3", "c", "4")
+	c.Assert(parent.Dirty(), IsTrue)
+
+	parent = newDB("a", "1", "b", "2")
+	c.Assert(newDB("b", "3", "c", "4").MergeTo(parent, ConflictFirstWins), IsNil)
+	checkDB(parent, "a", "1", "b", "2", "c", "4")
+
+	// Nothing is written on conflict.
+	parent = newDB("a", "1", "b", "2")
+	err := newDB("0", "0", "b", "3").MergeTo(parent, ConflictError)
+	c.Assert(err, DeepEquals, &tikverr.ErrMergeConflict{Key: []byte("b")})
+	checkDB(parent, "a", "1", "b", "2")
+	c.Assert(parent.Len(), Equals, 2)
+
+	// The deletions are merged as tombstones, and the flags are merged too.
+	db := newDB("c", "4")
+	c.Assert(db.Delete([]byte("a")), IsNil)
+	db.UpdateFlags([]byte("b"), kv.SetPresumeKeyNotExists)
+	db.UpdateFlags([]byte("d"), kv.SetKeyLocked)
+	c.Assert(db.MergeTo(parent, ConflictError), NotNil)
+	c.Assert(db.MergeTo(parent, ConflictLastWins), IsNil)
+	checkDB(parent, "b", "2", "c", "4")
+	_, err = parent.Get([]byte("a"))
+	c.Assert(err, IsNil)
+	flags, err := parent.GetFlags([]byte("b"))
+	c.Assert(err, IsNil)
+	c.Assert(flags.HasPresumeKeyNotExists(), IsTrue)
+	flags, err = parent.GetFlags([]byte("d"))
+	c.Assert(err, IsNil)
+	c.Assert(flags.HasLocked(), IsTrue)
+
+	// The merge is written to the current staging buffer of the parent.
+	parent = newDB("a", "1")
+	h := parent.Staging()
+	c.Assert(newDB("a", "2", "b", "3").MergeTo(parent, ConflictLastWins), IsNil)
+	checkDB(parent, "a", "2", "b", "3")
+	parent.Cleanup(h)
+	checkDB(parent, "a", "1")
+	c.Assert(parent.Len(), Equals, 1)
+}
+
+func (s *testMemDBSuite) TestMergeToConcurrent(c *C) {
+	const (
+		workers = 2
+		cnt     = 10000
+	)
+	parent := newMemDB()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Each worker builds its own batch of keys, the batches don't overlap.
+			db := newMemDB()
+			var buf [4]byte
+			for i := w; i < workers*cnt; i += workers {
+				binary.BigEndian.PutUint32(buf[:], uint32(i))
+				c.Check(db.Set(buf[:], buf[:]), IsNil)
+			}
+			c.Check(db.MergeTo(parent, ConflictError), IsNil)
+		}(w)
+	}
+	wg.Wait()
+
+	c.Assert(parent.Len(), Equals, workers*cnt)
+	var buf [4]byte
+	i := 0
+	for it, _ := parent.Iter(nil, nil); it.Valid(); _ = it.Next() {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(it.Key(), BytesEquals, buf[:])
+		c.Assert(it.Value(), BytesEquals, buf[:])
+		i++
+	}
+	c.Assert(i, Equals, workers*cnt)
+}
+
+func (s *testMemDBSuite) TestMergeToEachOther(c *C) {
+	const cnt = 100000
+	db1, db2 := newMemDB(), newMemDB()
+	c.Assert(db1.Set([]byte{1}, []byte{1}), IsNil)
+	c.Assert(db2.Set([]byte{2}, []byte{2}), IsNil)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < cnt; i++ {
+			c.Check(db1.MergeTo(db2, ConflictLastWins), IsNil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < cnt; i++ {
+			c.Check(db2.MergeTo(db1, ConflictLastWins), IsNil)
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		c.Fatal("merging MemDBs to each other deadlocks")
+	}
+
+	for _, db := range []*MemDB{db1, db2} {
+		c.Assert(db.Len(), Equals, 2)
+		for _, key := range [][]byte{{1}, {2}} {
+			v, err := db.Get(key)
+			c.Assert(err, IsNil)
+			c.Assert(v, BytesEquals, key)
+		}
+	}
+}
+
+func (s *testMemDBSuite) TestFilter(c *C) {
+	const cnt = 10000
+	db := s.fillDB(cnt)
+	c.Assert(db.Delete([]byte{0, 0, 0x10, 0x00}), IsNil)
+	c.Assert(db.Delete([]byte{0, 0, 0x10, 0x01}), IsNil)
+	db.UpdateFlags([]byte{0, 0, 0x10, 0x00, 0}, kv.SetPresumeKeyNotExists)
+	db.UpdateFlags([]byte{0, 0, 0x10, 0x02}, kv.SetKeyLocked)
+
+	// Keep the even keys, including the tombstone and the flags only key.
+	var tombstones, flagsOnly int
+	f := db.Filter(func(key, value []byte) bool {
+		if value == nil {
+			flagsOnly++
+		} else if len(value) == 0 {
+			tombstones++
+		}
+		return key[len(key)-1]%2 == 0
+	})
+	c.Assert(tombstones, Equals, 2)
+	c.Assert(flagsOnly, Equals, 1)
+	c.Assert(f.Len(), Equals, cnt/2+1)
+	c.Assert(f.LiveLen(), Equals, cnt/2-1)
+	c.Assert(f.Size(), Equals, (cnt/2-1)*8+4+5)
+	s.checkRBTree(c, f)
+
+	var buf [4]byte
+	i := 0
+	for it := f.IterWithFlags(nil, nil); it.Valid(); _ = it.Next() {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(it.Key(), BytesEquals, buf[:])
+		switch i {
+		case 0x1000:
+			c.Assert(it.Value(), BytesEquals, tombstone)
+			c.Assert(it.Next(), IsNil)
+			c.Assert(it.Key(), BytesEquals, []byte{0, 0, 0x10, 0x00, 0})
+			c.Assert(it.HasValue(), IsFalse)
+			c.Assert(it.Flags().HasPresumeKeyNotExists(), IsTrue)
+		case 0x1002:
+			c.Assert(it.Value(), BytesEquals, buf[:])
+			c.Assert(it.Flags().HasLocked(), IsTrue)
+		default:
+			c.Assert(it.Value(), BytesEquals, buf[:])
+		}
+		i += 2
+	}
+	c.Assert(i, Equals, cnt)
+
+	// The filtered MemDB can be written as usual.
+	h := f.Staging()
+	for i := 1; i < cnt; i += 2 {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(f.Set(buf[:], buf[:]), IsNil)
+	}
+	s.checkRBTree(c, f)
+	c.Assert(f.LiveLen(), Equals, cnt-1)
+	f.Cleanup(h)
+	s.checkRBTree(c, f)
+	c.Assert(f.Len(), Equals, cnt/2+1)
+
+	c.Assert(db.Filter(func(key, value []byte) bool { return false }).Len(), Equals, 0)
+	c.Assert(db.Len(), Equals, cnt+1)
+}
+
+func (s *testMemDBSuite) TestDiff(c *C) {
+	before := newMemDB()
+	c.Assert(before.Set([]byte("a"), []byte("1")), IsNil)
+	c.Assert(before.Set([]byte("b"), []byte("2")), IsNil)
+	c.Assert(before.Set([]byte("c"), []byte("3")), IsNil)
+	c.Assert(before.Delete([]byte("d")), IsNil)
+	c.Assert(before.Set([]byte("g"), []byte("7")), IsNil)
+	before.UpdateFlags([]byte("e"), kv.SetKeyLocked)
+
+	after := before.Clone()
+	c.Assert(after.Set([]byte("b"), []byte("20")), IsNil)
+	c.Assert(after.Delete([]byte("c")), IsNil)
+	c.Assert(after.Set([]byte("d"), []byte("4")), IsNil)
+	c.Assert(after.Set([]byte("f"), []byte("6")), IsNil)
+	c.Assert(after.Delete([]byte("h")), IsNil)
+	after.UpdateFlags([]byte("i"), kv.SetKeyLocked)
+	// g is absent in other.
+	other := after.Filter(func(key, value []byte) bool { return !bytes.Equal(key, []byte("g")) })
+
+	added, removed, modified, err := before.Diff(other)
+	c.Assert(err, IsNil)
+	c.Assert(added, DeepEquals, []kv.Pair{{Key: []byte("d"), Value: []byte("4")}, {Key: []byte("f"), Value: []byte("6")}})
+	c.Assert(removed, DeepEquals, []kv.Pair{{Key: []byte("c"), Value: []byte("3")}, {Key: []byte("g"), Value: []byte("7")}})
+	c.Assert(modified, DeepEquals, []kv.Pair{{Key: []byte("b"), Value: []byte("20")}})
+
+	// The diff of the other direction swaps added and removed.
+	added, removed, modified, err = other.Diff(before)
+	c.Assert(err, IsNil)
+	c.Assert(added, DeepEquals, []kv.Pair{{Key: []byte("c"), Value: []byte("3")}, {Key: []byte("g"), Value: []byte("7")}})
+	c.Assert(removed, DeepEquals, []kv.Pair{{Key: []byte("d"), Value: []byte("4")}, {Key: []byte("f"), Value: []byte("6")}})
+	c.Assert(modified, DeepEquals, []kv.Pair{{Key: []byte("b"), Value: []byte("2")}})
+
+	added, removed, modified, err = before.Diff(before.Clone())
+	c.Assert(err, IsNil)
+	c.Assert(added, HasLen, 0)
+	c.Assert(removed, HasLen, 0)
+	c.Assert(modified, HasLen, 0)
+
+	const cnt = 10000
+	added, removed, modified, err = newMemDB().Diff(s.fillDB(cnt))
+	c.Assert(err, IsNil)
+	c.Assert(added, HasLen, cnt)
+	c.Assert(removed, HasLen, 0)
+	c.Assert(modified, HasLen, 0)
+}
+
+func (s *testMemDBSuite) TestDiffEachOther(c *C) {
+	const cnt = 1000
+	db1, db2 := s.fillDB(cnt), s.fillDB(cnt)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(4)
+	for _, dbs := range [][2]*MemDB{{db1, db2}, {db2, db1}} {
+		go func(db, other *MemDB) {
+			defer wg.Done()
+			for i := 0; i < cnt; i++ {
+				_, _, _, err := db.Diff(other)
+				c.Check(err, IsNil)
+			}
+		}(dbs[0], dbs[1])
+		// The writers waiting for the lock block the new readers.
+		go func(db *MemDB) {
+			defer wg.Done()
+			for i := 0; i < cnt; i++ {
+				c.Check(db.Set([]byte{1}, []byte{byte(i)}), IsNil)
+			}
+		}(dbs[0])
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Minute):
+		c.Fatal("diffing MemDBs with each other deadlocks")
+	}
+}
+
+func (s *testMemDBSuite) TestCompactArena(c *C) {
+	const cnt = 10000
+	db := newMemDB()
+	key := make([]byte, 4)
+	// The values of different lengths can't be overwritten in place, they leave holes in the arena.
+	for round := 1; round <= 4; round++ {
+		for i := 0; i < cnt; i++ {
+			binary.BigEndian.PutUint32(key, uint32(i))
+			c.Assert(db.Set(key, make([]byte, round*16)), IsNil)
+		}
+	}
+	c.Assert(db.GC(0.5), Equals, int64(0))
+
+	it, err := db.Iter(nil, nil)
+	c.Assert(err, IsNil)
+	before := db.EstimateMemoryFootprint()
+	_, err = db.CompactArena()
+	c.Assert(err, Equals, tikverr.ErrAliveIterators)
+	c.Assert(db.EstimateMemoryFootprint(), Equals, before)
+	it.Close()
+	// Closing an iterator again is harmless.
+	it.Close()
+	h := db.Staging()
+	reclaimed, err := db.CompactArena()
+	c.Assert(err, IsNil)
+	c.Assert(reclaimed, Equals, int64(0))
+	db.Cleanup(h)
+
+	size := db.Size()
+	reclaimed, err = db.CompactArena()
+	c.Assert(err, IsNil)
+	c.Assert(reclaimed, Equals, before-db.EstimateMemoryFootprint())
+	c.Assert(reclaimed*3 > before, IsTrue, Commentf("before %d, reclaimed %d", before, reclaimed))
+	c.Assert(db.Len(), Equals, cnt)
+	c.Assert(db.Size(), Equals, size)
+	s.checkRBTree(c, db)
+	for i := 0; i < cnt; i++ {
+		binary.BigEndian.PutUint32(key, uint32(i))
+		v, err := db.Get(key)
+		c.Assert(err, IsNil)
+		c.Assert(v, HasLen, 64)
+	}
+
+	// The compacted MemDB can be written as usual.
+	h = db.Staging()
+	c.Assert(db.Set([]byte("k"), []byte("v")), IsNil)
+	db.Cleanup(h)
+	c.Assert(db.Len(), Equals, cnt)
+}
+
+func (s *testMemDBSuite) TestArenaAlignment(c *C) {
+	const cnt = 1000
+	value := func(i int, v byte) []byte {
+		return bytes.Repeat([]byte{v}, 1+i%70)
+	}
+	check := func(db *MemDB, v byte) {
+		c.Assert(db.Len(), Equals, cnt)
+		i := 0
+		it, _ := db.Iter(nil, nil)
+		for ; it.Valid(); _ = it.Next() {
+			c.Assert(it.Key(), BytesEquals, []byte(fmt.Sprintf("k%04d", i)))
+			c.Assert(it.Value(), BytesEquals, value(i, v))
+			c.Assert(uintptr(unsafe.Pointer(&it.Value()[0]))%32, Equals, uintptr(0))
+			i++
+		}
+		it.Close()
+		c.Assert(i, Equals, cnt)
+	}
+
+	db := newMemDBWithOptions(ArenaOptions{Alignment: 32})
+	for i := 0; i < cnt; i++ {
+		c.Assert(db.Set([]byte(fmt.Sprintf("k%04d", i)), value(i, 'a')), IsNil)
+	}
+	check(db, 'a')
+	c.Assert(len(db.vlog.blocks), Greater, 1)
+
+	// The padded entries are walked back by Cleanup.
+	h := db.Staging()
+	for i := 0; i < cnt; i++ {
+		c.Assert(db.Set([]byte(fmt.Sprintf("k%04d", i)), value(i, 'b')), IsNil)
+	}
+	check(db, 'b')
+	var inspected int
+	db.InspectStage(h, func(key []byte, _ kv.KeyFlags, val []byte) {
+		c.Assert(val[0], Equals, byte('b'))
+		inspected++
+	})
+	c.Assert(inspected, Equals, cnt)
+	db.Cleanup(h)
+	check(db, 'a')
+	s.checkRBTree(c, db)
+
+	// The copies are aligned as well.
+	check(db.Clone(), 'a')
+	check(db.Filter(func(_, _ []byte) bool { return true }), 'a')
+
+	// The alignment is kept after the arenas are rebuilt.
+	for i := 0; i < cnt; i++ {
+		c.Assert(db.Set([]byte(fmt.Sprintf("k%04d", i)), value(i, 'c')), IsNil)
+	}
+	_, err := db.CompactArena()
+	c.Assert(err, IsNil)
+	check(db, 'c')
+	for i := 0; i < cnt; i++ {
+		c.Assert(db.Set([]byte(fmt.Sprintf("k%04d", i)), value(i, 'd')), IsNil)
+	}
+	check(db, 'd')
+
+	c.Assert(func() { newMemDBWithOptions(ArenaOptions{Alignment: 24}) }, PanicMatches, "invalid alignment 24")
+}
+
+func (s *testMemDBSuite) TestCheckpoint(c *C) {
+	db := newMemDB()
+	c.Assert(db.Set([]byte("k1"), []byte("v1")), IsNil)
+	cp1 := db.Checkpoint()
+	c.Assert(db.Set([]byte("k1"), []byte("v2")), IsNil)
+	c.Assert(db.Set([]byte("k2"), []byte("v2")), IsNil)
+	cp2 := db.Checkpoint()
+	c.Assert(db.Set([]byte("k2"), []byte("v3")), IsNil)
+	c.Assert(db.Delete([]byte("k1")), IsNil)
+	c.Assert(db.Set([]byte("k3"), []byte("v3")), IsNil)
+
+	checkGet := func(key, expect string) {
+		val, err := db.Get([]byte(key))
+		if expect == "" {
+			c.Assert(tikverr.IsErrNotFound(err), IsTrue, Commentf("%s", key))
+			return
+		}
+		c.Assert(err, IsNil)
+		c.Assert(string(val), Equals, expect, Commentf("%s", key))
+	}
+	c.Assert(db.RollbackTo(cp2), IsNil)
+	checkGet("k1", "v2")
+	checkGet("k2", "v2")
+	checkGet("k3", "")
+	c.Assert(db.Len(), Equals, 2)
+	c.Assert(db.Size(), Equals, 8)
+	s.checkRBTree(c, db)
+
+	// The checkpoint is kept after the rollback.
+	c.Assert(db.Set([]byte("k2"), []byte("v4")), IsNil)
+	c.Assert(db.RollbackTo(cp2), IsNil)
+	checkGet("k2", "v2")
+
+	c.Assert(db.RollbackTo(cp1), IsNil)
+	checkGet("k1", "v1")
+	checkGet("k2", "")
+	c.Assert(db.Size(), Equals, 4)
+	// The checkpoints after the one rolled back to are invalid.
+	c.Assert(db.RollbackTo(cp2), Equals, tikverr.ErrInvalidCheckpoint)
+
+	// The checkpoint before the current staging buffer can't be rolled back to.
+	c.Assert(db.Set([]byte("k0"), []byte("v0")), IsNil)
+	h := db.Staging()
+	c.Assert(db.Set([]byte("k4"), []byte("v4")), IsNil)
+	c.Assert(db.RollbackTo(cp1), Equals, tikverr.ErrInvalidCheckpoint)
+	cp3 := db.Checkpoint()
+	c.Assert(db.Set([]byte("k5"), []byte("v5")), IsNil)
+	c.Assert(db.RollbackTo(cp3), IsNil)
+	checkGet("k4", "v4")
+	checkGet("k5", "")
+	// The checkpoints in the staging buffer are invalid after Cleanup.
+	db.Cleanup(h)
+	checkGet("k4", "")
+	c.Assert(db.RollbackTo(cp3), Equals, tikverr.ErrInvalidCheckpoint)
+	checkGet("k0", "v0")
+	c.Assert(db.RollbackTo(cp1), IsNil)
+	checkGet("k0", "")
+	checkGet("k1", "v1")
+
+	db.Reset()
+	c.Assert(db.RollbackTo(cp1), Equals, tikverr.ErrInvalidCheckpoint)
+}
+
+func (s *testMemDBSuite) TestNamedCheckpoint(c *C) {
+	db := newMemDB()
+	checkGet := func(key, expect string) {
+		val, err := db.Get([]byte(key))
+		if expect == "" {
+			c.Assert(tikverr.IsErrNotFound(err), IsTrue, Commentf("%s", key))
+			return
+		}
+		c.Assert(err, IsNil)
+		c.Assert(string(val), Equals, expect, Commentf("%s", key))
+	}
+	c.Assert(db.RollbackToNamed("sp1"), Equals, tikverr.ErrInvalidCheckpoint)
+
+	c.Assert(db.Set([]byte("k1"), []byte("v1")), IsNil)
+	db.NamedCheckpoint("sp1")
+	c.Assert(db.Set([]byte("k2"), []byte("v2")), IsNil)
+	db.NamedCheckpoint("sp2")
+	c.Assert(db.Set([]byte("k3"), []byte("v3")), IsNil)
+	db.NamedCheckpoint("sp3")
+	c.Assert(db.Set([]byte("k4"), []byte("v4")), IsNil)
+
+	c.Assert(db.RollbackToNamed("sp2"), IsNil)
+	checkGet("k2", "v2")
+	checkGet("k3", "")
+	// The name is kept, but the names after it are removed.
+	c.Assert(db.Set([]byte("k3"), []byte("v3")), IsNil)
+	c.Assert(db.RollbackToNamed("sp2"), IsNil)
+	checkGet("k3", "")
+	c.Assert(db.RollbackToNamed("sp3"), Equals, tikverr.ErrInvalidCheckpoint)
+
+	// The checkpoint of the same name is replaced.
+	c.Assert(db.Set([]byte("k5"), []byte("v5")), IsNil)
+	db.NamedCheckpoint("sp1")
+	c.Assert(db.Set([]byte("k6"), []byte("v6")), IsNil)
+	c.Assert(db.RollbackToNamed("sp1"), IsNil)
+	checkGet("k5", "v5")
+	checkGet("k6", "")
+	// The names are kept across the staging buffers, but the checkpoints discarded by Cleanup are invalid.
+	h := db.Staging()
+	c.Assert(db.Set([]byte("k7"), []byte("v7")), IsNil)
+	db.NamedCheckpoint("sp4")
+	c.Assert(db.RollbackToNamed("sp2"), Equals, tikverr.ErrInvalidCheckpoint)
+	db.Cleanup(h)
+	c.Assert(db.RollbackToNamed("sp4"), Equals, tikverr.ErrInvalidCheckpoint)
+	c.Assert(db.RollbackToNamed("sp2"), IsNil)
+	checkGet("k1", "v1")
+	checkGet("k2", "v2")
+	checkGet("k5", "")
+
+	db.Reset()
+	c.Assert(db.RollbackToNamed("sp2"), Equals, tikverr.ErrInvalidCheckpoint)
+}
+
+func (s *testMemDBSuite) TestSizeOf(c *C) {
+	db := newMemDB()
+	c.Assert(db.Set([]byte("k1"), make([]byte, 4096)), IsNil)
+	c.Assert(db.Delete([]byte("k2")), IsNil)
+	db.UpdateFlags([]byte("k3"), kv.SetKeyLocked)
+
+	n, err := db.SizeOf([]byte("k1"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 4096)
+	n, err = db.SizeOf([]byte("k2"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, -1)
+	for _, key := range []string{"k0", "k3"} {
+		_, err = db.SizeOf([]byte(key))
+		c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+	}
+
+	// The size of the latest value is returned.
+	h := db.Staging()
+	c.Assert(db.Set([]byte("k1"), []byte("v")), IsNil)
+	n, err = db.SizeOf([]byte("k1"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+	db.Cleanup(h)
+	n, err = db.SizeOf([]byte("k1"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 4096)
+}
+
+func (s *testMemDBSuite) TestWatch(c *C) {
+	db := newMemDB()
+	key := []byte("k")
+	isClosed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	const cnt = 100
+	var wg sync.WaitGroup
+	var ready sync.WaitGroup
+	var notified int32
+	wg.Add(cnt)
+	ready.Add(cnt)
+	for i := 0; i < cnt; i++ {
+		go func() {
+			defer wg.Done()
+			ch, cancel := db.Watch(key)
+			defer cancel()
+			ready.Done()
+			select {
+			case <-ch:
+				atomic.AddInt32(&notified, 1)
+			case <-time.After(10 * time.Second):
+			}
+		}()
+	}
+	ready.Wait()
+	c.Assert(db.Set(key, []byte("v")), IsNil)
+	wg.Wait()
+	c.Assert(notified, Equals, int32(cnt))
+	c.Assert(db.watchedKeys, Equals, int32(0))
+
+	// The channel is closed at most once, the changes of other keys and the flags are not notified.
+	ch, cancel := db.Watch(key)
+	canceled, cancelCanceled := db.Watch(key)
+	cancelCanceled()
+	c.Assert(db.Set([]byte("k2"), []byte("v")), IsNil)
+	db.UpdateFlags(key, kv.SetKeyLocked)
+	c.Assert(isClosed(ch), IsFalse)
+	c.Assert(db.Delete(key), IsNil)
+	c.Assert(isClosed(ch), IsTrue)
+	c.Assert(isClosed(canceled), IsFalse)
+	cancel()
+	cancelCanceled()
+
+	// Rolling back the value by Cleanup is a change as well.
+	ch, cancel = db.Watch(key)
+	defer cancel()
+	h := db.Staging()
+	c.Assert(db.Set(key, []byte("v1")), IsNil)
+	c.Assert(isClosed(ch), IsTrue)
+	ch, _ = db.Watch(key)
+	db.Cleanup(h)
+	c.Assert(isClosed(ch), IsTrue)
+	val, err := db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, tombstone)
+
+	ch1, _ := db.Watch(key)
+	ch2, _ := db.Watch([]byte("k2"))
+	db.Reset()
+	c.Assert(isClosed(ch1), IsTrue)
+	c.Assert(isClosed(ch2), IsTrue)
+	c.Assert(db.watchedKeys, Equals, int32(0))
+}
+
+// checkRBTree checks the red-black tree properties, the parent pointers and the live counts of db if they're maintained.
+func (s *testMemDBSuite) checkRBTree(c *C, db *MemDB) {
+	var check func(x memdbNodeAddr) int
+	check = func(x memdbNodeAddr) int {
+		if x.isNull() {
+			return 1
+		}
+		left, right := x.getLeft(db), x.getRight(db)
+		for _, child := range []memdbNodeAddr{left, right} {
+			if !child.isNull() {
+				c.Assert(child.up, Equals, x.addr)
+				c.Assert(x.isRed() && child.isRed(), IsFalse)
+			}
+		}
+		bh := check(left)
+		c.Assert(check(right), Equals, bh)
+		if x.isBlack() {
+			bh++
+		}
+		if !db.vlogInvalid && db.liveCntEnabled == 1 {
+			liveCnt := left.liveCnt + right.liveCnt
+			if db.isLiveNode(x) {
+				liveCnt++
+			}
+			c.Assert(x.liveCnt, Equals, liveCnt)
+		}
+		return bh
+	}
+	root := db.getRoot()
+	c.Assert(root.isRed(), IsFalse)
+	check(root)
+	if !db.vlogInvalid && db.liveCntEnabled == 1 {
+		c.Assert(int(root.liveCnt), Equals, db.LiveLen())
+	}
+}
+
+func (s *testMemDBSuite) TestLiveLen(c *C) {
+	const (
+		keyCnt = 500
+		opCnt  = 50000
+	)
+	db := newMemDB()
+	ref := make(map[int]bool)
+	var stages []map[int]bool
+	var handles []int
+	liveLen := func() int {
+		cnt := 0
+		for _, live := range ref {
+			if live {
+				cnt++
+			}
+		}
+		return cnt
+	}
+
+	var buf [4]byte
+	for i := 0; i < opCnt; i++ {
+		k := rand.Intn(keyCnt)
+		binary.BigEndian.PutUint32(buf[:], uint32(k))
+		switch op := rand.Intn(100); {
+		case op < 45:
+			// Insert or overwrite, the length of value varies to avoid always modifying in place.
+			c.Assert(db.Set(buf[:], make([]byte, 1+rand.Intn(3))), IsNil)
+			ref[k] = true
+		case op < 75:
+			c.Assert(db.Delete(buf[:]), IsNil)
+			ref[k] = false
+		case op < 85:
+			db.UpdateFlags(buf[:], kv.SetPresumeKeyNotExists)
+		case op < 92 || len(stages) == 0:
+			snapshot := make(map[int]bool, len(ref))
+			for k, v := range ref {
+				snapshot[k] = v
+			}
+			stages = append(stages, snapshot)
+			handles = append(handles, db.Staging())
+		case op < 96:
+			db.Release(handles[len(handles)-1])
+			stages, handles = stages[:len(stages)-1], handles[:len(handles)-1]
+		default:
+			db.Cleanup(handles[len(handles)-1])
+			ref = stages[len(stages)-1]
+			stages, handles = stages[:len(stages)-1], handles[:len(handles)-1]
+		}
+		c.Assert(db.LiveLen(), Equals, liveLen())
+	}
+	for len(handles) > 0 {
+		db.Cleanup(handles[len(handles)-1])
+		ref = stages[len(stages)-1]
+		stages, handles = stages[:len(stages)-1], handles[:len(handles)-1]
+		c.Assert(db.LiveLen(), Equals, liveLen())
+	}
+
+	cnt := 0
+	for it, _ := db.Iter(nil, nil); it.Valid(); _ = it.Next() {
+		if len(it.Value()) > 0 {
+			cnt++
+		}
+	}
+	c.Assert(db.LiveLen(), Equals, cnt)
+	db.Reset()
+	c.Assert(db.LiveLen(), Equals, 0)
+}
+
+func (s *testMemDBSuite) TestRangeCount(c *C) {
+	const (
+		keyCnt = 300
+		opCnt  = 20000
+	)
+	db := newMemDB()
+	ref := make(map[int]bool)
+	var stages []map[int]bool
+	var handles []int
+	encode := func(k int) []byte {
+		var buf [4]byte
+		binary.BigEndian.PutUint32(buf[:], uint32(k))
+		return buf[:]
+	}
+	// rangeCount is the reference, it counts the live keys in [start, end) by a linear scan.
+	rangeCount := func(start, end int) int {
+		cnt := 0
+		for k := start; k < end; k++ {
+			if ref[k] {
+				cnt++
+			}
+		}
+		return cnt
+	}
+	checkRanges := func() {
+		for j := 0; j < 5; j++ {
+			start, end := rand.Intn(keyCnt+1), rand.Intn(keyCnt+1)
+			expected := 0
+			if start < end {
+				expected = rangeCount(start, end)
+			}
+			c.Assert(db.RangeCount(encode(start), encode(end)), Equals, expected)
+		}
+		k := rand.Intn(keyCnt + 1)
+		c.Assert(db.RangeCount(nil, encode(k)), Equals, rangeCount(0, k))
+		c.Assert(db.RangeCount(encode(k), nil), Equals, rangeCount(k, keyCnt))
+		c.Assert(db.RangeCount(nil, nil), Equals, rangeCount(0, keyCnt))
+	}
+
+	// The live counts are not maintained until the first RangeCount builds them.
+	for k := 0; k < keyCnt; k += 3 {
+		c.Assert(db.Set(encode(k), []byte{1}), IsNil)
+		ref[k] = true
+	}
+	c.Assert(db.Delete(encode(0)), IsNil)
+	ref[0] = false
+	c.Assert(db.liveCntEnabled, Equals, int32(0))
+	c.Assert(db.getRoot().liveCnt, Equals, uint32(0))
+	checkRanges()
+	c.Assert(db.liveCntEnabled, Equals, int32(1))
+	s.checkRBTree(c, db)
+
+	for i := 0; i < opCnt; i++ {
+		k := rand.Intn(keyCnt)
+		switch op := rand.Intn(100); {
+		case op < 45:
+			// Insert or overwrite, the length of value varies to avoid always modifying in place.
+			c.Assert(db.Set(encode(k), make([]byte, 1+rand.Intn(3))), IsNil)
+			ref[k] = true
+		case op < 75:
+			c.Assert(db.Delete(encode(k)), IsNil)
+			ref[k] = false
+		case op < 85:
+			db.UpdateFlags(encode(k), kv.SetPresumeKeyNotExists)
+		case op < 92 || len(stages) == 0:
+			snapshot := make(map[int]bool, len(ref))
+			for k, v := range ref {
+				snapshot[k] = v
+			}
+			stages = append(stages, snapshot)
+			handles = append(handles, db.Staging())
+		case op < 96:
+			db.Release(handles[len(handles)-1])
+			stages, handles = stages[:len(stages)-1], handles[:len(handles)-1]
+		default:
+			db.Cleanup(handles[len(handles)-1])
+			ref = stages[len(stages)-1]
+			stages, handles = stages[:len(stages)-1], handles[:len(handles)-1]
+		}
+		checkRanges()
+	}
+	s.checkRBTree(c, db)
+
+	// Check the whole range against the iterator too, the tombstones are iterated with empty values.
+	it, err := db.Iter(nil, nil)
+	c.Assert(err, IsNil)
+	cnt := 0
+	for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+		if len(it.Value()) > 0 {
+			cnt++
+		}
+	}
+	it.Close()
+	c.Assert(db.RangeCount(nil, nil), Equals, cnt)
+
+	for len(handles) > 0 {
+		db.Cleanup(handles[len(handles)-1])
+		ref = stages[len(stages)-1]
+		stages, handles = stages[:len(stages)-1], handles[:len(handles)-1]
+		checkRanges()
+	}
+	s.checkRBTree(c, db)
+
+	// The live counts are copied by the compaction and Clone, and built for the new MemDB of Filter.
+	_, err = db.CompactArena()
+	c.Assert(err, IsNil)
+	s.checkRBTree(c, db)
+	checkRanges()
+	s.checkRBTree(c, db.Clone())
+	f := db.Filter(func(key, value []byte) bool {
+		return binary.BigEndian.Uint32(key)%2 == 0
+	})
+	c.Assert(f.RangeCount(nil, nil), Equals, f.LiveLen())
+	s.checkRBTree(c, f)
+
+	db.Reset()
+	c.Assert(db.liveCntEnabled, Equals, int32(0))
+	c.Assert(db.RangeCount(nil, nil), Equals, 0)
+}
+
+func (s *testMemDBSuite) TestKeyAt(c *C) {
+	const cnt = 10000
+	db := newMemDB()
+	var buf [4]byte
+	for _, i := range rand.Perm(cnt) {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(db.Set(buf[:], buf[:]), IsNil)
+	}
+	// The tombstones and the flags only keys are skipped.
+	for i := 0; i < cnt; i += 3 {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(db.Delete(buf[:]), IsNil)
+	}
+	for i := cnt; i < cnt+100; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		db.UpdateFlags(buf[:], kv.SetPresumeKeyNotExists)
+	}
+
+	check := func() {
+		it, err := db.Iter(nil, nil)
+		c.Assert(err, IsNil)
+		i := 0
+		for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+			if len(it.Value()) == 0 {
+				continue
+			}
+			key, err := db.KeyAt(i)
+			c.Assert(err, IsNil)
+			c.Assert(key, BytesEquals, it.Key())
+			i++
+		}
+		it.Close()
+		c.Assert(i, Equals, db.LiveLen())
+		_, err = db.KeyAt(i)
+		c.Assert(err, NotNil)
+		_, err = db.KeyAt(-1)
+		c.Assert(err, NotNil)
+	}
+	check()
+
+	h := db.Staging()
+	for i := 0; i < cnt; i += 2 {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(db.Set(buf[:], buf[:]), IsNil)
+	}
+	check()
+	db.Cleanup(h)
+	check()
+
+	db.Reset()
+	_, err := db.KeyAt(0)
+	c.Assert(err, NotNil)
+}
+
+func (s *testMemDBSuite) TestClone(c *C) {
+	const (
+		workers = 4
+		cnt     = 1000
+	)
+	base := s.fillDB(cnt)
+	h := base.Staging()
+	c.Assert(base.Set([]byte("staged"), []byte("staged")), IsNil)
+
+	clones := make([]*MemDB, workers)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			db := base.Clone()
+			db.Cleanup(h)
+			for j := 0; j < cnt; j++ {
+				var key [8]byte
+				binary.BigEndian.PutUint64(key[:], uint64((i+1)*cnt+j))
+				if err := db.Set(key[:], key[:]); err != nil {
+					panic(err)
+				}
+			}
+			// Overwrite a key of the base, only this clone should see it.
+			var key [4]byte
+			binary.BigEndian.PutUint32(key[:], uint32(i))
+			if err := db.Set(key[:], []byte{byte(i)}); err != nil {
+				panic(err)
+			}
+			clones[i] = db
+		}(i)
+	}
+	wg.Wait()
+
+	c.Assert(base.Len(), Equals, cnt+1)
+	val, err := base.Get([]byte("staged"))
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("staged"))
+	for i := uint32(0); i < workers; i++ {
+		var key [4]byte
+		binary.BigEndian.PutUint32(key[:], i)
+		val, err := base.Get(key[:])
+		c.Assert(err, IsNil)
+		c.Assert(val, BytesEquals, key[:])
+	}
+
+	for i, db := range clones {
+		c.Assert(db.Len(), Equals, 2*cnt)
+		_, err := db.Get([]byte("staged"))
+		c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+		for w := 0; w < workers; w++ {
+			var key [4]byte
+			binary.BigEndian.PutUint32(key[:], uint32(w))
+			val, err := db.Get(key[:])
+			c.Assert(err, IsNil)
+			if w == i {
+				c.Assert(val, BytesEquals, []byte{byte(i)})
+			} else {
+				c.Assert(val, BytesEquals, key[:])
+			}
+
+			var ownKey [8]byte
+			binary.BigEndian.PutUint64(ownKey[:], uint64((w+1)*cnt))
+			_, err = db.Get(ownKey[:])
+			c.Assert(err == nil, Equals, w == i)
+		}
+
+		it, err := db.Iter(nil, nil)
+		c.Assert(err, IsNil)
+		n := 0
+		for ; it.Valid(); it.Next() {
+			n++
+		}
+		c.Assert(n, Equals, 2*cnt)
+
+		// The values not overwritten are shared with the base rather than copied.
+		var key [4]byte
+		binary.BigEndian.PutUint32(key[:], uint32(workers))
+		val, err := db.Get(key[:])
+		c.Assert(err, IsNil)
+		baseVal, err := base.Get(key[:])
+		c.Assert(err, IsNil)
+		c.Assert(&val[0] == &baseVal[0], IsTrue)
+	}
+
+	// The writes of the base after the clones don't go to the shared blocks.
+	base.Cleanup(h)
+	for i := 0; i < cnt; i++ {
+		var key [4]byte
+		binary.BigEndian.PutUint32(key[:], uint32(i))
+		c.Assert(base.Set(key[:], []byte("base")), IsNil)
+	}
+	for _, db := range clones {
+		var key [4]byte
+		binary.BigEndian.PutUint32(key[:], uint32(workers))
+		val, err := db.Get(key[:])
+		c.Assert(err, IsNil)
+		c.Assert(val, BytesEquals, key[:])
+	}
+}
+
+func (s *testMemDBSuite) TestCloneCompact(c *C) {
+	const cnt = 100
+	db := s.fillDB(cnt)
+	cp := db.Checkpoint()
+	var buf [4]byte
+	for i := 0; i < cnt/2; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(db.Set(buf[:], []byte("overwritten")), IsNil)
+	}
+	h := s.deriveAndFill(cnt, 200*cnt, 0, db)
+	clone := db.Clone()
+
+	// The compaction of db relocates the nodes in the headers of the value log, which mustn't affect the clone.
+	db.Cleanup(h)
+	c.Assert(db.allocator.freedSize, Equals, 0)
+	clone.Cleanup(h)
+	c.Assert(clone.allocator.freedSize, Equals, 0)
+
+	for _, db := range []*MemDB{db, clone} {
+		c.Assert(db.RollbackTo(cp), IsNil)
+		c.Assert(db.Len(), Equals, cnt)
+		for i := 0; i < cnt; i++ {
+			binary.BigEndian.PutUint32(buf[:], uint32(i))
+			val, err := db.Get(buf[:])
+			c.Assert(err, IsNil)
+			c.Assert(val, BytesEquals, buf[:])
+		}
+	}
+}
+
+func (s *testMemDBSuite) TestConcurrentCAS(c *C) {
+	const (
+		workers = 8
+		loops   = 1000
+	)
+	db := newMemDB()
+	key := []byte("counter")
+	var buf [8]byte
+	ok, err := db.CAS(key, nil, buf[:])
+	c.Assert(err, IsNil)
+	c.Assert(ok, IsTrue)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loops; {
+				db.RLock()
+				old, err := db.Get(key)
+				old = append([]byte(nil), old...)
+				db.RUnlock()
+				if err != nil {
+					panic(err)
+				}
+				var next [8]byte
+				binary.BigEndian.PutUint64(next[:], binary.BigEndian.Uint64(old)+1)
+				ok, err := db.CAS(key, old, next[:])
+				if err != nil {
+					panic(err)
+				}
+				if ok {
+					j++
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	val, err := db.Get(key)
+	c.Assert(err, IsNil)
+	c.Assert(binary.BigEndian.Uint64(val), Equals, uint64(workers*loops))
+}
+
+func (s *testMemDBSuite) TestSnapshotMarshal(c *C) {
+	const cnt = 10000
+	db := s.fillDB(cnt)
+	var buf [4]byte
+	for i := 0; i < cnt; i += 3 {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		c.Assert(db.Delete(buf[:]), IsNil)
+	}
+	// The changes in staging buffer are not included in the snapshot.
+	h := s.deriveAndFill(0, 2*cnt, 1, db)
+	snap, err := db.Snapshot()
+	c.Assert(err, IsNil)
+	data, err := snap.Marshal()
+	c.Assert(err, IsNil)
+	db.Cleanup(h)
+
+	restored := newMemDB()
+	c.Assert(restored.RestoreSnapshot(data), IsNil)
+	c.Assert(restored.Len(), Equals, db.Len())
+	c.Assert(restored.Size(), Equals, db.Size())
+	for i := 0; i < 2*cnt; i++ {
+		binary.BigEndian.PutUint32(buf[:], uint32(i))
+		v1, err1 := db.Get(buf[:])
+		v2, err2 := restored.Get(buf[:])
+		c.Assert(err2, DeepEquals, err1)
+		c.Assert(v2, BytesEquals, v1)
+	}
+
+	c.Assert(newMemDB().RestoreSnapshot(data[:len(data)-1]), NotNil)
+	db.DiscardValues()
+	_, err = db.Snapshot()
+	c.Assert(err, NotNil)
+}
+
+func (s *testMemDBSuite) TestSnapshotMarshalLaterWrites(c *C) {
+	db := newMemDB()
+	c.Assert(db.Set([]byte("a"), []byte("1")), IsNil)
+	c.Assert(db.Set([]byte("b"), []byte("1")), IsNil)
+	snap, err := db.Snapshot()
+	c.Assert(err, IsNil)
+
+	// The writes after the snapshot are not included, even if they're not in a staging buffer.
+	c.Assert(db.Set([]byte("a"), []byte("2")), IsNil)
+	c.Assert(db.Delete([]byte("b")), IsNil)
+	c.Assert(db.Set([]byte("c"), []byte("1")), IsNil)
+	data, err := snap.Marshal()
+	c.Assert(err, IsNil)
+
+	restored := newMemDB()
+	c.Assert(restored.RestoreSnapshot(data), IsNil)
+	c.Assert(restored.Len(), Equals, 2)
+	for _, key := range []string{"a", "b"} {
+		val, err := restored.Get([]byte(key))
+		c.Assert(err, IsNil)
+		c.Assert(val, BytesEquals, []byte("1"))
+	}
+	_, err = restored.Get([]byte("c"))
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+
+	val, err := db.Get([]byte("a"))
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("2"))
+}
+
+func (s *testMemDBSuite) checkConsist(c *C, p1 *MemDB, p2 *leveldb.DB) {
+	c.Assert(p1.Len(), Equals, p2.Len())
+	c.Assert(p1.Size(), Equals, p2.Size())
+
+	it1, _ := p1.Iter(nil, nil)
+	it2 := p2.NewIterator(nil)
+
+	var prevKey, prevVal []byte
+	for it2.First(); it2.Valid(); it2.Next() {
+		v, err := p1.Get(it2.Key())
+		c.Assert(err, IsNil)
+		c.Assert(v, BytesEquals, it2.Value())
+
+		c.Assert(it1.Key(), BytesEquals, it2.Key())
+		c.Assert(it1.Value(), BytesEquals, it2.Value())
+
+		it, _ := p1.Iter(it2.Key(), nil)
+		c.Assert(it.Key(), BytesEquals, it2.Key())
+		c.Assert(it.Value(), BytesEquals, it2.Value())
+
+		if prevKey != nil {
+			it, _ = p1.IterReverse(it2.Key())
+			c.Assert(it.Key(), BytesEquals, prevKey)
+			c.Assert(it.Value(), BytesEquals, prevVal)
+		}
+
+		_ = it1.Next()
+		prevKey = it2.Key()
+		prevVal = it2.Value()
+	}
+
+	it1, _ = p1.IterReverse(nil)
+	for it2.Last(); it2.Valid(); it2.Prev() {
+		c.Assert(it1.Key(), BytesEquals, it2.Key())
+		c.Assert(it1.Value(), BytesEquals, it2.Value())
+		_ = it1.Next()
+	}
+}
+
+func (s *testMemDBSuite) fillDB(cnt int) *MemDB {
+	db := newMemDB()
+	h := s.deriveAndFill(0, cnt, 0, db)
+	db.Release(h)
+	return db
+}
+
+func (s *testMemDBSuite) deriveAndFill(start, end, valueBase int, db *MemDB) int {
+	h := db.Staging()
+	var kbuf, vbuf [4]byte
+	for i := start; i < end; i++ {
+		binary.BigEndian.PutUint32(kbuf[:], uint32(i))
+		binary.BigEndian.PutUint32(vbuf[:], uint32(i+valueBase))
+		db.Set(kbuf[:], vbuf[:])
+	}
+	return h
+}
+
+const (
+	startIndex = 0
+	testCount  = 2
+	indexStep  = 2
+)
+
+type testKVSuite struct {
+	bs []*MemDB
+}
+
+func (s *testKVSuite) SetUpSuite(c *C) {
+	s.bs = make([]*MemDB, 1)
+	s.bs[0] = newMemDB()
+}
+
+func (s *testKVSuite) ResetMembuffers() {
+	s.bs[0] = newMemDB()
+}
 
 func insertData(c *C, buffer *MemDB) {
 	for i := startIndex; i < testCount; i++ {
@@ -824,3 +2553,288 @@ func (s *testKVSuite) TestBufferLimit(c *C) {
 	err = buffer.Delete(make([]byte, 500))
 	c.Assert(err, NotNil)
 }
+
+// memdbWriter is the write methods of MemDB, which must not be reachable from a ReadonlyMemDB.
+type memdbWriter interface {
+	Set(key []byte, value []byte) error
+	SetWithFlags(key []byte, value []byte, ops ...kv.FlagsOp) error
+	Delete(key []byte) error
+	DeleteWithFlags(key []byte, ops ...kv.FlagsOp) error
+	UpdateFlags(key []byte, ops ...kv.FlagsOp)
+}
+
+var _ memdbWriter = (*MemDB)(nil)
+
+func (s *testMemDBSuite) TestReadonlyView(c *C) {
+	db := newMemDB()
+	view := db.ReadonlyView()
+	_, ok := view.(memdbWriter)
+	c.Assert(ok, IsFalse)
+
+	c.Assert(db.SetWithFlags([]byte("k1"), []byte("v1"), kv.SetPresumeKeyNotExists), IsNil)
+	c.Assert(db.Delete([]byte("k2")), IsNil)
+	db.UpdateFlags([]byte("k3"), kv.SetKeyLocked)
+
+	// The view reads the current state of the MemDB.
+	c.Assert(view.Len(), Equals, 3)
+	val, err := view.Get([]byte("k1"))
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("v1"))
+	val, flags, err := view.GetWithFlags([]byte("k1"))
+	c.Assert(err, IsNil)
+	c.Assert(val, BytesEquals, []byte("v1"))
+	c.Assert(flags.HasPresumeKeyNotExists(), IsTrue)
+	_, flags, err = view.GetWithFlags([]byte("k3"))
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+	c.Assert(flags.HasLocked(), IsTrue)
+	_, _, err = view.GetWithFlags([]byte("k4"))
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+	n, err := view.SizeOf([]byte("k2"))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, -1)
+
+	c.Assert(db.Set([]byte("k0"), []byte("v0")), IsNil)
+	c.Assert(view.Len(), Equals, 4)
+	var keys []string
+	it, err := view.Iter(nil, nil)
+	c.Assert(err, IsNil)
+	for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	c.Assert(keys, DeepEquals, []string{"k0", "k1", "k2"})
+	keys = keys[:0]
+	it, err = view.IterReverse(nil)
+	c.Assert(err, IsNil)
+	for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	c.Assert(keys, DeepEquals, []string{"k2", "k1", "k0"})
+}
+
+func (s *testMemDBSuite) TestExportImport(c *C) {
+	db := newMemDB()
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		if i%10 == 0 {
+			c.Assert(db.Delete(key), IsNil)
+		} else {
+			c.Assert(db.Set(key, bytes.Repeat([]byte{byte(i)}, i)), IsNil)
+		}
+	}
+	db.UpdateFlags([]byte("flags"), kv.SetKeyLocked)
+
+	for _, format := range []ExportFormat{BinaryFormat, CSVFormat} {
+		var buf bytes.Buffer
+		c.Assert(db.Export(&buf, format), IsNil)
+		data := buf.Bytes()
+		db1 := newMemDB()
+		c.Assert(db1.Import(bytes.NewReader(data), format), IsNil)
+		// The flags only key is not exported.
+		c.Assert(db1.Len(), Equals, 100)
+		c.Assert(db1.LiveLen(), Equals, 90)
+		it, err := db.Iter(nil, nil)
+		c.Assert(err, IsNil)
+		it1, err := db1.Iter(nil, nil)
+		c.Assert(err, IsNil)
+		for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+			c.Assert(it1.Valid(), IsTrue)
+			c.Assert(it1.Key(), BytesEquals, it.Key())
+			c.Assert(it1.Value(), BytesEquals, it.Value())
+			c.Assert(it1.Next(), IsNil)
+		}
+		c.Assert(it1.Valid(), IsFalse)
+		it.Close()
+		it1.Close()
+
+		// The truncated content is reported.
+		if format == BinaryFormat {
+			c.Assert(newMemDB().Import(bytes.NewReader(data[:len(data)-1]), format), NotNil)
+		} else {
+			c.Assert(newMemDB().Import(bytes.NewReader([]byte("6b6579,7\n")), format), NotNil)
+		}
+	}
+
+	var buf bytes.Buffer
+	c.Assert(db.Export(&buf, CSVFormat), IsNil)
+	line, err := buf.ReadString('\n')
+	c.Assert(err, IsNil)
+	c.Assert(line, Equals, "6b6579303030,\n")
+
+	c.Assert(db.Export(&buf, ExportFormat(100)), NotNil)
+	c.Assert(db.Import(&buf, ExportFormat(100)), NotNil)
+}
+
+func (s *testMemDBSuite) TestUnionWith(c *C) {
+	counter := func(v int64) []byte {
+		buf := make([]byte, 8)
+		binary.LittleEndian.PutUint64(buf, uint64(v))
+		return buf
+	}
+	newDBs := func() (*MemDB, *MemDB) {
+		db, other := newMemDB(), newMemDB()
+		c.Assert(db.Set([]byte("a"), counter(1)), IsNil)
+		c.Assert(db.Set([]byte("b"), counter(2)), IsNil)
+		c.Assert(db.Delete([]byte("c")), IsNil)
+		c.Assert(other.Set([]byte("b"), counter(-5)), IsNil)
+		c.Assert(other.Set([]byte("c"), counter(3)), IsNil)
+		c.Assert(other.Set([]byte("d"), counter(4)), IsNil)
+		other.UpdateFlags([]byte("e"), kv.SetKeyLocked)
+		return db, other
+	}
+	check := func(db *MemDB, expected map[string][]byte) {
+		it, err := db.Iter(nil, nil)
+		c.Assert(err, IsNil)
+		n := 0
+		for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+			n++
+		}
+		it.Close()
+		c.Assert(n, Equals, len(expected))
+		for k, v := range expected {
+			val, err := db.Get([]byte(k))
+			c.Assert(err, IsNil)
+			c.Assert(val, BytesEquals, v, Commentf("%s", k))
+		}
+	}
+
+	db, other := newDBs()
+	c.Assert(db.UnionWith(other, LastWriteWins), IsNil)
+	check(db, map[string][]byte{"a": counter(1), "b": counter(-5), "c": counter(3), "d": counter(4)})
+	// The flags are merged like MergeTo.
+	flags, err := db.GetFlags([]byte("e"))
+	c.Assert(err, IsNil)
+	c.Assert(flags.HasLocked(), IsTrue)
+
+	// The tombstone of c is overwritten as LastWriteWins.
+	db, other = newDBs()
+	c.Assert(db.UnionWith(other, Accumulate), IsNil)
+	check(db, map[string][]byte{"a": counter(1), "b": counter(-3), "c": counter(3), "d": counter(4)})
+
+	// The MemDB is left unchanged on error, though b and c are before d.
+	db, other = newDBs()
+	c.Assert(db.Set([]byte("d"), counter(0)), IsNil)
+	c.Assert(other.Set([]byte("d"), []byte("v")), IsNil)
+	c.Assert(db.UnionWith(other, Accumulate), NotNil)
+	check(db, map[string][]byte{"a": counter(1), "b": counter(2), "c": {}, "d": counter(0)})
+
+	db, other = newDBs()
+	err = db.UnionWith(other, ErrorOnConflict)
+	_, ok := err.(*tikverr.ErrMergeConflict)
+	c.Assert(ok, IsTrue)
+	check(db, map[string][]byte{"a": counter(1), "b": counter(2), "c": {}})
+	c.Assert(other.Delete([]byte("b")), IsNil)
+	c.Assert(other.Delete([]byte("c")), IsNil)
+	// The tombstones conflict as well.
+	_, ok = db.UnionWith(other, ErrorOnConflict).(*tikverr.ErrMergeConflict)
+	c.Assert(ok, IsTrue)
+	other = newMemDB()
+	c.Assert(other.Set([]byte("d"), counter(4)), IsNil)
+	c.Assert(db.UnionWith(other, ErrorOnConflict), IsNil)
+	check(db, map[string][]byte{"a": counter(1), "b": counter(2), "c": {}, "d": counter(4)})
+
+	c.Assert(db.UnionWith(db, LastWriteWins), NotNil)
+}
+
+func (s *testMemDBSuite) TestSplitAt(c *C) {
+	db := newMemDB()
+	for _, k := range []string{"a", "k", "l", "m", "n", "z"} {
+		c.Assert(db.Set([]byte(k), []byte(k+"0")), IsNil)
+	}
+	h1 := db.Staging()
+	// The tombstones straddle the split key "m".
+	c.Assert(db.Delete([]byte("l")), IsNil)
+	c.Assert(db.Delete([]byte("m")), IsNil)
+	c.Assert(db.Set([]byte("k"), []byte("k1")), IsNil)
+	h2 := db.Staging()
+	c.Assert(db.Set([]byte("l"), []byte("l2")), IsNil)
+	c.Assert(db.Set([]byte("n"), []byte("n2")), IsNil)
+	db.UpdateFlags([]byte("ma"), kv.SetKeyLocked)
+	c.Assert(db.SetWithTTL([]byte("b"), []byte("b2"), time.Now().Add(-time.Second)), IsNil)
+
+	_, _, err := db.SplitAt(nil)
+	c.Assert(err, NotNil)
+	left, right, err := db.SplitAt([]byte("m"))
+	c.Assert(err, IsNil)
+	s.checkRBTree(c, left)
+	s.checkRBTree(c, right)
+	check := func(db *MemDB, expected map[string]string) {
+		for k, v := range expected {
+			val, err := db.Get([]byte(k))
+			c.Assert(err, IsNil, Commentf("%s", k))
+			c.Assert(string(val), Equals, v, Commentf("%s", k))
+		}
+	}
+	c.Assert(left.Len(), Equals, 4)
+	check(left, map[string]string{"a": "a0", "k": "k1", "l": "l2"})
+	// The expired key stays expired.
+	_, err = left.Get([]byte("b"))
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+	c.Assert(right.Len(), Equals, 4)
+	check(right, map[string]string{"m": "", "n": "n2", "z": "z0"})
+	flags, err := right.GetFlags([]byte("ma"))
+	c.Assert(err, IsNil)
+	c.Assert(flags.HasLocked(), IsTrue)
+
+	// db is not modified, its staging buffers still work.
+	db.Cleanup(h2)
+	check(db, map[string]string{"k": "k1", "l": "", "m": "", "n": "n0"})
+	db.Cleanup(h1)
+	check(db, map[string]string{"k": "k0", "l": "l0", "m": "m0"})
+	check(left, map[string]string{"k": "k1", "l": "l2"})
+
+	// The split MemDBs are fully functional.
+	h := left.Staging()
+	c.Assert(left.Set([]byte("c"), []byte("c3")), IsNil)
+	c.Assert(right.Delete([]byte("z")), IsNil)
+	check(left, map[string]string{"c": "c3"})
+	left.Cleanup(h)
+	_, err = left.Get([]byte("c"))
+	c.Assert(tikverr.IsErrNotFound(err), IsTrue)
+	var keys []string
+	it, err := right.Iter(nil, nil)
+	c.Assert(err, IsNil)
+	for ; it.Valid(); c.Assert(it.Next(), IsNil) {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+	c.Assert(keys, DeepEquals, []string{"m", "n", "z"})
+}
+
+func (s *testMemDBSuite) TestHistogram(c *C) {
+	c.Assert(newMemDB().Histogram(4), IsNil)
+
+	const cnt = 1000
+	db := s.fillDB(cnt)
+	c.Assert(db.Delete([]byte{0, 0, 0, 1}), IsNil)
+	db.UpdateFlags([]byte{0, 0, 0, 1, 0}, kv.SetKeyLocked)
+	c.Assert(db.Histogram(0), IsNil)
+
+	for _, buckets := range []int{1, 7, cnt, cnt + 1, 2 * cnt} {
+		hist := db.Histogram(buckets)
+		expectedBuckets := buckets
+		if expectedBuckets > db.Len() {
+			expectedBuckets = db.Len()
+		}
+		c.Assert(hist, HasLen, expectedBuckets)
+		sum := 0
+		for i, bucket := range hist {
+			sum += bucket.Count
+			// The counts of the buckets differ by at most 1.
+			c.Assert(bucket.Count-hist[len(hist)-1].Count <= 1, IsTrue)
+			c.Assert(bytes.Compare(bucket.LowKey, bucket.HighKey) <= 0, IsTrue)
+			if i > 0 {
+				c.Assert(bytes.Compare(hist[i-1].HighKey, bucket.LowKey) < 0, IsTrue)
+			}
+		}
+		c.Assert(sum, Equals, db.Len())
+		c.Assert(hist[0].LowKey, BytesEquals, []byte{0, 0, 0, 0})
+		c.Assert(hist[len(hist)-1].HighKey, BytesEquals, []byte{0, 0, 0x03, 0xe7})
+	}
+
+	// The tombstone and the flags only key are counted with the value size 0.
+	hist := db.Histogram(1)
+	c.Assert(hist[0].Count, Equals, cnt+1)
+	c.Assert(hist[0].AvgValueSize, Equals, float64((cnt-1)*4)/float64(cnt+1))
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	"github.com/pingcap/errors"
+)
+
+// MergePolicy decides the value of a key written by both MemDBs merged by MemDB.UnionWith.
+// It's the ConflictPolicy of MergeTo, which UnionWith is built on.
+type MergePolicy = ConflictPolicy
+
+const (
+	// LastWriteWins takes the value of the merged MemDB.
+	LastWriteWins = ConflictLastWins
+	// Accumulate takes the values as little-endian int64 counters and adds them up.
+	Accumulate = ConflictAccumulate
+	// ErrorOnConflict returns ErrMergeConflict.
+	ErrorOnConflict = ConflictError
+)
+
+// UnionWith merges other into db by other.MergeTo(db, policy), so the values written by only one MemDB
+// are kept, the values of the keys written by both are decided by policy, and the flags are merged.
+// The tombstones are merged as values. db is left unchanged if a key is too large or conflicts.
+func (db *MemDB) UnionWith(other *MemDB, policy MergePolicy) error {
+	if db == other {
+		return errors.New("cannot union a MemDB with itself")
+	}
+	return other.MergeTo(db, policy)
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 PingCAP, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unionstore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// memdbWatchList is the subscribers of a key watched by MemDB.Watch.
+type memdbWatchList struct {
+	sync.Mutex
+	chans []chan struct{}
+	// removed is set when the list is removed from MemDB.watchers, the new subscribers
+	// must not be added to it then.
+	removed bool
+}
+
+// Watch returns a channel which is closed when the value of key is changed, it's for the
+// subscribers which want to react to the changes instead of polling the MemDB.
+// All the writes of the value are changes, including setting the same value, deleting the key,
+// rolling back the value by Cleanup, purging the expired key and Reset, but updating the flags is not.
+// The channel is closed at most once, so the subscriber needs to Watch again to get the next change,
+// the changes in between are not delivered. The returned cancel function unsubscribes the channel
+// without closing it, it's safe to call it more than once or after the channel is closed.
+func (db *MemDB) Watch(key []byte) (<-chan struct{}, context.CancelFunc) {
+	ch := make(chan struct{})
+	for {
+		v, loaded := db.watchers.LoadOrStore(string(key), &memdbWatchList{})
+		l := v.(*memdbWatchList)
+		l.Lock()
+		if l.removed {
+			// The list has been notified or canceled, wait for it to be deleted from watchers.
+			l.Unlock()
+			continue
+		}
+		if !loaded {
+			atomic.AddInt32(&db.watchedKeys, 1)
+		}
+		l.chans = append(l.chans, ch)
+		l.Unlock()
+		return ch, func() { db.unwatch(key, l, ch) }
+	}
+}
+
+func (db *MemDB) unwatch(key []byte, l *memdbWatchList, ch chan struct{}) {
+	l.Lock()
+	defer l.Unlock()
+	if l.removed {
+		return
+	}
+	for i, c := range l.chans {
+		if c == ch {
+			l.chans = append(l.chans[:i], l.chans[i+1:]...)
+			break
+		}
+	}
+	if len(l.chans) == 0 {
+		db.removeWatchList(key, l)
+	}
+}
+
+// removeWatchList deletes l from watchers, l must be locked.
+func (db *MemDB) removeWatchList(key []byte, l *memdbWatchList) {
+	l.removed = true
+	db.watchers.Delete(string(key))
+	atomic.AddInt32(&db.watchedKeys, -1)
+}
+
+// notifyWatchers closes the channels subscribed to the key of x. It's called on every write, so it's
+// kept small enough to be inlined into the writes, and only checks the number of the watched keys if
+// nothing is watched. The key of x is read by the outlined notifyNodeWatchers.
+func (db *MemDB) notifyWatchers(x memdbNodeAddr) {
+	if atomic.LoadInt32(&db.watchedKeys) != 0 {
+		db.notifyNodeWatchers(x)
+	}
+}
+
+func (db *MemDB) notifyNodeWatchers(x memdbNodeAddr) {
+	db.notifyKeyWatchers(x.getKey())
+}
+
+// notifyKeyWatchers closes the channels subscribed to key.
+func (db *MemDB) notifyKeyWatchers(key []byte) {
+	v, ok := db.watchers.Load(string(key))
+	if !ok {
+		return
+	}
+	l := v.(*memdbWatchList)
+	l.Lock()
+	defer l.Unlock()
+	if l.removed {
+		return
+	}
+	for _, ch := range l.chans {
+		close(ch)
+	}
+	l.chans = nil
+	db.removeWatchList(key, l)
+}
+
+// notifyAllWatchers closes all the channels subscribed to the MemDB.
+func (db *MemDB) notifyAllWatchers() {
+	if atomic.LoadInt32(&db.watchedKeys) == 0 {
+		return
+	}
+	db.watchers.Range(func(k, _ interface{}) bool {
+		db.notifyKeyWatchers([]byte(k.(string)))
+		return true
+	})
+}
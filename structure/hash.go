@@ -260,6 +260,7 @@ func (t *TxStructure) iterateHash(key []byte, fn func(k []byte, v []byte) error)
 	if err != nil {
 		return errors.Trace(err)
 	}
+	defer it.Close()
 
 	var field []byte
 
@@ -331,6 +332,7 @@ func (i *ReverseHashIterator) Value() []byte {
 
 // Close Implements the Iterator Close.
 func (i *ReverseHashIterator) Close() {
+	i.iter.Close()
 }
 
 // NewHashReverseIter creates a reverse hash iterator.
@@ -353,6 +355,7 @@ func (t *TxStructure) iterReverseHash(key []byte, fn func(k []byte, v []byte) (b
 	if err != nil {
 		return errors.Trace(err)
 	}
+	defer it.Close()
 
 	var field []byte
 	for it.Valid() {
@@ -21,6 +21,7 @@ import (
 	"math"
 	"strconv"
 	"strings"
+	gotime "time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/mysql"
@@ -661,6 +662,40 @@ func ConvertJSONToDecimal(sc *stmtctx.StatementContext, j json.BinaryJSON) (*MyD
 	return res, errors.Trace(err)
 }
 
+// ConvertJSONToTime casts JSON into time with the specified type and fsp.
+// A JSON string is parsed as a datetime literal, and a JSON integer is treated
+// as a Unix timestamp in the time zone of the statement context.
+func ConvertJSONToTime(sc *stmtctx.StatementContext, j json.BinaryJSON, tp byte, fsp int) (Time, error) {
+	switch j.TypeCode {
+	case json.TypeCodeString:
+		return ParseTime(sc, string(hack.String(j.GetString())), tp, int8(fsp))
+	case json.TypeCodeInt64, json.TypeCodeUint64:
+		if j.TypeCode == json.TypeCodeUint64 && j.GetUint64() > math.MaxInt64 {
+			break
+		}
+		checkedFsp, err := CheckFsp(fsp)
+		if err != nil {
+			return NewTime(ZeroCoreTime, tp, DefaultFsp), errors.Trace(err)
+		}
+		tz := gotime.Local
+		if sc != nil && sc.TimeZone != nil {
+			tz = sc.TimeZone
+		}
+		goTime := gotime.Unix(j.GetInt64(), 0).In(tz)
+		ct := FromGoTime(goTime)
+		if tp == mysql.TypeDate {
+			// A DATE has no time part.
+			ct = FromDate(goTime.Year(), int(goTime.Month()), goTime.Day(), 0, 0, 0, 0)
+		}
+		t := NewTime(ct, tp, checkedFsp)
+		if err = t.check(sc); err != nil {
+			return NewTime(ZeroCoreTime, tp, DefaultFsp), errors.Trace(err)
+		}
+		return t, nil
+	}
+	return NewTime(ZeroCoreTime, tp, DefaultFsp), errors.Trace(ErrWrongValue.GenWithStackByArgs(DateTimeStr, j.String()))
+}
+
 // getValidFloatPrefix gets prefix of string which can be successfully parsed as float.
 func getValidFloatPrefix(sc *stmtctx.StatementContext, s string, isFuncCast bool) (valid string, err error) {
 	if isFuncCast && s == "" {
@@ -1062,6 +1062,46 @@ func (s *testTypeConvertSuite) TestConvertJSONToDecimal(c *C) {
 	}
 }
 
+func (s *testTypeConvertSuite) TestConvertJSONToTime(c *C) {
+	sc := &stmtctx.StatementContext{TimeZone: time.UTC}
+	var tests = []struct {
+		In  string
+		Tp  byte
+		Out string
+	}{
+		{`"2024-06-01"`, mysql.TypeDate, "2024-06-01"},
+		{`"2024-06-01 12:34:56"`, mysql.TypeDatetime, "2024-06-01 12:34:56"},
+		{`1717200000`, mysql.TypeDatetime, "2024-06-01 00:00:00"},
+		{`1717200000`, mysql.TypeDate, "2024-06-01"},
+		{`1717243200`, mysql.TypeDatetime, "2024-06-01 12:00:00"},
+		{`1717243200`, mysql.TypeDate, "2024-06-01"},
+	}
+	for _, tt := range tests {
+		j, err := json.ParseBinaryFromString(tt.In)
+		c.Assert(err, IsNil)
+		casted, err := ConvertJSONToTime(sc, j, tt.Tp, 0)
+		c.Assert(err, IsNil, Commentf("input: %v", tt.In))
+		c.Assert(casted.String(), Equals, tt.Out, Commentf("input: %v", tt.In))
+	}
+
+	// The time part of a DATE is zero, so it's equal to the DATE of the same day.
+	j, err := json.ParseBinaryFromString(`1717243200`)
+	c.Assert(err, IsNil)
+	casted, err := ConvertJSONToTime(sc, j, mysql.TypeDate, 0)
+	c.Assert(err, IsNil)
+	date, err := ParseDate(sc, "2024-06-01")
+	c.Assert(err, IsNil)
+	c.Assert(casted.Compare(date), Equals, 0)
+	c.Assert(casted.CoreTime().Hour(), Equals, 0)
+
+	for _, in := range []string{`{}`, `[]`, `true`, `1.5`} {
+		j, err := json.ParseBinaryFromString(in)
+		c.Assert(err, IsNil)
+		_, err = ConvertJSONToTime(sc, j, mysql.TypeDatetime, 0)
+		c.Assert(ErrWrongValue.Equal(err), IsTrue, Commentf("input: %v", in))
+	}
+}
+
 func (s *testTypeConvertSuite) TestNumberToDuration(c *C) {
 	var testCases = []struct {
 		number int64
@@ -4,7 +4,7 @@ import "github.com/pingcap/tidb/types/json"
 
 // FuzzMarshalJSON implements the fuzzer
 func FuzzMarshalJSON(data []byte) int {
-	bj, err := json.ParseBinaryFromString(string(data))
+	bj, err := json.ParseBinaryFromBytes(data)
 	if err != nil {
 		return -1
 	}
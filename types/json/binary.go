@@ -125,6 +125,12 @@ func (bj BinaryJSON) MarshalJSON() ([]byte, error) {
 	return bj.marshalTo(buf)
 }
 
+// AppendTo appends the JSON text of bj to buf and returns the extended buffer.
+// The result is the same as String, but nothing is allocated if buf has enough capacity.
+func (bj BinaryJSON) AppendTo(buf []byte) ([]byte, error) {
+	return bj.marshalTo(buf)
+}
+
 func (bj BinaryJSON) marshalTo(buf []byte) ([]byte, error) {
 	switch bj.TypeCode {
 	case TypeCodeString:
@@ -393,11 +399,17 @@ func marshalLiteralTo(b []byte, litType byte) []byte {
 
 // ParseBinaryFromString parses a json from string.
 func ParseBinaryFromString(s string) (bj BinaryJSON, err error) {
-	if len(s) == 0 {
+	return ParseBinaryFromBytes(hack.Slice(s))
+}
+
+// ParseBinaryFromBytes parses a json from bytes, it's for the callers which already have the bytes.
+// The result doesn't reference data, and data is only read, so it's safe for concurrent callers
+// sharing data as long as nobody modifies data during the call.
+func ParseBinaryFromBytes(data []byte) (bj BinaryJSON, err error) {
+	if len(data) == 0 {
 		err = ErrInvalidJSONText.GenWithStackByArgs("The document is empty")
 		return
 	}
-	data := hack.Slice(s)
 	if !json.Valid(data) {
 		err = ErrInvalidJSONText.GenWithStackByArgs("The document root must not be followed by other values.")
 		return
@@ -16,6 +16,7 @@ package json
 import (
 	"math"
 	"strings"
+	"sync"
 	"testing"
 
 	. "github.com/pingcap/check"
@@ -43,6 +44,60 @@ func (s *testJSONSuite) TestBinaryJSONMarshalUnmarshal(c *C) {
 	}
 }
 
+func (s *testJSONSuite) TestBinaryJSONAppendTo(c *C) {
+	c.Parallel()
+	strs := []string{
+		`{"a": [1, "2", {"aa": "bb"}, 4, null], "b": true, "c": null}`,
+		`[{"a": 1, "b": true}, 3, 3.5, "hello, world", null, true]`,
+		`"hello"`,
+		`-1`,
+		`18446744073709551615`,
+		`null`,
+	}
+	buf := make([]byte, 0, 256)
+	for _, str := range strs {
+		var err error
+		buf, err = mustParseBinaryFromString(c, str).AppendTo(buf[:0])
+		c.Assert(err, IsNil)
+		c.Assert(string(buf), Equals, str)
+	}
+	// It appends to the existing content.
+	buf, err := mustParseBinaryFromString(c, `[1, 2]`).AppendTo([]byte("x"))
+	c.Assert(err, IsNil)
+	c.Assert(string(buf), Equals, `x[1, 2]`)
+}
+
+func (s *testJSONSuite) TestParseBinaryFromBytes(c *C) {
+	c.Parallel()
+	data := []byte(`{"a": [1, "2"], "b": "c"}`)
+	var wg sync.WaitGroup
+	results := make([]BinaryJSON, 10)
+	errs := make([]error, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = ParseBinaryFromBytes(data)
+		}(i)
+	}
+	wg.Wait()
+	for i := range results {
+		c.Assert(errs[i], IsNil)
+		c.Assert(results[i].String(), Equals, string(data))
+	}
+
+	// The result doesn't reference the bytes.
+	bj, err := ParseBinaryFromBytes(data)
+	c.Assert(err, IsNil)
+	copy(data, `{"x"`)
+	c.Assert(bj.String(), Equals, `{"a": [1, "2"], "b": "c"}`)
+
+	for _, bad := range []string{"", "{", `{"a": 1} 2`} {
+		_, err = ParseBinaryFromBytes([]byte(bad))
+		c.Assert(ErrInvalidJSONText.Equal(err), IsTrue, Commentf("%q", bad))
+	}
+}
+
 func (s *testJSONSuite) TestBinaryJSONExtract(c *C) {
 	c.Parallel()
 	bj1 := mustParseBinaryFromString(c, `{"\"hello\"": "world", "a": [1, "2", {"aa": "bb"}, 4.0, {"aa": "cc"}], "b": true, "c": ["d"]}`)
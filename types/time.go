@@ -384,6 +384,32 @@ func (t Time) String() string {
 	return str
 }
 
+// AppendTo appends the string format of t to buf and returns the extended buffer.
+// The result is the same as String, but nothing is allocated if buf has enough capacity.
+func (t Time) AppendTo(buf []byte) []byte {
+	buf = appendIntWidthN(buf, t.Year(), 4)
+	buf = append(buf, '-')
+	buf = appendIntWidthN(buf, t.Month(), 2)
+	buf = append(buf, '-')
+	buf = appendIntWidthN(buf, t.Day(), 2)
+	if t.Type() == mysql.TypeDate {
+		return buf
+	}
+
+	buf = append(buf, ' ')
+	buf = appendIntWidthN(buf, t.Hour(), 2)
+	buf = append(buf, ':')
+	buf = appendIntWidthN(buf, t.Minute(), 2)
+	buf = append(buf, ':')
+	buf = appendIntWidthN(buf, t.Second(), 2)
+	if fsp := int(t.Fsp()); fsp > 0 {
+		buf = append(buf, '.')
+		buf = appendIntWidthN(buf, t.Microsecond(), 6)
+		buf = buf[:len(buf)-6+fsp]
+	}
+	return buf
+}
+
 // IsZero returns a boolean indicating whether the time is equal to ZeroCoreTime.
 func (t Time) IsZero() bool {
 	return compareTime(t.coreTime, ZeroCoreTime) == 0
@@ -1451,6 +1477,24 @@ func (d Duration) ToNumber() *MyDecimal {
 	return dec
 }
 
+// ToDecimalExact is like ToNumber, but the decimal is built by integer arithmetic instead of
+// formatting and parsing a string. Like formatFrac, the fraction is truncated to the fsp digits.
+func (d Duration) ToDecimalExact() *MyDecimal {
+	sign, hours, minutes, seconds, fraction := splitDuration(d.Duration)
+	fsp := int(d.Fsp)
+	num := int64(hours)*10000 + int64(minutes)*100 + int64(seconds)
+	num = num*int64(powers10[fsp]) + int64(fraction)/int64(powers10[int(MaxFsp)-fsp])
+
+	dec := NewDecFromInt(int64(sign) * num)
+	// Neither the shift nor the round can overflow, since the digits are fewer than the ones of an int64.
+	err := dec.Shift(-fsp)
+	terror.Log(errors.Trace(err))
+	// Shift drops the trailing zeros of the fraction, round to fsp to keep them.
+	err = dec.Round(dec, fsp, ModeTruncate)
+	terror.Log(errors.Trace(err))
+	return dec
+}
+
 // ConvertToTime converts duration to Time.
 // Tp is TypeDatetime, TypeTimestamp and TypeDate.
 func (d Duration) ConvertToTime(sc *stmtctx.StatementContext, tp uint8) (Time, error) {
@@ -1922,6 +1966,13 @@ func ParseTimeFromFloatString(sc *stmtctx.StatementContext, str string, tp byte,
 	return parseTime(sc, str, tp, fsp, true)
 }
 
+// ParseTimeFromDecimal parses a decimal as the float converted string.
+// The digits are read from the fixed-point representation of dec, so a decimal parsed from
+// a scientific notation like "2.0201231235959E+13" is handled as "20201231235959".
+func ParseTimeFromDecimal(sc *stmtctx.StatementContext, dec *MyDecimal, tp byte, fsp int8) (Time, error) {
+	return ParseTimeFromFloatString(sc, string(dec.ToString()), tp, fsp)
+}
+
 func parseTime(sc *stmtctx.StatementContext, str string, tp byte, fsp int8, isFloat bool) (Time, error) {
 	fsp, err := CheckFsp(int(fsp))
 	if err != nil {
@@ -2701,6 +2752,18 @@ func FormatIntWidthN(num, n int) string {
 	return string(padBytes) + numString
 }
 
+// appendIntWidthN is like FormatIntWidthN, but appends the result to buf.
+func appendIntWidthN(buf []byte, num, n int) []byte {
+	digits := 1
+	for x := num; x >= 10; x /= 10 {
+		digits++
+	}
+	for ; digits < n; digits++ {
+		buf = append(buf, '0')
+	}
+	return strconv.AppendInt(buf, int64(num), 10)
+}
+
 func abbrDayOfMonth(day int) string {
 	var str string
 	switch day {
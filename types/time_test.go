@@ -772,6 +772,42 @@ func (s *testTimeSuite) TestToNumber(c *C) {
 	}
 }
 
+func (s *testTimeSuite) TestDurationToDecimalExact(c *C) {
+	sc := mock.NewContext().GetSessionVars().StmtCtx
+	defer testleak.AfterTest(c)()
+	tbl := []struct {
+		Input  string
+		Fsp    int8
+		Expect string
+	}{
+		{"11:30:45", 0, "113045"},
+		{"11:30:45", 6, "113045.000000"},
+		{"11:30:45.123", 6, "113045.123000"},
+		{"11:30:45.123345", 3, "113045.123"},
+		{"11:30:45.123345", 5, "113045.12335"},
+		{"12:34:56.123456", 6, "123456.123456"},
+		{"11:30:45.9233456", 0, "113046"},
+		{"-11:30:45.9233456", 0, "-113046"},
+		{"-00:00:00.5", 1, "-0.5"},
+		{"00:00:00", 6, "0.000000"},
+		{"838:59:59", 6, "8385959.000000"},
+		{"-838:59:58.999999", 6, "-8385958.999999"},
+	}
+
+	for _, test := range tbl {
+		t, err := types.ParseDuration(sc, test.Input, test.Fsp)
+		c.Assert(err, IsNil)
+		dec := t.ToDecimalExact()
+		c.Assert(dec.String(), Equals, test.Expect)
+		c.Assert(dec.Compare(t.ToNumber()), Equals, 0)
+		// Truncate to a smaller fsp like ToNumber does.
+		for fsp := int8(0); fsp < t.Fsp; fsp++ {
+			t.Fsp = fsp
+			c.Assert(t.ToDecimalExact().String(), Equals, t.ToNumber().String())
+		}
+	}
+}
+
 func (s *testTimeSuite) TestParseTimeFromFloatString(c *C) {
 	sc := mock.NewContext().GetSessionVars().StmtCtx
 	sc.IgnoreZeroInDate = true
@@ -805,6 +841,35 @@ func (s *testTimeSuite) TestParseTimeFromFloatString(c *C) {
 	}
 }
 
+func (s *testTimeSuite) TestParseTimeFromDecimal(c *C) {
+	sc := mock.NewContext().GetSessionVars().StmtCtx
+	defer testleak.AfterTest(c)()
+	table := []struct {
+		Input       string
+		Fsp         int8
+		ExpectError bool
+		Expect      string
+	}{
+		{"2.0201231235959E+13", 0, false, "2020-12-31 23:59:59"},
+		{"2.01212311130451234E+13", 4, false, "2012-12-31 11:30:45.1234"},
+		{"1.21231113045E+11", 0, false, "2012-12-31 11:30:45"},
+		{"2.0170118E+7", 0, false, "2017-01-18 00:00:00"},
+		{"1.23456789E+7", 0, true, ""},
+	}
+
+	for _, test := range table {
+		dec := new(types.MyDecimal)
+		c.Assert(dec.FromString([]byte(test.Input)), IsNil)
+		t, err := types.ParseTimeFromDecimal(sc, dec, mysql.TypeDatetime, test.Fsp)
+		if test.ExpectError {
+			c.Assert(err, NotNil)
+		} else {
+			c.Assert(err, IsNil)
+			c.Assert(t.String(), Equals, test.Expect)
+		}
+	}
+}
+
 func (s *testTimeSuite) TestParseFrac(c *C) {
 	defer testleak.AfterTest(c)()
 	tbl := []struct {
@@ -1869,6 +1934,32 @@ func (s *testTimeSuite) TestFormatIntWidthN(c *C) {
 	}
 }
 
+func (s *testTimeSuite) TestTimeAppendTo(c *C) {
+	sc := mock.NewContext().GetSessionVars().StmtCtx
+	sc.IgnoreZeroInDate = true
+	cases := []struct {
+		input string
+		tp    byte
+		fsp   int8
+	}{
+		{"0000-00-00 00:00:00", mysql.TypeDatetime, 0},
+		{"0001-01-01 01:02:03.000004", mysql.TypeDatetime, 6},
+		{"2012-12-31 11:30:45.123456", mysql.TypeDatetime, 3},
+		{"2012-12-31 11:30:45.1", mysql.TypeTimestamp, 1},
+		{"9999-12-31 23:59:59.999999", mysql.TypeDatetime, 6},
+		{"2012-12-31", mysql.TypeDate, 0},
+		{"0000-00-00", mysql.TypeDate, 0},
+	}
+	buf := make([]byte, 0, 32)
+	for _, ca := range cases {
+		t, err := types.ParseTime(sc, ca.input, ca.tp, ca.fsp)
+		c.Assert(err, IsNil)
+		buf = t.AppendTo(buf[:0])
+		c.Assert(string(buf), Equals, t.String())
+	}
+	c.Assert(string(types.ZeroDatetime.AppendTo([]byte("x"))), Equals, "x0000-00-00 00:00:00")
+}
+
 func (s *testTimeSuite) TestFromGoTime(c *C) {
 	// Test rounding of nanosecond to millisecond.
 	cases := []struct {